@@ -0,0 +1,175 @@
+package ssp
+
+import (
+	"fmt"
+
+	"github.com/affo/ssp/values"
+)
+
+// Aggregator incrementally computes an aggregate over values.
+// Accumulators must be treated as immutable: Add returns a new accumulator.
+type Aggregator interface {
+	// Init returns the initial accumulator.
+	Init() values.Value
+	// Add adds v to the accumulator.
+	Add(acc, v values.Value) (values.Value, error)
+	// Result returns the aggregate for the accumulator.
+	Result(acc values.Value) (values.Value, error)
+	// Name is used for naming nodes.
+	Name() string
+}
+
+// MergeableAggregator can merge accumulators computed separately.
+// The planner uses it for pre-aggregating values before they get shuffled.
+type MergeableAggregator interface {
+	Aggregator
+	// Merge merges two accumulators.
+	Merge(a, b values.Value) (values.Value, error)
+}
+
+type sumAggregator struct{}
+
+// Sum sums numbers and durations, ignoring nulls.
+// The result is null if no value is summed.
+func Sum() Aggregator {
+	return sumAggregator{}
+}
+
+func (sumAggregator) Init() values.Value {
+	return values.NewNull(values.Int64)
+}
+
+func (sumAggregator) Add(acc, v values.Value) (values.Value, error) {
+	return values.Add(acc, v)
+}
+
+func (sumAggregator) Merge(a, b values.Value) (values.Value, error) {
+	return values.Add(a, b)
+}
+
+func (sumAggregator) Result(acc values.Value) (values.Value, error) {
+	return acc, nil
+}
+
+func (sumAggregator) Name() string {
+	return "sum"
+}
+
+type countAggregator struct{}
+
+// Count counts values, as int64.
+func Count() Aggregator {
+	return countAggregator{}
+}
+
+func (countAggregator) Init() values.Value {
+	return values.New(int64(0))
+}
+
+func (countAggregator) Add(acc, _ values.Value) (values.Value, error) {
+	return values.New(acc.Int64() + 1), nil
+}
+
+func (countAggregator) Merge(a, b values.Value) (values.Value, error) {
+	return values.New(a.Int64() + b.Int64()), nil
+}
+
+func (countAggregator) Result(acc values.Value) (values.Value, error) {
+	return acc, nil
+}
+
+func (countAggregator) Name() string {
+	return "count"
+}
+
+type pickAggregator struct {
+	name string
+	pick func(a, b values.Value) (values.Value, error)
+}
+
+// Min computes the minimum value, according to values.Compare, ignoring nulls.
+func Min() Aggregator {
+	return pickAggregator{name: "min", pick: values.Min}
+}
+
+// Max computes the maximum value, according to values.Compare, ignoring nulls.
+func Max() Aggregator {
+	return pickAggregator{name: "max", pick: values.Max}
+}
+
+func (pickAggregator) Init() values.Value {
+	return values.NewNull(values.Int64)
+}
+
+func (a pickAggregator) Add(acc, v values.Value) (values.Value, error) {
+	return a.pick(acc, v)
+}
+
+func (a pickAggregator) Merge(x, y values.Value) (values.Value, error) {
+	return a.pick(x, y)
+}
+
+func (pickAggregator) Result(acc values.Value) (values.Value, error) {
+	return acc, nil
+}
+
+func (a pickAggregator) Name() string {
+	return a.name
+}
+
+type avgAccumulator struct {
+	sum values.Value
+	n   int64
+}
+
+type avgAggregator struct{}
+
+// Avg computes the average of numbers as float64, ignoring nulls.
+// The result is null if no value is averaged.
+func Avg() Aggregator {
+	return avgAggregator{}
+}
+
+func (avgAggregator) Init() values.Value {
+	return values.New(avgAccumulator{sum: values.NewNull(values.Float64)})
+}
+
+func (avgAggregator) Add(acc, v values.Value) (values.Value, error) {
+	a := acc.Get().(avgAccumulator)
+	if v.IsNull() && v.Type() != values.Object {
+		return acc, nil
+	}
+	if !values.IsNumeric(v.Type()) {
+		return nil, fmt.Errorf("cannot average value of type %v", v.Type())
+	}
+	sum, err := values.Add(a.sum, v)
+	if err != nil {
+		return nil, err
+	}
+	return values.New(avgAccumulator{sum: sum, n: a.n + 1}), nil
+}
+
+func (avgAggregator) Merge(a, b values.Value) (values.Value, error) {
+	x, y := a.Get().(avgAccumulator), b.Get().(avgAccumulator)
+	sum, err := values.Add(x.sum, y.sum)
+	if err != nil {
+		return nil, err
+	}
+	return values.New(avgAccumulator{sum: sum, n: x.n + y.n}), nil
+}
+
+func (avgAggregator) Result(acc values.Value) (values.Value, error) {
+	a := acc.Get().(avgAccumulator)
+	if a.n == 0 {
+		return values.NewNull(values.Float64), nil
+	}
+	sum, err := values.AsFloat64(a.sum)
+	if err != nil {
+		return nil, err
+	}
+	return values.New(sum / float64(a.n)), nil
+}
+
+func (avgAggregator) Name() string {
+	return "avg"
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// generate renders templates with JSON data, like github.com/benbjohnson/tmpl does for go generate.
+// The output of template "x.go.tmpl" is "x.go", unless -o is given.
+// Go files get a header that marks them as generated, and are formatted.
+func generate(args []string, stderr io.Writer) error {
+	fs := newFlagSet("generate", stderr)
+	data := fs.String("data", "", "JSON data for the templates, or @file to read it from file")
+	out := fs.String("o", "", "output file, if a single template is given")
+	noHeader := fs.Bool("no-header", false, "do not add the generated code header")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("%s: expected the path to at least one template", fs.Name())
+	}
+	if *out != "" && fs.NArg() > 1 {
+		return fmt.Errorf("%s: -o requires a single template, got %d", fs.Name(), fs.NArg())
+	}
+	var d interface{}
+	if *data != "" {
+		bs := []byte(*data)
+		if strings.HasPrefix(*data, "@") {
+			var err error
+			if bs, err = os.ReadFile(strings.TrimPrefix(*data, "@")); err != nil {
+				return err
+			}
+		}
+		if err := json.Unmarshal(bs, &d); err != nil {
+			return fmt.Errorf("cannot parse data: %v", err)
+		}
+	}
+	for _, path := range fs.Args() {
+		if err := render(path, *out, d, !*noHeader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const templateExt = ".tmpl"
+
+var funcs = template.FuncMap{
+	"title": strings.Title,
+}
+
+func render(path, out string, data interface{}, header bool) error {
+	if !strings.HasSuffix(path, templateExt) {
+		return fmt.Errorf("template must have %s extension: %s", templateExt, path)
+	}
+	if out == "" {
+		out = strings.TrimSuffix(path, templateExt)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	t, err := template.New(filepath.Base(path)).Funcs(funcs).Parse(string(src))
+	if err != nil {
+		return err
+	}
+	isGo := filepath.Ext(out) == ".go"
+	var buf bytes.Buffer
+	if header && isGo {
+		fmt.Fprintln(&buf, "// Generated by tmpl")
+		fmt.Fprintln(&buf, "// https://github.com/benbjohnson/tmpl")
+		fmt.Fprintln(&buf, "//")
+		fmt.Fprintln(&buf, "// DO NOT EDIT!")
+		fmt.Fprintln(&buf, "// Source:", path)
+		fmt.Fprintln(&buf, "")
+	}
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+	bs := buf.Bytes()
+	if isGo {
+		formatted, err := format.Source(bs)
+		if err != nil {
+			// Write the unformatted code, for inspection.
+			_ = os.WriteFile(out, bs, fi.Mode())
+			return fmt.Errorf("cannot format %s: %v", out, err)
+		}
+		bs = formatted
+	}
+	return os.WriteFile(out, bs, fi.Mode())
+}
@@ -0,0 +1,193 @@
+// Command ssp validates, explains and runs declarative pipelines, written in JSON or YAML,
+// and generates graph code from templates.
+//
+// Usage:
+//
+//	ssp validate <spec>
+//	ssp plan [-format text|dot|json] [-planner=false] <spec>
+//	ssp dot [-planner=false] <spec>
+//	ssp run [-planner=false] <spec>
+//	ssp generate -data <json|@file> [-o output] [-no-header] <template>...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/pipeline"
+)
+
+const usage = `usage: ssp <command> [flags] [args]
+
+commands:
+  validate  validate a pipeline spec
+  plan      print the plan of a pipeline spec
+  dot       render the plan of a pipeline spec in the Graphviz DOT language
+  run       run a pipeline spec locally
+  generate  generate graph code from templates
+
+Run "ssp <command> -h" for the flags of a command.
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return flag.ErrHelp
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "validate":
+		return validate(args, stdout, stderr)
+	case "plan":
+		return plan(args, stdout, stderr, "")
+	case "dot":
+		return plan(args, stdout, stderr, "dot")
+	case "run":
+		return runSpec(args, stderr)
+	case "generate":
+		return generate(args, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("ssp "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// specArg returns the only positional argument of fs, the path to a spec.
+func specArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s: expected the path to a spec, got %d arguments", fs.Name(), fs.NArg())
+	}
+	return fs.Arg(0), nil
+}
+
+// build loads the spec at path, and builds its Graph in a new context.
+// Warnings are written to stderr, and validation errors are returned.
+func build(path string, stderr io.Writer) (context.Context, func() error, error) {
+	s, err := pipeline.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := ssp.Context()
+	release, err := s.Build(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs, warns := ssp.Validate(ssp.GetGraph(ctx))
+	for _, w := range warns {
+		fmt.Fprintln(stderr, w)
+	}
+	if len(errs) > 0 {
+		_ = release()
+		return nil, nil, errs
+	}
+	return ctx, release, nil
+}
+
+func validate(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("validate", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path, err := specArg(fs)
+	if err != nil {
+		return err
+	}
+	_, release, err := build(path, stderr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: ok\n", path)
+	return release()
+}
+
+func plan(args []string, stdout, stderr io.Writer, format string) error {
+	name := "plan"
+	if format != "" {
+		name = format
+	}
+	fs := newFlagSet(name, stderr)
+	if format == "" {
+		fs.StringVar(&format, "format", "text", "output format: text, dot or json")
+	}
+	planner := fs.Bool("planner", true, "apply the default planning rules")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path, err := specArg(fs)
+	if err != nil {
+		return err
+	}
+	ctx, release, err := build(path, stderr)
+	if err != nil {
+		return err
+	}
+	e := newEngine(*planner).Explain(ctx)
+	switch format {
+	case "text":
+		_, err = io.WriteString(stdout, e.Text())
+	case "dot":
+		_, err = io.WriteString(stdout, e.DOT())
+	case "json":
+		var bs []byte
+		if bs, err = e.JSON(); err == nil {
+			_, err = fmt.Fprintln(stdout, string(bs))
+		}
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if rerr := release(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func runSpec(args []string, stderr io.Writer) error {
+	fs := newFlagSet("run", stderr)
+	planner := fs.Bool("planner", true, "apply the default planning rules")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path, err := specArg(fs)
+	if err != nil {
+		return err
+	}
+	ctx, release, err := build(path, stderr)
+	if err != nil {
+		return err
+	}
+	err = newEngine(*planner).Execute(ctx)
+	if rerr := release(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func newEngine(planner bool) *ssp.Engine {
+	if !planner {
+		return ssp.NewEngine()
+	}
+	return ssp.NewEngine(ssp.WithPlanner(ssp.DefaultPlanner()))
+}
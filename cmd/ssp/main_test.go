@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeSpec(t *testing.T, dir, out string) string {
+	t.Helper()
+	in := filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(in, []byte("a b\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec := filepath.Join(dir, "spec.json")
+	if err := os.WriteFile(spec, []byte(`{
+  "name": "words",
+  "nodes": [
+    {"name": "lines", "type": "file-source", "params": {"path": "`+in+`"}},
+    {"name": "words", "type": "split"},
+    {"name": "out", "type": "file-sink", "params": {"path": "`+out+`"}}
+  ],
+  "arches": [
+    {"from": "lines", "to": "words"},
+    {"from": "words", "to": "out"}
+  ]
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	spec := writeSpec(t, dir, out)
+
+	t.Run("validate", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if err := run([]string{"validate", spec}, stdout, stderr); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(spec+": ok\n", stdout.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+		// Building the pipeline truncates the output file.
+		if fi, err := os.Stat(out); err != nil || fi.Size() != 0 {
+			t.Errorf("expected an empty output file, got: %v, %v", fi, err)
+		}
+	})
+	t.Run("plan", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if err := run([]string{"plan", spec}, stdout, stderr); err != nil {
+			t.Fatal(err)
+		}
+		want := `logical:
+  lines (source, parallelism 1)
+    -> words [forward]
+  words (node, parallelism 1)
+    -> out [forward]
+  out (node, parallelism 1)
+rewrites:
+physical:
+  lines (source, parallelism 1)
+    -> words [forward]
+  words (node, parallelism 1)
+    -> out [forward]
+  out (node, parallelism 1)
+`
+		if diff := cmp.Diff(want, stdout.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+	t.Run("dot", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if err := run([]string{"dot", "-planner=false", spec}, stdout, stderr); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(stdout.String(), "digraph explain {\n") {
+			t.Errorf("unexpected result: %s", stdout)
+		}
+	})
+	t.Run("run", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if err := run([]string{"run", spec}, stdout, stderr); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("a\nb\nc\n", string(bs)); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			want string
+		}{
+			{args: []string{"nope"}, want: `unknown command "nope"`},
+			{args: []string{"run"}, want: "ssp run: expected the path to a spec, got 0 arguments"},
+			{args: []string{"plan", "-format", "svg", spec}, want: `unknown format "svg"`},
+			{args: []string{"generate", "-data", "{}", "x.go"}, want: "template must have .tmpl extension: x.go"},
+		} {
+			err := run(tc.args, &bytes.Buffer{}, &bytes.Buffer{})
+			if err == nil {
+				t.Fatalf("%v: expected error, got none", tc.args)
+			}
+			if diff := cmp.Diff(tc.want, err.Error()); diff != "" {
+				t.Errorf("%v: unexpected error -want/+got:\n\t%s", tc.args, diff)
+			}
+		}
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		template string
+		want     string
+	}{
+		{
+			name:     "topology",
+			data:     "../../topology.tmpldata",
+			template: "../../graph/graph.go.tmpl",
+			want:     "../../topology.gen.go",
+		},
+		{
+			name:     "values",
+			data:     "../../values/values.tmpldata",
+			template: "../../values/values.go.tmpl",
+			want:     "../../values/values.gen.go",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.go")
+			if err := run([]string{"generate", "-data", "@" + tc.data, "-o", out, tc.template}, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(tc.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Generated files only differ in the path to the template.
+			source := func(bs []byte) string {
+				lines := strings.Split(string(bs), "\n")
+				for i, l := range lines {
+					if strings.HasPrefix(l, "// Source: ") {
+						lines[i] = "// Source: " + filepath.Base(strings.TrimPrefix(l, "// Source: "))
+					}
+				}
+				return strings.Join(lines, "\n")
+			}
+			if diff := cmp.Diff(source(want), source(got)); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
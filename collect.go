@@ -1,6 +1,8 @@
 package ssp
 
 import (
+	"fmt"
+
 	"github.com/affo/ssp/values"
 )
 
@@ -8,10 +10,76 @@ type Collector interface {
 	Collect(v values.Value)
 }
 
+// TaggedCollector can collect values to named outputs, other than the main one.
+type TaggedCollector interface {
+	Collector
+	CollectTo(tag string, v values.Value)
+}
+
+// NewTaggedLink creates an Arch from the output of from tagged with tag.
+// Nodes use it to implement OutTag, like they use NewLink to implement Out.
+func NewTaggedLink(from Node, tag string) *Arch {
+	return NewLink(from).withTag(tag)
+}
+
+// CollectTo collects v to the output tagged with tag.
+// Collectors that do not support tagged outputs discard the value.
+// DeadLetterTag is reserved for the records that make nodes fail: collecting to it panics,
+// which makes the node fail on the record being processed.
+func CollectTo(c Collector, tag string, v values.Value) {
+	checkTag(tag)
+	if tc, ok := c.(TaggedCollector); ok {
+		tc.CollectTo(tag, v)
+	}
+}
+
+// Done returns a channel that is closed when the job of the node collecting to c gets canceled or fails.
+// Sources that run until canceled should stop emitting when it is closed.
+// It returns nil for collectors that are not part of a job.
+func Done(c Collector) <-chan struct{} {
+	if dc, ok := c.(interface{ Done() <-chan struct{} }); ok {
+		return dc.Done()
+	}
+	return nil
+}
+
 func SendClose(c Collector) {
 	c.Collect(values.NewMeta(values.Close))
 }
 
+// outputCollector routes values to the main output and to tagged outputs.
+// Values sent to outputs that are not connected get discarded.
+type outputCollector struct {
+	out    Collector
+	tagged map[string]Collector
+	done   <-chan struct{}
+}
+
+func (c outputCollector) Collect(v values.Value) {
+	if c.out != nil {
+		c.out.Collect(v)
+	}
+}
+
+func (c outputCollector) CollectTo(tag string, v values.Value) {
+	checkTag(tag)
+	if tc, ok := c.tagged[tag]; ok {
+		tc.Collect(v)
+	}
+}
+
+// checkTag panics if tag is reserved.
+// Dead letters are collected by the Operator directly, without going through CollectTo.
+func checkTag(tag string) {
+	if tag == DeadLetterTag {
+		panic(fmt.Sprintf("cannot collect to tag %q: it is reserved for dead letters", tag))
+	}
+}
+
+func (c outputCollector) Done() <-chan struct{} {
+	return c.done
+}
+
 // Transport enables collecting on a DataStream.
 type Transport interface {
 	Collector
@@ -0,0 +1,92 @@
+package ssp
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCollectTo(t *testing.T) {
+	main := &dumbCollector{}
+	invalid := &dumbCollector{}
+	c := outputCollector{
+		out: main,
+		tagged: map[string]Collector{
+			"invalid": invalid,
+		},
+	}
+	c.Collect(values.New(1))
+	CollectTo(c, "invalid", values.New(2))
+	// Not connected, discarded.
+	CollectTo(c, "alerts", values.New(3))
+	// Not a tagged collector, discarded.
+	CollectTo(main, "invalid", values.New(4))
+
+	if len(main.vs) != 1 || main.vs[0].Int() != 1 {
+		t.Errorf("unexpected main output: %v", main.vs)
+	}
+	if len(invalid.vs) != 1 || invalid.vs[0].Int() != 2 {
+		t.Errorf("unexpected tagged output: %v", invalid.vs)
+	}
+}
+
+func TestEngine_SideOutputs(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	splitter := NewNode(func(collector Collector, v values.Value) error {
+		for i := 0; i < 10; i++ {
+			collector.Collect(values.New(i))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			switch {
+			case v.Int() < 0:
+				CollectTo(collector, "unreachable", v)
+			case v.Int()%2 == 0:
+				collector.Collect(v)
+			default:
+				CollectTo(collector, "odd", v)
+			}
+			if v.Int() > 7 {
+				CollectTo(collector, "alerts", values.New("too high"))
+			}
+			return nil
+		})).
+		SetName("splitter").
+		SetParallelism(3)
+
+	even, evenLog := NewLogSink(values.Int)
+	odd, oddLog := NewLogSink(values.Int)
+	alerts, alertsLog := NewLogSink(values.String)
+	splitter.Out().Connect(ctx, even.SetName("even"))
+	splitter.OutTag("odd").Connect(ctx, odd.SetName("odd"))
+	splitter.OutTag("alerts").Connect(ctx, alerts.SetName("alerts"))
+
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	getInts := func(l *values.List) []int {
+		var got []int
+		for _, v := range l.GetValues() {
+			got = append(got, v.Int())
+		}
+		sort.Ints(got)
+		return got
+	}
+	if diff := cmp.Diff([]int{0, 2, 4, 6, 8}, getInts(evenLog)); diff != "" {
+		t.Errorf("unexpected even values -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff([]int{1, 3, 5, 7, 9}, getInts(oddLog)); diff != "" {
+		t.Errorf("unexpected odd values -want/+got:\n\t%s", diff)
+	}
+	if got, want := len(alertsLog.GetValues()), 2; got != want {
+		t.Errorf("unexpected number of alerts -want/+got:\n\t-\t%d\n\t+\t%d", want, got)
+	}
+}
@@ -0,0 +1,174 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/affo/ssp/values"
+)
+
+// CompositeFn builds a fragment of a Graph, from the input node of the fragment, and returns its output node.
+// The fragment must be made of new nodes, connected to the Graph in ctx.
+type CompositeFn func(ctx context.Context, in Node) (out Node)
+
+// Composite is a reusable fragment of a Graph, with one input and one output, that is connected like a single Node.
+//
+// The first Arch connected to a Composite expands the fragment into the Graph: the Arch feeds an input node,
+// that is passed to the CompositeFn of the Composite, together with the context.
+// Other Arches connected to the Composite in the same Graph feed the same input node.
+// If they are keyed, the fragment receives values by the same keys, unless it keys them again.
+// The nodes of the fragment are named after the Composite, e.g., "sessions/count" for node "count" in Composite "sessions".
+//
+// Out provides the output of the fragment, once the Composite is connected.
+// Parallelism and error policy, if set before connecting, apply to the nodes of the fragment that keep the defaults.
+type Composite struct {
+	baseNode
+	build      CompositeFn
+	expansions map[*Graph]*expansion
+	last       *expansion
+}
+
+// expansion is a Composite expanded into a Graph.
+type expansion struct {
+	in, out Node
+}
+
+func NewComposite(name string, build CompositeFn) *Composite {
+	c := &Composite{
+		baseNode:   newBaseNode(),
+		build:      build,
+		expansions: make(map[*Graph]*expansion),
+	}
+	c.name = name
+	return c
+}
+
+// expandComposite is the connect hook of Arch: it expands Composites instead of connecting them.
+func expandComposite(ctx context.Context, a *Arch, node Node) (Node, bool) {
+	c, ok := node.(*Composite)
+	if !ok {
+		return nil, false
+	}
+	c.connect(ctx, a)
+	return c, true
+}
+
+func (c *Composite) connect(ctx context.Context, a *Arch) {
+	g := getGraph(ctx)
+	e, ok := c.expansions[g]
+	if !ok {
+		before := make(map[Node]bool)
+		for _, n := range g.Nodes() {
+			before[n] = true
+		}
+		in := newChainNode(chain{steps: []step{{name: "input", f: func(values.Value) bool {
+			return true
+		}}}, keepKeys: true}).SetName("input")
+		out := c.build(ctx, in)
+		if oc, ok := out.(*Composite); ok {
+			out = oc.Output(ctx)
+		}
+		if out == nil {
+			panic(fmt.Sprintf("composite %q: no output node", c.name))
+		}
+		owned := []Node{in}
+		if out != in && !before[out] {
+			owned = append(owned, out)
+		}
+		for _, n := range g.Nodes() {
+			if !before[n] && n != in && n != out {
+				owned = append(owned, n)
+			}
+		}
+		for _, n := range owned {
+			c.own(n)
+		}
+		e = &expansion{in: in, out: out}
+		c.expansions[g] = e
+	}
+	c.last = e
+	a.Connect(ctx, e.in)
+	if a.ks != nil {
+		keepInputKeys(g, e.in)
+	}
+}
+
+// keepInputKeys makes the arches leaving in partition values by the keys they have,
+// unless the fragment keys them with a KeySelector of its own.
+func keepInputKeys(g *Graph, in Node) {
+	for _, a := range g.Adjacents(in) {
+		if a.ks == nil && a.tag == "" {
+			a.ks = keptKeySelector{}
+		}
+	}
+}
+
+// own names n after the Composite, and applies its options.
+func (c *Composite) own(n Node) {
+	n.SetName(c.name + "/" + n.GetName())
+	if c.par != 1 && n.GetParallelism() == 1 {
+		n.SetParallelism(c.par)
+	}
+	if pn, ok := n.(PolicyNode); ok && c.ep != FailOnError && pn.GetErrorPolicy() == FailOnError {
+		pn.SetErrorPolicy(c.ep)
+	}
+}
+
+// Output returns the output node of the fragment expanded into the Graph in ctx, or nil if the Composite is not connected to it.
+func (c *Composite) Output(ctx context.Context) Node {
+	if e, ok := c.expansions[getGraph(ctx)]; ok {
+		return e.out
+	}
+	return nil
+}
+
+func (c *Composite) output() Node {
+	if c.last == nil {
+		panic(fmt.Sprintf("composite %q is not connected", c.name))
+	}
+	return c.last.out
+}
+
+// Do fails, because Composites are expanded into other nodes when connected.
+func (c *Composite) Do(collector Collector, v values.Value) error {
+	return fmt.Errorf("composite %q cannot process values", c.name)
+}
+
+// Out provides the output of the fragment last expanded.
+func (c *Composite) Out() *Arch {
+	return c.output().Out()
+}
+
+// OutTag provides the output tagged with tag of the output node of the fragment.
+func (c *Composite) OutTag(tag string) *Arch {
+	return c.output().OutTag(tag)
+}
+
+// DeadLetters provides the dead letters of the output node of the fragment.
+func (c *Composite) DeadLetters() *Arch {
+	return DeadLetters(c.output())
+}
+
+func (c *Composite) SetParallelism(par int) Node {
+	c.par = par
+	return c
+}
+
+func (c *Composite) SetName(name string) Node {
+	c.name = name
+	return c
+}
+
+func (c *Composite) SetErrorPolicy(p ErrorPolicy) Node {
+	c.ep = p
+	return c
+}
+
+// Clone returns a Composite with the same options, that is not connected.
+func (c *Composite) Clone() Node {
+	return &Composite{
+		baseNode:   c.baseNode.Clone(),
+		build:      c.build,
+		expansions: make(map[*Graph]*expansion),
+	}
+}
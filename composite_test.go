@@ -0,0 +1,173 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// windowedCount timestamps values with their value, keys them by parity, and counts them in windows of size 4.
+func windowedCount(name string) *Composite {
+	return NewComposite(name, func(ctx context.Context, in Node) Node {
+		ts := in.Out().Connect(ctx, AssignTimestamp(func(v values.Value) (values.Timestamp, values.Timestamp) {
+			ts := values.Timestamp(v.Int())
+			return ts, ts
+		}).SetName("timestamps"))
+		keyed := ts.Out().KeyBy(FnKeySelector(func(v values.Value) values.Key {
+			return values.Key(v.Int() % 2)
+		}))
+		return keyed.Window(4, 4).Aggregate(ctx, Count())
+	})
+}
+
+func TestComposite(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	var got []string
+	counts := windowedCount("counts")
+	newSliceSource(ints(0, 1, 2, 3, 5, 8, 9)...).Out().
+		Connect(ctx, counts).Out().
+		Sink(ctx, func(v values.Value) error {
+			ts, _, _ := values.GetTime(v)
+			got = append(got, fmt.Sprintf("%v@%d", v, ts))
+			return nil
+		})
+
+	wantGraph := `counts/input -> counts/timestamps
+counts/timestamps -> counts/window-count
+counts/window-count -> sink
+source -> counts/input
+`
+	if diff := cmp.Diff(wantGraph, GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Windows [4, 8) close with 8 and 9, the last ones do not close.
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"1@7", "2@3", "2@3"}, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestComposite_Keyed(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// counter counts values by the key of its input, with 2 instances.
+	counter := NewComposite("counter", func(ctx context.Context, in Node) Node {
+		return in.Out().Connect(ctx, NewStatefulNode(values.New(0),
+			func(state values.Value, collector Collector, v values.Value) (values.Value, error) {
+				count := state.Int() + 1
+				collector.Collect(values.New(count))
+				return values.New(count), nil
+			}).SetName("count"))
+	})
+	counter.SetParallelism(2)
+	ctx := Context()
+	sink, log := NewLogSink(values.Int)
+	newSliceSource(ints(1, 2, 3, 4)...).Out().
+		KeyBy(FnKeySelector(func(v values.Value) values.Key {
+			return values.Key(v.Int() % 2)
+		})).
+		Connect(ctx, counter).Out().
+		Connect(ctx, sink.SetName("sink"))
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1", "1", "2", "2"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestComposite_Inputs(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	double := NewComposite("double", func(ctx context.Context, in Node) Node {
+		return in.Out().Map(ctx, func(v values.Value) (values.Value, error) {
+			return values.New(v.Int() * 2), nil
+		})
+	})
+	sink, log := NewLogSink(values.Int)
+	newSliceSource(ints(1, 2)...).SetName("a").Out().Connect(ctx, double)
+	newSliceSource(ints(3)...).SetName("b").Out().Connect(ctx, double).Out().Connect(ctx, sink.SetName("sink"))
+
+	// The fragment is expanded once, and fed by both sources.
+	want := `a -> double/input
+b -> double/input
+double/input -> double/map
+double/map -> sink
+`
+	if diff := cmp.Diff(want, GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"2", "4", "6"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+
+	t.Run("other graph", func(t *testing.T) {
+		other := Context()
+		if double.Output(other) != nil {
+			t.Fatal("composite should not be expanded in another graph")
+		}
+		newSliceSource(ints(1)...).Out().Connect(other, double)
+		if double.Output(other) == double.Output(ctx) {
+			t.Error("composite should be expanded again in another graph")
+		}
+	})
+}
+
+func TestComposite_Nested(t *testing.T) {
+	ctx := Context()
+	inner := NewComposite("inner", func(ctx context.Context, in Node) Node {
+		return in.Out().Filter(ctx, func(v values.Value) bool {
+			return v.Int() > 0
+		})
+	})
+	outer := NewComposite("outer", func(ctx context.Context, in Node) Node {
+		return in.Out().Connect(ctx, inner)
+	}).SetErrorPolicy(SkipOnError).SetParallelism(2)
+	newSliceSource(ints(1)...).Out().Connect(ctx, outer).Out().Sink(ctx, func(values.Value) error {
+		return nil
+	})
+	want := `outer/inner/filter -> sink
+outer/inner/input -> outer/inner/filter
+outer/input -> outer/inner/input
+source -> outer/input
+`
+	g := GetGraph(ctx)
+	if diff := cmp.Diff(want, g.String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+	// Options of the outer Composite apply to the nodes of the inner one.
+	for _, n := range g.Nodes() {
+		par, ep := 2, SkipOnError
+		if n.GetName() == "source" || n.GetName() == "sink" {
+			par, ep = 1, FailOnError
+		}
+		if n.GetParallelism() != par || GetErrorPolicy(n) != ep {
+			t.Errorf("node %v: unexpected parallelism %d and error policy %v", n, n.GetParallelism(), GetErrorPolicy(n))
+		}
+	}
+}
+
+func TestComposite_NotConnected(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || r != `composite "lonely" is not connected` {
+			t.Errorf("unexpected panic: %v", r)
+		}
+	}()
+	NewComposite("lonely", func(ctx context.Context, in Node) Node {
+		return in
+	}).Out()
+}
@@ -3,6 +3,7 @@ package ssp
 import (
 	"sync/atomic"
 
+	"github.com/affo/ssp/metrics"
 	"github.com/affo/ssp/values"
 )
 
@@ -46,18 +47,37 @@ type infiniteStream struct {
 	s          chan values.Value
 	bufferSize int
 	closed     int64
+	// done unblocks producers and consumers when the job gets canceled.
+	done <-chan struct{}
+	// buffered, if set, reports the number of values in the buffer.
+	buffered metrics.Gauge
 }
 
 func NewInfiniteStream() *infiniteStream {
+	return newInfiniteStream(nil)
+}
+
+func newInfiniteStream(done <-chan struct{}) *infiniteStream {
 	is := &infiniteStream{
 		bufferSize: defaultBufferSize,
+		done:       done,
 	}
 	is.s = make(chan values.Value, is.bufferSize)
 	return is
 }
 
 func (s *infiniteStream) Collect(v values.Value) {
-	s.s <- v
+	select {
+	case s.s <- v:
+		s.report()
+	case <-s.done:
+	}
+}
+
+func (s *infiniteStream) report() {
+	if s.buffered != nil {
+		s.buffered.Set(float64(len(s.s)))
+	}
 }
 
 func (s *infiniteStream) isClosed() bool {
@@ -73,10 +93,21 @@ func (s *infiniteStream) Next() values.Value {
 	if s.isClosed() {
 		return nil
 	}
-	v := <-s.s
-	if v.Type() == values.Close {
-		s.close()
+	// Give priority to cancellation.
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	select {
+	case v := <-s.s:
+		s.report()
+		if v.Type() == values.Close {
+			s.close()
+			return nil
+		}
+		return v
+	case <-s.done:
 		return nil
 	}
-	return v
 }
@@ -0,0 +1,67 @@
+package ssp
+
+import (
+	"fmt"
+
+	"github.com/affo/ssp/values"
+)
+
+// ErrorPolicy determines what happens when a Node fails to process a record.
+type ErrorPolicy int
+
+const (
+	// FailOnError fails the job.
+	FailOnError ErrorPolicy = iota
+	// SkipOnError logs the error and skips the record.
+	SkipOnError
+	// DeadLetterOnError sends the record to the dead-letter stream of the Node, that must be connected.
+	DeadLetterOnError
+)
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case FailOnError:
+		return "fail"
+	case SkipOnError:
+		return "skip"
+	case DeadLetterOnError:
+		return "dead-letter"
+	default:
+		return fmt.Sprintf("ErrorPolicy(%d)", int(p))
+	}
+}
+
+// DeadLetter is a record that made a Node fail.
+// Dead letters travel as object values, timestamped as the offending record, if it was.
+type DeadLetter struct {
+	Node      string
+	Key       values.Key
+	Source    values.Source
+	Timestamp values.Timestamp
+	Value     values.Value
+	Err       error
+}
+
+func newDeadLetter(node string, v values.Value, err error) values.Value {
+	dl := DeadLetter{
+		Node:  node,
+		Value: v,
+		Err:   err,
+	}
+	dl.Key, _ = values.GetKey(v)
+	dl.Source, _ = values.GetSource(v)
+	ts, wm, terr := values.GetTime(v)
+	if terr != nil {
+		return values.New(dl)
+	}
+	dl.Timestamp = ts
+	return values.SetTime(ts, wm, values.New(dl))
+}
+
+func (dl DeadLetter) String() string {
+	return fmt.Sprintf("dead letter from %q: %v (%v)", dl.Node, dl.Value, dl.Err)
+}
+
+// DeadLetterTag is the output tag of dead letters.
+// It is reserved: only the engine collects to it, see CollectTo.
+const DeadLetterTag = "dead-letters"
@@ -0,0 +1,160 @@
+package ssp
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestErrorPolicy(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	setup := func(p ErrorPolicy) (context.Context, Node, *values.List) {
+		ctx := Context()
+		parser := NewNode(func(collector Collector, v values.Value) error {
+			i, err := strconv.Atoi(v.String())
+			if err != nil {
+				return err
+			}
+			collector.Collect(values.New(i))
+			return nil
+		}).SetErrorPolicy(p).SetName("parser")
+		sink, log := NewLogSink(values.Int)
+		NewNode(func(collector Collector, v values.Value) error {
+			for _, s := range []string{"1", "2", "three", "4", "five"} {
+				collector.Collect(values.New(s))
+			}
+			return nil
+		}).SetName("source").
+			Out().
+			Connect(ctx, parser).
+			Out().
+			Connect(ctx, sink.SetName("sink"))
+		return ctx, parser, log
+	}
+
+	getInts := func(l *values.List) []int {
+		var got []int
+		for _, v := range l.GetValues() {
+			got = append(got, v.Int())
+		}
+		return got
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx, _, _ := setup(FailOnError)
+		if err := Execute(ctx); err == nil {
+			t.Fatal("expected error got none")
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx, _, log := setup(SkipOnError)
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]int{1, 2, 4}, getInts(log)); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("dead letter", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx, parser, log := setup(DeadLetterOnError)
+		dlqSink, dlq := NewLogSink(values.Object)
+		DeadLetters(parser).Connect(ctx, dlqSink.SetName("dlq"))
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]int{1, 2, 4}, getInts(log)); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+		var got []string
+		for _, v := range dlq.GetValues() {
+			dl := v.Get().(DeadLetter)
+			if dl.Node != "parser" {
+				t.Errorf("unexpected node in dead letter: %s", dl.Node)
+			}
+			if !strings.Contains(dl.Err.Error(), "invalid syntax") {
+				t.Errorf("unexpected error in dead letter: %v", dl.Err)
+			}
+			got = append(got, dl.Value.String())
+		}
+		if diff := cmp.Diff([]string{"three", "five"}, got); diff != "" {
+			t.Errorf("unexpected dead letters -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("reserved tag", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx := Context()
+		forger := NewNode(func(collector Collector, v values.Value) error {
+			CollectTo(collector, DeadLetterTag, v)
+			return nil
+		}).SetErrorPolicy(DeadLetterOnError).SetName("forger")
+		newSliceSource(values.New("1")).Out().Connect(ctx, forger)
+		dlqSink, dlq := NewLogSink(values.Object)
+		DeadLetters(forger).Connect(ctx, dlqSink.SetName("dlq"))
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// Only the record that made the node fail gets there, as a DeadLetter.
+		if len(dlq.GetValues()) != 1 {
+			t.Fatalf("unexpected dead letters: %v", dlq.GetValues())
+		}
+		dl := dlq.GetValues()[0].Get().(DeadLetter)
+		if !strings.Contains(dl.Err.Error(), `cannot collect to tag "dead-letters"`) {
+			t.Errorf("unexpected error in dead letter: %v", dl.Err)
+		}
+	})
+
+	t.Run("dead letter not connected", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx, _, log := setup(DeadLetterOnError)
+		err := Execute(ctx)
+		if err == nil || !strings.Contains(err.Error(), `node "parser": error policy is dead-letter, but the dead-letter stream is not connected`) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if got := getInts(log); len(got) != 0 {
+			t.Errorf("unexpected result: %v", got)
+		}
+	})
+}
+
+func TestNewDeadLetter(t *testing.T) {
+	v := values.SetKey(3, values.SetSource(1, values.SetTime(10, 5, values.New("foo"))))
+	dlv := newDeadLetter("node", v, nil)
+	dl := dlv.Get().(DeadLetter)
+	if diff := cmp.Diff(DeadLetter{
+		Node:      "node",
+		Key:       3,
+		Source:    1,
+		Timestamp: 10,
+	}, DeadLetter{
+		Node:      dl.Node,
+		Key:       dl.Key,
+		Source:    dl.Source,
+		Timestamp: dl.Timestamp,
+	}); diff != "" {
+		t.Errorf("unexpected dead letter -want/+got:\n\t%s", diff)
+	}
+	if ts, wm, err := values.GetTime(dlv); ts != 10 || wm != 5 || err != nil {
+		t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", 10, 5, ts, wm, err)
+	}
+}
@@ -0,0 +1,308 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/affo/ssp/values"
+)
+
+// This file provides common transformations on Arches.
+// Each transformation connects a new node, named after the transformation, to the Arch in the graph held by ctx.
+// Names are made unique in the graph with a counter, for example "map", "map-2", "map-3".
+// Transformations return the new node, so that it can be configured, and its output transformed further.
+// Transformations that emit new values keep the timestamps of the values they process, unless new values have their own.
+
+// Map emits the result of f for every value.
+func (a *Arch) Map(ctx context.Context, f func(v values.Value) (values.Value, error)) Node {
+	return a.Connect(ctx, newChainNode(chain{steps: []step{{name: "map", m: f}}}).SetName(uniqueName(ctx, "map")))
+}
+
+// Filter emits the values for which f returns true.
+func (a *Arch) Filter(ctx context.Context, f func(v values.Value) bool) Node {
+	return a.Connect(ctx, newChainNode(chain{steps: []step{{name: "filter", f: f}}}).SetName(uniqueName(ctx, "filter")))
+}
+
+// uniqueName returns name, or name followed by a counter if the Graph in ctx already has a node with that name.
+func uniqueName(ctx context.Context, name string) string {
+	names := make(map[string]bool)
+	for n := range getGraph(ctx).roots {
+		names[n.GetName()] = true
+	}
+	unique := name
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	return unique
+}
+
+// step is a map or a filter in a chain.
+type step struct {
+	name string
+	m    func(v values.Value) (values.Value, error)
+	f    func(v values.Value) bool
+}
+
+// chain applies maps and filters in sequence.
+// Map and Filter produce chains of one step, that the planner can fuse together.
+type chain struct {
+	steps []step
+	// keepKeys makes the chain emit values with the key of their input.
+	keepKeys bool
+}
+
+func newChainNode(c chain) *AnonymousNode {
+	n := NewNode(func(collector Collector, v values.Value) error {
+		out, ok, err := c.apply(v)
+		if err != nil || !ok {
+			return err
+		}
+		collector.Collect(out)
+		return nil
+	}).SetTimestamps(KeepsTimestamps)
+	n.chain = &c
+	return n
+}
+
+// apply returns the value to emit for v, if any.
+func (c chain) apply(v values.Value) (values.Value, bool, error) {
+	out := v
+	for _, s := range c.steps {
+		if s.f != nil {
+			if !s.f(out) {
+				return nil, false, nil
+			}
+			continue
+		}
+		res, err := s.m(out)
+		if err != nil {
+			return nil, false, err
+		}
+		out = output(out, res)
+	}
+	if out == v {
+		out = forward(v)
+	}
+	if c.keepKeys {
+		if k, err := values.GetKey(v); err == nil {
+			out = values.SetKey(k, out)
+		}
+	}
+	return out, true, nil
+}
+
+// filtersOnly returns true if the chain does not change values.
+func (c chain) filtersOnly() bool {
+	for _, s := range c.steps {
+		if s.f == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// FlatMap emits every value returned by f.
+func (a *Arch) FlatMap(ctx context.Context, f func(v values.Value) ([]values.Value, error)) Node {
+	return a.Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+		outs, err := f(v)
+		if err != nil {
+			return err
+		}
+		for _, out := range outs {
+			collector.Collect(output(v, out))
+		}
+		return nil
+	}).SetTimestamps(KeepsTimestamps).SetName(uniqueName(ctx, "flatmap")))
+}
+
+// Reduce combines values with f, and emits the result at every value.
+// The first value is emitted as it is.
+// If the Arch is keyed, values are reduced per key.
+func (a *Arch) Reduce(ctx context.Context, f func(acc, v values.Value) (values.Value, error)) Node {
+	// A null Object means that no value has been reduced yet.
+	return a.Connect(ctx, NewStatefulNode(values.NewNull(values.Object),
+		func(state values.Value, collector Collector, v values.Value) (values.Value, error) {
+			acc := undecorated(v)
+			if state.Type() != values.Object || state.Get() != nil {
+				var err error
+				if acc, err = f(state, acc); err != nil {
+					return nil, err
+				}
+				acc = undecorated(acc)
+			}
+			collector.Collect(withTimeOf(v, acc))
+			return acc, nil
+		}).SetTimestamps(KeepsTimestamps).SetName(uniqueName(ctx, "reduce")))
+}
+
+// Aggregate emits the result of agg at every value.
+// If the Arch is keyed, values are aggregated per key.
+func (a *Arch) Aggregate(ctx context.Context, agg Aggregator) Node {
+	n := NewAggregateNode(agg)
+	return a.Connect(ctx, n.SetName(uniqueName(ctx, n.GetName())))
+}
+
+// NewAggregateNode creates the node used by Arch.Aggregate.
+func NewAggregateNode(agg Aggregator) Node {
+	return NewStatefulNode(agg.Init(),
+		func(state values.Value, collector Collector, v values.Value) (values.Value, error) {
+			acc, err := agg.Add(state, undecorated(v))
+			if err != nil {
+				return nil, err
+			}
+			res, err := agg.Result(acc)
+			if err != nil {
+				return nil, err
+			}
+			collector.Collect(withTimeOf(v, res))
+			return acc, nil
+		}).SetTimestamps(KeepsTimestamps).SetName(agg.Name())
+}
+
+// Distinct emits values that have not been seen before, according to values.Equal.
+// If the Arch is keyed, values are de-duplicated per key.
+func (a *Arch) Distinct(ctx context.Context) Node {
+	// The set of seen values is created at the first value,
+	// because the initial state is shared by every instance of the node.
+	return a.Connect(ctx, NewStatefulNode(values.NewNull(values.Object),
+		func(state values.Value, collector Collector, v values.Value) (values.Value, error) {
+			seen, ok := state.Get().(*seenValues)
+			if !ok {
+				seen = newSeenValues()
+				state = values.New(seen)
+			}
+			if seen.add(undecorated(v)) {
+				collector.Collect(forward(v))
+			}
+			return state, nil
+		}).SetTimestamps(KeepsTimestamps).SetName(uniqueName(ctx, "distinct")))
+}
+
+// seenValues is a set of values of any type.
+type seenValues struct {
+	vs map[uint64][]values.Value
+}
+
+func newSeenValues() *seenValues {
+	return &seenValues{vs: make(map[uint64][]values.Value)}
+}
+
+// add returns true if v was not in the set.
+func (s *seenValues) add(v values.Value) bool {
+	h := values.Hash(v)
+	for _, sv := range s.vs[h] {
+		if values.Equal(sv, v) {
+			return false
+		}
+	}
+	s.vs[h] = append(s.vs[h], v)
+	return true
+}
+
+// Union emits the values from this Arch and the others.
+// See Union for the semantics of sources and watermarks.
+func (a *Arch) Union(ctx context.Context, others ...*Arch) Node {
+	return Union(ctx, append([]*Arch{a}, others...))
+}
+
+// WindowedArch is an Arch whose values are grouped in windows.
+type WindowedArch struct {
+	a           *Arch
+	size, slide int
+}
+
+// Window groups values in fixed windows of the given size and slide.
+// Values must be timestamped.
+func (a *Arch) Window(size, slide int) *WindowedArch {
+	return &WindowedArch{a: a, size: size, slide: slide}
+}
+
+// Apply calls f for every window that closes.
+// Values in the window can be accessed with Window.Range.
+func (w *WindowedArch) Apply(ctx context.Context, f WindowCloseFn) Node {
+	return w.a.Connect(ctx, NewWindowedNode(w.size, w.slide, values.NewNull(values.Object),
+		func(w *Window, collector Collector, v values.TimestampedValue) error {
+			w.AddElement(v)
+			return nil
+		}, f).SetName(uniqueName(ctx, "window")))
+}
+
+// Aggregate emits the result of agg for every window that closes.
+// Results are timestamped with the last instant in the window.
+func (w *WindowedArch) Aggregate(ctx context.Context, agg Aggregator) Node {
+	n := NewWindowAggregateNode(w.size, w.slide, agg)
+	return w.a.Connect(ctx, n.SetName(uniqueName(ctx, n.GetName())))
+}
+
+// NewWindowAggregateNode creates the node used by WindowedArch.Aggregate.
+func NewWindowAggregateNode(size, slide int, agg Aggregator) Node {
+	return newWindowAggregateNode(size, slide, agg, agg.Add).SetName("window-" + agg.Name())
+}
+
+// newWindowAggregateNode creates a window that accumulates values with add, and emits the result of agg.
+func newWindowAggregateNode(size, slide int, agg Aggregator, add func(acc, v values.Value) (values.Value, error)) Node {
+	n := NewWindowedNode(size, slide, agg.Init(),
+		func(w *Window, collector Collector, v values.TimestampedValue) error {
+			acc, err := add(w.State, undecorated(v))
+			if err != nil {
+				return err
+			}
+			w.State = acc
+			return nil
+		},
+		func(w *Window, collector Collector) error {
+			res, err := agg.Result(w.State)
+			if err != nil {
+				return err
+			}
+			ts := w.Stop() - 1
+			collector.Collect(values.SetTime(ts, ts, res))
+			return nil
+		})
+	wn := n.(*windowedNode)
+	wn.ts = WithTimestamps
+	wn.agg = agg
+	return n
+}
+
+// Sink consumes values with f.
+func (a *Arch) Sink(ctx context.Context, f func(v values.Value) error) Node {
+	return a.Connect(ctx, NewNode(func(_ Collector, v values.Value) error {
+		return f(v)
+	}).SetName(uniqueName(ctx, "sink")))
+}
+
+// undecorated strips keys, sources and timestamps from v.
+// Values must be stripped before being emitted or stored in state, because the engine decorates them in place.
+func undecorated(v values.Value) values.Value {
+	for {
+		u, err := v.Unwrap()
+		if err != nil {
+			return v
+		}
+		v = u
+	}
+}
+
+// forward strips keys and sources from v, but keeps its timestamp.
+func forward(v values.Value) values.Value {
+	return withTimeOf(v, undecorated(v))
+}
+
+// output prepares a value computed from in for being emitted.
+// It keeps the timestamp of out, if any, or it uses the timestamp of in.
+func output(in, out values.Value) values.Value {
+	if _, _, err := values.GetTime(out); err == nil {
+		return forward(out)
+	}
+	return withTimeOf(in, undecorated(out))
+}
+
+// withTimeOf timestamps out with the timestamp of in, if any.
+// out must not be decorated.
+func withTimeOf(in, out values.Value) values.Value {
+	if ts, wm, err := values.GetTime(in); err == nil {
+		return values.SetTime(ts, wm, out)
+	}
+	return out
+}
@@ -0,0 +1,265 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newSliceSource(vs ...values.Value) Node {
+	return NewNode(func(collector Collector, _ values.Value) error {
+		for _, v := range vs {
+			collector.Collect(v)
+		}
+		return nil
+	}).SetName("source")
+}
+
+func ints(is ...int) []values.Value {
+	vs := make([]values.Value, len(is))
+	for i, v := range is {
+		vs[i] = values.New(v)
+	}
+	return vs
+}
+
+func sortedStrings(vs []values.Value) []string {
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = v.String()
+	}
+	sort.Strings(ss)
+	return ss
+}
+
+func TestDSL(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		build func(ctx context.Context, in *Arch) *Arch
+		in    []values.Value
+		want  []string
+	}{
+		{
+			name: "map",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Map(ctx, func(v values.Value) (values.Value, error) {
+					return values.New(v.Int() * 10), nil
+				}).Out()
+			},
+			in:   ints(1, 2, 3),
+			want: []string{"10", "20", "30"},
+		},
+		{
+			name: "filter",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Filter(ctx, func(v values.Value) bool {
+					return v.Int()%2 == 0
+				}).Out()
+			},
+			in:   ints(1, 2, 3, 4),
+			want: []string{"2", "4"},
+		},
+		{
+			name: "flatmap",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.FlatMap(ctx, func(v values.Value) ([]values.Value, error) {
+					var vs []values.Value
+					for _, w := range strings.Fields(v.String()) {
+						vs = append(vs, values.New(w))
+					}
+					return vs, nil
+				}).Out()
+			},
+			in:   []values.Value{values.New("a b"), values.New("c")},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "reduce",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.KeyBy(NewStringValueKeySelector(func(v values.Value) string {
+					return v.String()
+				})).Reduce(ctx, func(acc, v values.Value) (values.Value, error) {
+					return values.New(acc.String() + v.String()), nil
+				}).SetParallelism(2).Out()
+			},
+			in:   []values.Value{values.New("a"), values.New("b"), values.New("a")},
+			want: []string{"a", "aa", "b"},
+		},
+		{
+			name: "sum",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Aggregate(ctx, Sum()).Out()
+			},
+			in:   ints(1, 2, 3),
+			want: []string{"1", "3", "6"},
+		},
+		{
+			name: "count",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Aggregate(ctx, Count()).Out()
+			},
+			in:   []values.Value{values.New("a"), values.New(1.5)},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "min",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Aggregate(ctx, Min()).Out()
+			},
+			in:   []values.Value{values.New(3), values.New(1.5), values.New(int8(2))},
+			want: []string{"1.5", "1.5", "3"},
+		},
+		{
+			name: "max",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Aggregate(ctx, Max()).Out()
+			},
+			in:   []values.Value{values.New(3), values.New(1.5), values.New(int8(5))},
+			want: []string{"3", "3", "5"},
+		},
+		{
+			name: "avg",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Aggregate(ctx, Avg()).Out()
+			},
+			in:   []values.Value{values.New(1), values.NewNull(values.Int), values.New(2)},
+			want: []string{"1", "1", "1.5"},
+		},
+		{
+			name: "distinct",
+			build: func(ctx context.Context, in *Arch) *Arch {
+				return in.Distinct(ctx).Out()
+			},
+			in:   ints(1, 2, 1, 3, 2),
+			want: []string{"1", "2", "3"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer leaktest.Check(t)()
+
+			ctx := Context()
+			out := tc.build(ctx, newSliceSource(tc.in...).Out())
+			var got []values.Value
+			out.Sink(ctx, func(v values.Value) error {
+				got = append(got, v)
+				return nil
+			})
+			if err := Execute(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, sortedStrings(got)); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
+
+func TestDSL_Union(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	a := newSliceSource(ints(1, 2)...).SetName("a").Out()
+	b := newSliceSource(ints(3)...).SetName("b").Out()
+	c := newSliceSource(ints(4)...).SetName("c").Out()
+	sink, log := NewLogSink(values.Int)
+	a.Union(ctx, b, c).Out().Connect(ctx, sink)
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1", "2", "3", "4"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestDSL_Window(t *testing.T) {
+	in := []values.Value{
+		values.SetTime(0, 0, values.New(1)),
+		values.SetTime(1, 0, values.New(2)),
+		values.SetTime(3, 2, values.New(3)),
+		values.SetTime(4, 3, values.New(4)),
+		// Closes every window.
+		values.SetTime(10, 10, values.New(5)),
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx := Context()
+		sink, log := NewLogSink(values.String)
+		newSliceSource(in...).Out().
+			Window(3, 3).
+			Apply(ctx, func(w *Window, collector Collector) error {
+				var vs []string
+				_ = w.Range(func(v values.TimestampedValue) error {
+					vs = append(vs, v.String())
+					return nil
+				})
+				collector.Collect(values.New(fmt.Sprintf("%v: %v", w, vs)))
+				return nil
+			}).
+			Out().
+			Connect(ctx, sink)
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"[0, 3): [1 2]", "[3, 6): [3 4]"}
+		if diff := cmp.Diff(want, sortedStrings(log.GetValues())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx := Context()
+		var got []string
+		newSliceSource(in...).Out().
+			Window(3, 3).
+			Aggregate(ctx, Sum()).
+			Out().
+			Sink(ctx, func(v values.Value) error {
+				ts, _, err := values.GetTime(v)
+				got = append(got, fmt.Sprintf("%v@%d", v, ts))
+				return err
+			})
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"3@2", "7@5"}, got); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
+
+func TestDSL_NodeNames(t *testing.T) {
+	ctx := Context()
+	newSliceSource().Out().
+		Map(ctx, func(v values.Value) (values.Value, error) { return v, nil }).Out().
+		Filter(ctx, func(v values.Value) bool { return true }).Out().
+		Aggregate(ctx, Count()).Out().
+		Sink(ctx, func(v values.Value) error { return nil })
+	want := "count -> sink\nfilter -> count\nmap -> filter\nsource -> map\n"
+	if diff := cmp.Diff(want, GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestDSL_UniqueNodeNames(t *testing.T) {
+	ctx := Context()
+	m := newSliceSource().Out().
+		Map(ctx, func(v values.Value) (values.Value, error) { return v, nil }).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) { return v, nil }).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) { return v, nil })
+	m.Out().Sink(ctx, func(v values.Value) error { return nil })
+	m.Out().Sink(ctx, func(v values.Value) error { return nil })
+	want := "map -> map-2\nmap-2 -> map-3\nmap-3 -> sink\nmap-3 -> sink-2\nsource -> map\n"
+	if diff := cmp.Diff(want, GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+}
@@ -3,31 +3,157 @@ package ssp
 import (
 	"context"
 	"fmt"
+	"log"
+	"math"
 	"reflect"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/affo/ssp/metrics"
 	"github.com/affo/ssp/values"
 )
 
 func Execute(ctx context.Context) error {
-	e := &Engine{}
-	return e.Execute(ctx)
+	return NewEngine().Execute(ctx)
+}
+
+type Engine struct {
+	rs      RestartStrategy
+	scope   FailoverScope
+	planner *Planner
+	metrics metrics.Registry
+	bytes   bool
+	warn    func(warnings Errors)
+}
+
+type EngineOption func(e *Engine)
+
+// WithFailover makes the engine restart the given scope on failure, according to rs.
+func WithFailover(scope FailoverScope, rs RestartStrategy) EngineOption {
+	return func(e *Engine) {
+		e.rs = rs
+		e.scope = scope
+	}
+}
+
+// WithPlanner makes the engine execute the physical plan produced by p, instead of the graph as it is.
+func WithPlanner(p *Planner) EngineOption {
+	return func(e *Engine) {
+		e.planner = p
+	}
+}
+
+// WithMetrics makes the engine report the metrics of every operator to r.
+// Metrics are labeled with the name of the node and the index of the partition.
+// Unnamed nodes are labeled with their position in the graph, as "#1", "#2", and so on.
+func WithMetrics(r metrics.Registry) EngineOption {
+	return func(e *Engine) {
+		e.metrics = r
+	}
+}
+
+// WithByteMetrics makes the engine also report the encoded size of the records entering and leaving every operator.
+// It is expensive, because every record gets encoded twice per operator.
+func WithByteMetrics() EngineOption {
+	return func(e *Engine) {
+		e.bytes = true
+	}
+}
+
+// WithWarnings makes the engine pass to f the warnings found by Validate before executing, if any.
+// Without it, warnings are discarded.
+func WithWarnings(f func(warnings Errors)) EngineOption {
+	return func(e *Engine) {
+		e.warn = f
+	}
 }
 
-type Engine struct{}
+func NewEngine(opts ...EngineOption) *Engine {
+	e := &Engine{}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
 
 func (e *Engine) Execute(ctx context.Context) error {
 	g := GetGraph(ctx)
+	errs, warns := Validate(g)
+	if len(errs) > 0 {
+		return errs
+	}
+	if len(warns) > 0 && e.warn != nil {
+		e.warn(warns)
+	}
+	release, errs := acquireSources(g)
+	if len(errs) > 0 {
+		return errs
+	}
+	defer release()
+	if e.planner != nil {
+		g = e.planner.Plan(g).Physical
+	}
+	if e.rs == nil || e.scope != FailoverJob {
+		return e.execute(ctx, g)
+	}
+	t := newRestartTracker(e.rs)
+	for {
+		err := e.execute(ctx, g)
+		if err == nil {
+			return nil
+		}
+		d, ok := t.fail()
+		if !ok {
+			return err
+		}
+		if !wait(d, ctx.Done()) {
+			return err
+		}
+	}
+}
+
+// execute runs g until completion, or until ctx gets canceled.
+func (e *Engine) execute(ctx context.Context, g Graph) error {
+	f := newFailureCoordinator()
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.fail(ctx.Err())
+		case <-finished:
+		}
+	}()
+	opts := []OperatorOption{withFailureCoordinator(f)}
+	if e.rs != nil && e.scope == FailoverRegion {
+		opts = append(opts, WithRestartStrategy(e.rs))
+	}
+	var labels map[Node]string
+	if e.metrics != nil {
+		labels = nodeLabels(g)
+	}
+	loops := findLoops(g)
+	nodeOpts := func(n Node) []OperatorOption {
+		nopts := opts[:len(opts):len(opts)]
+		if l, ok := loops[n]; ok {
+			nopts = append(nopts, withLoop(l))
+		}
+		if e.metrics != nil {
+			nopts = append(nopts, withMetrics(e.metrics, labels[n], e.bytes))
+		}
+		return nopts
+	}
 	ops := make(map[Node]*ParallelOperator)
-	ins := make(map[Node][]Node)
+	ins := make(map[Node][]*Arch)
 	Walk(g, func(a *Arch) {
 		if from := a.From(); from != nil {
 			if _, ok := ops[from]; !ok {
 				par := from.GetParallelism()
 				ops[from] = NewParallelOperator(par, func() *Operator {
 					return NewOperator(from)
-				})
+				}, nodeOpts(from)...)
 			}
 		}
 		if to := a.To(); to != nil {
@@ -35,57 +161,100 @@ func (e *Engine) Execute(ctx context.Context) error {
 				par := to.GetParallelism()
 				ops[to] = NewParallelOperator(par, func() *Operator {
 					return NewOperator(to)
-				}, WithInKeySelector(a.ks))
+				}, append(nodeOpts(to), WithInKeySelector(a.ks))...)
 			}
 		}
 		if from, to := a.From(), a.To(); from != nil && to != nil {
 			if _, ok := ins[to]; !ok {
-				ins[to] = make([]Node, 0, 1)
+				ins[to] = make([]*Arch, 0, 1)
 			}
-			ins[to] = append(ins[to], from)
+			ins[to] = append(ins[to], a)
 		}
 	})
 
 	outs := make(map[Node][]Collector)
+	tagged := make(map[Node]map[string][]Collector)
 	for n, in := range ins {
+		u, isUnion := n.(*unionNode)
+		if isUnion {
+			in = u.sortInputs(in)
+		}
+		h, isHead := n.(*iterationHead)
+		nInputs := len(in)
+		if isHead {
+			in, nInputs = h.it.sortInputs(in)
+		}
+		l := loops[n]
 		inss := make([]*infiniteStream, 0, len(in))
 		to := ops[n]
-		for _, from := range in {
-			is := NewInfiniteStream()
+		for i, a := range in {
+			is := newInfiniteStream(f.Done())
 			inss = append(inss, is)
-			if _, ok := outs[from]; !ok {
-				outs[from] = make([]Collector, 0, 1)
+			var c Collector = is
+			if i >= nInputs {
+				c = newFeedbackCollector(is, f.Done())
+			}
+			if l != nil {
+				c = l.collector(c)
 			}
-			outs[from] = append(outs[from], is)
+			if a.tag == "" {
+				outs[a.From()] = append(outs[a.From()], c)
+				continue
+			}
+			if _, ok := tagged[a.From()]; !ok {
+				tagged[a.From()] = make(map[string][]Collector)
+			}
+			tagged[a.From()][a.tag] = append(tagged[a.From()][a.tag], c)
 		}
 
-		to.In(newWatermarker(newDataStreams(inss...), len(inss)), func() Transport {
-			return NewInfiniteStream()
+		ds := newDataStreams(inss...).withDone(f.Done())
+		if isHead {
+			ds = ds.withLoop(l, nInputs)
+		}
+		wmk := newWatermarker(ds, len(inss))
+		if isUnion {
+			wmk = wmk.aligned()
+		}
+		to.In(wmk, func() Transport {
+			return newInfiniteStream(f.Done())
 		})
 	}
 
 	for n, out := range outs {
 		ops[n].Out(out)
 	}
+	for n, tcs := range tagged {
+		for tag, cs := range tcs {
+			ops[n].OutTag(tag, cs)
+		}
+	}
 
 	for _, op := range ops {
 		op.Open()
 	}
-	var werr error
 	for _, op := range ops {
+		// Errors are collected by the failure coordinator in the order they happen,
+		// the ones it missed are added at the end.
 		if err := op.Close(); err != nil {
-			werr = fmt.Errorf("error on operator close: %w", err)
+			for _, err := range err.(Errors) {
+				f.add(err)
+			}
 		}
 	}
-	return werr
+	return f.Err()
 }
 
 // dataStreams joins multiple streams from different sources offering a DataStream.
 // It manages tagging records with a values.Source.
 type dataStreams struct {
-	ss    []*infiniteStream
-	cases []reflect.SelectCase
-	n     int64
+	ss     []*infiniteStream
+	cases  []reflect.SelectCase
+	n      int64
+	last   values.Source
+	closed []bool
+	// Streams from nInputs on are the feedback of loop.
+	loop    *loop
+	nInputs int
 }
 
 func newDataStreams(ss ...*infiniteStream) *dataStreams {
@@ -97,37 +266,92 @@ func newDataStreams(ss ...*infiniteStream) *dataStreams {
 		}
 	}
 	return &dataStreams{
-		ss:    ss,
-		cases: cases,
-		n:     int64(len(ss)),
+		ss:     ss,
+		cases:  cases,
+		n:      int64(len(ss)),
+		closed: make([]bool, len(ss)),
 	}
 }
 
+// withDone makes Next return nil as soon as done gets closed.
+func (d *dataStreams) withDone(done <-chan struct{}) *dataStreams {
+	d.cases = append(d.cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(done),
+	})
+	return d
+}
+
+// withLoop makes d the input of the Head of l.
+// Streams from nInputs on are feedback streams, and Next returns nil as soon as the loop terminates.
+func (d *dataStreams) withLoop(l *loop, nInputs int) *dataStreams {
+	d.loop = l
+	d.nInputs = nInputs
+	l.setInputs(nInputs)
+	d.cases = append(d.cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(l.Done()),
+	})
+	return d
+}
+
 func (d *dataStreams) Next() values.Value {
 	if n := atomic.LoadInt64(&d.n); n == 0 {
 		return nil
 	}
 	i, value, ok := reflect.Select(d.cases)
+	if i >= len(d.ss) {
+		// The job has been canceled, or the loop terminated.
+		return nil
+	}
 	// !ok means the channel has been closed.
 	if !ok {
 		panic("unexpected close")
 	}
 	v := value.Interface().(values.Value)
 	if v.Type() == values.Close {
+		d.closed[i] = true
 		atomic.AddInt64(&d.n, -1)
+		if d.loop != nil && i < d.nInputs {
+			d.loop.inputClosed()
+		}
 		return d.Next()
 	}
-	return values.SetSource(values.Source(i), v)
+	d.last = values.Source(i)
+	return values.SetSource(d.last, v)
+}
+
+// current returns the index of the stream that provided the last record.
+func (d *dataStreams) current() values.Source {
+	return d.last
+}
+
+// isClosed returns true if the stream with the given index has been closed.
+func (d *dataStreams) isClosed(s values.Source) bool {
+	return d.closed[s]
 }
 
+// inputs is implemented by DataStreams that join multiple inputs.
+type inputs interface {
+	current() values.Source
+	isClosed(s values.Source) bool
+}
+
+// noWatermark is the watermark of aligned watermarkers that are waiting for some input.
+const noWatermark = values.Timestamp(math.MinInt64)
+
 // watermarker sets correct watermarks on timestamped values based on their source.
 // Resulting values (obtained via Next()) will have a monotonically increasing watermark equal to
 // the minimum of the watermark of the sources.
 // This makes time flow in one direction, and ensures that all sources agree on time passing.
+// If aligned, the watermark does not advance until every source sends a timestamped value,
+// and closed sources stop holding it back.
 type watermarker struct {
 	DataStream
 	nSources int
 	wms      map[values.Source]values.Timestamp
+	align    bool
+	wm       values.Timestamp
 }
 
 func newWatermarker(dataStream DataStream, nSources int) *watermarker {
@@ -135,9 +359,17 @@ func newWatermarker(dataStream DataStream, nSources int) *watermarker {
 		DataStream: dataStream,
 		nSources:   nSources,
 		wms:        make(map[values.Source]values.Timestamp, nSources),
+		wm:         noWatermark,
 	}
 }
 
+// aligned makes the watermarker wait for every source.
+// The DataStream must implement inputs.
+func (w *watermarker) aligned() *watermarker {
+	w.align = true
+	return w
+}
+
 // handleTimestamp replaces the watermark with the minimum watermark received for each source.
 // It also makes watermarks monotonically increasing for every record that passes in.
 func (w *watermarker) handleTimestamp(tsv values.TimestampedValue, source values.Source) values.Value {
@@ -147,22 +379,51 @@ func (w *watermarker) handleTimestamp(tsv values.TimestampedValue, source values
 		w.wms[source] = wm
 	}
 	minWm := wm
-	for _, wm := range w.wms {
-		if wm < minWm {
-			minWm = wm
+	if w.align {
+		minWm = w.alignedWatermark(minWm)
+	} else {
+		for _, wm := range w.wms {
+			if wm < minWm {
+				minWm = wm
+			}
 		}
 	}
 	return values.SetTime(tsv.Timestamp(), minWm, tsv)
 }
 
+// alignedWatermark returns the minimum watermark of the open sources, starting from wm.
+// If some open source has not sent a watermark yet, the last watermark is kept.
+func (w *watermarker) alignedWatermark(wm values.Timestamp) values.Timestamp {
+	ins := w.DataStream.(inputs)
+	for i := 0; i < w.nSources; i++ {
+		s := values.Source(i)
+		if ins.isClosed(s) {
+			continue
+		}
+		swm, ok := w.wms[s]
+		if !ok {
+			return w.wm
+		}
+		if swm < wm {
+			wm = swm
+		}
+	}
+	if wm > w.wm {
+		w.wm = wm
+	}
+	return w.wm
+}
+
 func (w *watermarker) Next() values.Value {
 	v := w.DataStream.Next()
 	if v == nil {
 		return nil
 	}
-	s, err := values.GetSource(v)
-	if err != nil {
-		s = values.Source(0)
+	var s values.Source
+	if ins, ok := w.DataStream.(inputs); ok {
+		s = ins.current()
+	} else if src, err := values.GetSource(v); err == nil {
+		s = src
 	}
 	if tsv, err := values.GetTimestampedValue(v); err == nil {
 		v = w.handleTimestamp(tsv, s)
@@ -211,10 +472,17 @@ func (s broadcastCollector) Collect(v values.Value) {
 }
 
 type Operator struct {
-	bn  Node
-	ns  map[values.Key]Node
-	in  DataStream
-	out Collector
+	bn     Node
+	ns     map[values.Key]Node
+	in     DataStream
+	out    Collector
+	tagged map[string]Collector
+
+	partition int
+	failures  *failureCoordinator
+	restarts  *restartTracker
+	loop      *loop
+	metrics   *operatorMetrics
 
 	wg  sync.WaitGroup
 	err error
@@ -236,43 +504,220 @@ func (o *Operator) Out(c Collector) {
 	o.out = c
 }
 
+// OutTag sets the collector for the output tagged with tag.
+func (o *Operator) OutTag(tag string, c Collector) {
+	if o.tagged == nil {
+		o.tagged = make(map[string]Collector)
+	}
+	o.tagged[tag] = c
+}
+
+func (o *Operator) collector() Collector {
+	var c Collector = outputCollector{
+		out:    o.out,
+		tagged: o.tagged,
+		done:   o.done(),
+	}
+	if o.metrics != nil {
+		c = o.metrics.collector(c)
+	}
+	return c
+}
+
 func (o *Operator) getNode(key values.Key) Node {
 	if _, ok := o.ns[key]; !ok {
-		o.ns[key] = o.bn.Clone()
+		n := o.bn.Clone()
+		o.ns[key] = n
+		if o.metrics != nil {
+			if in, ok := n.(instrumented); ok {
+				in.instrument(o.metrics)
+			}
+			if s, ok := n.(statefulNode); ok && s.isStateful() {
+				o.metrics.keys.Set(float64(len(o.ns)))
+			}
+		}
 	}
 	return o.ns[key]
 }
 
+func (o *Operator) newError(k values.Key, v values.Value, err error) error {
+	return &OperatorError{
+		Node:      o.bn.GetName(),
+		Partition: o.partition,
+		Key:       k,
+		Value:     v,
+		Err:       err,
+	}
+}
+
+// handleError applies the ErrorPolicy of the Node to a record that made it fail.
+func (o *Operator) handleError(k values.Key, v values.Value, err error) error {
+	err = o.newError(k, v, err)
+	switch GetErrorPolicy(o.bn) {
+	case SkipOnError:
+		log.Printf("skipping record %v: %v", v, err)
+		return nil
+	case DeadLetterOnError:
+		dlq, ok := o.tagged[DeadLetterTag]
+		if !ok {
+			// Validation prevents this, fail rather than losing the record.
+			return err
+		}
+		dlq.Collect(newDeadLetter(o.bn.GetName(), v, err))
+		return nil
+	default:
+		return err
+	}
+}
+
+func (o *Operator) done() <-chan struct{} {
+	if o.failures == nil {
+		return nil
+	}
+	return o.failures.Done()
+}
+
+// safeDo makes n process v, converting panics into errors.
+func safeDo(n Node, c Collector, v values.Value) (err error) {
+	defer recoverError(&err)
+	return n.Do(c, v)
+}
+
+// process makes n process v.
+// If a restart strategy is set, a failure restores the state n had before v, and makes n process v again.
+func (o *Operator) process(n Node, c Collector, v values.Value) error {
+	if o.restarts == nil {
+		return safeDo(n, c, v)
+	}
+	cp, _ := n.(checkpointer)
+	for failed := false; ; failed = true {
+		var state interface{}
+		if cp != nil {
+			state = cp.checkpoint(v)
+		}
+		err := safeDo(n, c, v)
+		if err == nil {
+			if failed {
+				o.restarts.succeed()
+			}
+			return nil
+		}
+		if cp != nil {
+			cp.restore(state)
+		}
+		d, ok := o.restarts.fail()
+		if !ok {
+			return err
+		}
+		if !wait(d, o.done()) {
+			// The job has been canceled in the meantime.
+			return nil
+		}
+	}
+}
+
 func (o *Operator) do() error {
+	c := o.collector()
 	// This is a source, the provided value is useless.
+	// Sources are not restarted, as they would emit their values again.
 	if o.in == nil {
-		return o.bn.Do(o.out, values.NewNull(values.Int64))
+		v := values.NewNull(values.Int64)
+		if err := safeDo(o.bn, c, v); err != nil {
+			return o.handleError(0, v, err)
+		}
+		return nil
 	}
 
+	// Values emitted by the loop body carry the iteration of the value being processed.
+	var it int
+	if o.loop != nil {
+		if _, ok := o.bn.(*iterationHead); !ok {
+			c = iterationCollector{c: c, it: o.loop.it, n: &it}
+		}
+	}
 	for {
 		v := o.in.Next()
 		if v == nil {
-			return nil
+			return o.flush(c)
 		}
-		k, err := values.GetKey(v)
-		if err != nil {
-			return err
+		if o.loop != nil {
+			it, _ = iteration(v, o.loop.it)
 		}
-		n := o.getNode(k)
-		if err := n.Do(o.out, v); err != nil {
+		if err := o.processRecord(c, v); err != nil {
 			return err
 		}
 	}
 }
 
+// flusher is implemented by Nodes that buffer values, and emit them when their input ends.
+type flusher interface {
+	flush(collector Collector) error
+}
+
+// flush makes the nodes of o emit the values they buffer, unless the job has been canceled or has failed.
+func (o *Operator) flush(c Collector) error {
+	select {
+	case <-o.done():
+		return nil
+	default:
+	}
+	keys := make([]values.Key, 0, len(o.ns))
+	for k := range o.ns {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		if f, ok := o.ns[k].(flusher); ok {
+			if err := safeFlush(f, c); err != nil {
+				return o.newError(k, nil, err)
+			}
+		}
+	}
+	return nil
+}
+
+// safeFlush makes f flush, converting panics into errors.
+func safeFlush(f flusher, c Collector) (err error) {
+	defer recoverError(&err)
+	return f.flush(c)
+}
+
+func (o *Operator) processRecord(c Collector, v values.Value) error {
+	if o.loop != nil {
+		defer o.loop.processed()
+	}
+	if o.metrics != nil {
+		o.metrics.in(v)
+		defer o.metrics.processed(time.Now())
+	}
+	k, err := values.GetKey(v)
+	if err != nil {
+		return o.newError(k, v, err)
+	}
+	n := o.getNode(k)
+	if err := o.process(n, c, v); err != nil {
+		return o.handleError(k, v, err)
+	}
+	return nil
+}
+
 func (o *Operator) Open() {
 	o.wg.Add(1)
 	go func() {
 		o.err = o.do()
+		if o.err != nil && o.failures != nil {
+			// Fail the whole job.
+			o.failures.fail(o.err)
+		}
 		// Propagate close. Note that sinks have nil collector.
 		if o.out != nil {
 			SendClose(o.out)
 		}
+		for _, tc := range o.tagged {
+			SendClose(tc)
+		}
 		o.wg.Done()
 	}()
 }
@@ -283,7 +728,13 @@ func (o *Operator) Close() error {
 }
 
 type operatorOptions struct {
-	inKs KeySelector
+	inKs     KeySelector
+	failures *failureCoordinator
+	restart  RestartStrategy
+	loop     *loop
+	metrics  metrics.Registry
+	node     string
+	bytes    bool
 }
 
 type OperatorOption func(options *operatorOptions)
@@ -294,6 +745,39 @@ func WithInKeySelector(ks KeySelector) OperatorOption {
 	}
 }
 
+// WithRestartStrategy restarts the operator when it fails, according to rs.
+// The failing Node gets the state it had before the failing record, and processes it again.
+// Failures of every parallel instance count towards the same strategy, until a restart succeeds.
+// Sources are never restarted.
+func WithRestartStrategy(rs RestartStrategy) OperatorOption {
+	return func(o *operatorOptions) {
+		o.restart = rs
+	}
+}
+
+// withLoop makes the operator part of the loop l.
+func withLoop(l *loop) OperatorOption {
+	return func(o *operatorOptions) {
+		o.loop = l
+	}
+}
+
+// withMetrics makes every partition of the operator report its metrics to r, labeled with node.
+// The encoded size of records is only measured if bytes is true.
+func withMetrics(r metrics.Registry, node string, bytes bool) OperatorOption {
+	return func(o *operatorOptions) {
+		o.metrics = r
+		o.node = node
+		o.bytes = bytes
+	}
+}
+
+func withFailureCoordinator(f *failureCoordinator) OperatorOption {
+	return func(o *operatorOptions) {
+		o.failures = f
+	}
+}
+
 type ParallelOperator struct {
 	ops  []*Operator
 	opts operatorOptions
@@ -310,11 +794,52 @@ func NewParallelOperator(par int, f func() *Operator, opts ...OperatorOption) *P
 	for _, opt := range opts {
 		opt(&pop.opts)
 	}
+	var rt *restartTracker
+	if pop.opts.restart != nil {
+		rt = newRestartTracker(pop.opts.restart)
+	}
+	for i, op := range ops {
+		op.partition = i
+		op.failures = pop.opts.failures
+		op.restarts = rt
+		op.loop = pop.opts.loop
+		if r := pop.opts.metrics; r != nil {
+			op.metrics = newOperatorMetrics(r, pop.opts.node, i, pop.opts.bytes)
+		}
+	}
 	return pop
 }
 
 func (o *ParallelOperator) In(ds DataStream, f func() Transport) {
-	ps := NewPartitionedStream(len(o.ops), o.opts.inKs, ds, f)
+	var onErr func(v values.Value, err error)
+	if fc := o.opts.failures; fc != nil {
+		name := o.ops[0].bn.GetName()
+		onErr = func(v values.Value, err error) {
+			fc.fail(&OperatorError{
+				Node:  name,
+				Value: v,
+				Err:   fmt.Errorf("cannot partition record: %w", err),
+			})
+		}
+	}
+	var blocked []metrics.Counter
+	if o.opts.metrics != nil {
+		// Transports are created in order of partition.
+		var i int
+		newTransport := f
+		f = func() Transport {
+			t := newTransport()
+			if is, ok := t.(*infiniteStream); ok {
+				is.buffered = o.ops[i].metrics.buffered
+			}
+			i++
+			return t
+		}
+		for _, op := range o.ops {
+			blocked = append(blocked, op.metrics.blocked)
+		}
+	}
+	ps := newPartitionedStream(len(o.ops), o.opts.inKs, ds, f, onErr, blocked)
 	for i, o := range o.ops {
 		o.In(ps.Stream(i))
 	}
@@ -328,6 +853,14 @@ func (o *ParallelOperator) Out(cs []Collector) {
 	}
 }
 
+func (o *ParallelOperator) OutTag(tag string, cs []Collector) {
+	bc := newBroadCastCollector(cs)
+	sc := newSharedCollector(bc, len(o.ops))
+	for _, o := range o.ops {
+		o.OutTag(tag, sc)
+	}
+}
+
 func (o *ParallelOperator) Open() {
 	for _, op := range o.ops {
 		op.Open()
@@ -335,22 +868,33 @@ func (o *ParallelOperator) Open() {
 }
 
 func (o *ParallelOperator) Close() error {
-	var err error
+	var errs Errors
 	for _, op := range o.ops {
-		if oerr := op.Close(); oerr != nil {
-			err = oerr
+		if err := op.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return err
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 type partitionedStream struct {
 	ds DataStream
 	ts []Transport
 	ks KeySelector
+	// onErr is called when the KeySelector panics. If nil, the panic is not recovered.
+	onErr func(v values.Value, err error)
+	// blocked, if set, counts the time spent collecting to each partition.
+	blocked []metrics.Counter
 }
 
 func NewPartitionedStream(par int, ks KeySelector, ds DataStream, f func() Transport) *partitionedStream {
+	return newPartitionedStream(par, ks, ds, f, nil, nil)
+}
+
+func newPartitionedStream(par int, ks KeySelector, ds DataStream, f func() Transport, onErr func(v values.Value, err error), blocked []metrics.Counter) *partitionedStream {
 	if ks == nil {
 		ks = NewRoundRobinKeySelector(par)
 	}
@@ -359,9 +903,11 @@ func NewPartitionedStream(par int, ks KeySelector, ds DataStream, f func() Trans
 		ts[i] = f()
 	}
 	ps := &partitionedStream{
-		ds: ds,
-		ts: ts,
-		ks: ks,
+		ds:      ds,
+		ts:      ts,
+		ks:      ks,
+		onErr:   onErr,
+		blocked: blocked,
 	}
 	go ps.do()
 	return ps
@@ -371,14 +917,31 @@ func (s *partitionedStream) Stream(partition int) DataStream {
 	return s.ts[partition]
 }
 
+func (s *partitionedStream) getKey(v values.Value) (k values.Key, err error) {
+	if s.onErr != nil {
+		defer recoverError(&err)
+	}
+	return s.ks.GetKey(v), nil
+}
+
 func (s *partitionedStream) do() {
 	for v := s.ds.Next(); v != nil; v = s.ds.Next() {
 		// Apply new keying.
-		k := s.ks.GetKey(v)
+		k, err := s.getKey(v)
+		if err != nil {
+			s.onErr(v, err)
+			break
+		}
 		kv := values.SetKey(k, v)
 		i := uint64(k) % uint64(len(s.ts))
 		t := s.ts[i]
+		if s.blocked == nil {
+			t.Collect(kv)
+			continue
+		}
+		start := time.Now()
 		t.Collect(kv)
+		s.blocked[i].Add(time.Since(start).Seconds())
 	}
 	for _, t := range s.ts {
 		SendClose(t)
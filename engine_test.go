@@ -1,10 +1,13 @@
 package ssp
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/affo/ssp/values"
 	"github.com/fortytw2/leaktest"
@@ -392,6 +395,60 @@ func TestWatermarker(t *testing.T) {
 			t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", wantTs, wantWm, ts, wm, err)
 		}
 	})
+
+	t.Run("aligned", func(t *testing.T) {
+		wmer, dss, closeFn := setup(2)
+		defer closeFn()
+		wmer = wmer.aligned()
+
+		// The second source has not sent anything yet.
+		dss[0].Collect(values.SetTime(values.Timestamp(0), values.Timestamp(0), values.NewNull(values.Int)))
+		v := wmer.Next()
+		wantTs := values.Timestamp(0)
+		wantWm := noWatermark
+		if ts, wm, err := values.GetTime(v); ts != wantTs || wm != wantWm || err != nil {
+			t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", wantTs, wantWm, ts, wm, err)
+		}
+		dss[0].Collect(values.SetTime(values.Timestamp(3), values.Timestamp(2), values.NewNull(values.Int)))
+		v = wmer.Next()
+		wantTs = values.Timestamp(3)
+		wantWm = noWatermark
+		if ts, wm, err := values.GetTime(v); ts != wantTs || wm != wantWm || err != nil {
+			t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", wantTs, wantWm, ts, wm, err)
+		}
+		dss[1].Collect(values.SetTime(values.Timestamp(1), values.Timestamp(1), values.NewNull(values.Int)))
+		v = wmer.Next()
+		wantTs = values.Timestamp(1)
+		wantWm = values.Timestamp(1)
+		if ts, wm, err := values.GetTime(v); ts != wantTs || wm != wantWm || err != nil {
+			t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", wantTs, wantWm, ts, wm, err)
+		}
+		dss[0].Collect(values.SetTime(values.Timestamp(6), values.Timestamp(5), values.NewNull(values.Int)))
+		v = wmer.Next()
+		wantTs = values.Timestamp(6)
+		wantWm = values.Timestamp(1)
+		if ts, wm, err := values.GetTime(v); ts != wantTs || wm != wantWm || err != nil {
+			t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", wantTs, wantWm, ts, wm, err)
+		}
+
+		// The second source closes, and stops holding back the watermark.
+		SendClose(dss[1])
+		ds := wmer.DataStream.(*dataStreams)
+		next := make(chan values.Value)
+		go func() {
+			next <- wmer.Next()
+		}()
+		for atomic.LoadInt64(&ds.n) != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		dss[0].Collect(values.SetTime(values.Timestamp(7), values.Timestamp(6), values.NewNull(values.Int)))
+		v = <-next
+		wantTs = values.Timestamp(7)
+		wantWm = values.Timestamp(6)
+		if ts, wm, err := values.GetTime(v); ts != wantTs || wm != wantWm || err != nil {
+			t.Errorf("unexpected ts/wm: want: %d/%d, got: %d/%d, err: %v", wantTs, wantWm, ts, wm, err)
+		}
+	})
 }
 
 func TestSharedCollector(t *testing.T) {
@@ -613,6 +670,38 @@ func TestParallelEngine_MultipleInputs(t *testing.T) {
 	}
 }
 
+func TestEngine_SingleInputSource(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	// merge forwards values as they are, with the source set by its own inputs.
+	merge := NewNode(func(collector Collector, v values.Value) error {
+		collector.Collect(v)
+		return nil
+	}).SetName("merge")
+	newSliceSource(ints(1)...).SetName("a").Out().Connect(ctx, merge)
+	newSliceSource(ints(2)...).SetName("b").Out().Connect(ctx, merge)
+	var got []string
+	merge.Out().Connect(ctx, NewStatefulNode(values.NewNull(values.Object),
+		func(state values.Value, _ Collector, v values.Value) (values.Value, error) {
+			s, err := values.GetSource(v)
+			if err != nil {
+				return nil, err
+			}
+			got = append(got, fmt.Sprintf("%v@%d", undecorated(v), s))
+			return state, nil
+		}).SetName("d"))
+
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// d has a single input, so every value comes from source 0.
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"1@0", "2@0"}, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
 func TestParallelEngine_Windows(t *testing.T) {
 	defer leaktest.Check(t)()
 
@@ -742,3 +831,84 @@ func TestParallelEngine_Windows(t *testing.T) {
 		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
 	}
 }
+
+func TestEngine_ErrorPropagation(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		// Produce way more than what buffers can hold.
+		for i := 0; i < 10*defaultBufferSize; i++ {
+			collector.Collect(values.New(int64(i)))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			if v.Int64() == 10 {
+				return fmt.Errorf("bad record")
+			}
+			collector.Collect(v)
+			return nil
+		})).
+		SetName("failing").
+		SetParallelism(4).
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			return nil
+		}).SetName("sink"))
+
+	err := Execute(ctx)
+	if err == nil {
+		t.Fatal("expected error got none")
+	}
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 {
+		t.Fatalf("expected exactly one error, got: %v", err)
+	}
+	var oerr *OperatorError
+	if !errors.As(err, &oerr) {
+		t.Fatalf("expected an OperatorError, got: %v", err)
+	}
+	if got, want := oerr.Node, "failing"; got != want {
+		t.Errorf("unexpected node -want/+got:\n\t-\t%s\n\t+\t%s", want, got)
+	}
+	if got, want := oerr.Partition, 10%4; got != want {
+		t.Errorf("unexpected partition -want/+got:\n\t-\t%d\n\t+\t%d", want, got)
+	}
+	if got, want := oerr.Key, values.Key(10%4); got != want {
+		t.Errorf("unexpected key -want/+got:\n\t-\t%d\n\t+\t%d", want, got)
+	}
+	if !strings.Contains(err.Error(), "bad record") {
+		t.Errorf("error does not contain the cause: %v", err)
+	}
+}
+
+func TestEngine_ErrorPropagation_MultipleErrors(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		for i := 0; i < 4; i++ {
+			collector.Collect(values.New(int64(i)))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			return fmt.Errorf("bad record %d", v.Int64())
+		})).
+		SetName("failing").
+		SetParallelism(2)
+
+	err := Execute(ctx)
+	if err == nil {
+		t.Fatal("expected error got none")
+	}
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got: %T", err)
+	}
+	if len(errs) == 0 || len(errs) > 2 {
+		t.Errorf("unexpected number of errors: %d", len(errs))
+	}
+}
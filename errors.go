@@ -0,0 +1,144 @@
+package ssp
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/affo/ssp/values"
+)
+
+// OperatorError is the error produced by an Operator whose Node failed while processing a record.
+type OperatorError struct {
+	Node      string
+	Partition int
+	Key       values.Key
+	// Value is the offending record, if any.
+	Value values.Value
+	Err   error
+}
+
+func (e *OperatorError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("node %q (partition %d, key %d): %v", e.Node, e.Partition, e.Key, e.Err)
+	}
+	return fmt.Sprintf("node %q (partition %d, key %d) on record %v: %v", e.Node, e.Partition, e.Key, e.Value, e.Err)
+}
+
+func (e *OperatorError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is the error produced by recovering from a panic.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// recoverError converts a panic into a PanicError, and stores it in err.
+// It must be deferred.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		}
+	}
+}
+
+// Errors aggregates the errors of multiple operators.
+type Errors []error
+
+func (es Errors) Error() string {
+	if len(es) == 1 {
+		return es[0].Error()
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%d errors occurred:", len(es)))
+	for _, err := range es {
+		sb.WriteString("\n\t* ")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the aggregated errors.
+func (es Errors) Unwrap() []error {
+	return es
+}
+
+// Is reports whether any of the aggregated errors matches target.
+func (es Errors) Is(target error) bool {
+	for _, err := range es {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the aggregated errors that matches target, and sets target to it.
+func (es Errors) As(target interface{}) bool {
+	for _, err := range es {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// failureCoordinator fails a job fast.
+// The first error reported cancels the job by closing the done channel.
+// Streams select on it, so that no operator stays blocked on a stream that nobody reads anymore.
+type failureCoordinator struct {
+	mu   sync.Mutex
+	errs Errors
+	once sync.Once
+	done chan struct{}
+}
+
+func newFailureCoordinator() *failureCoordinator {
+	return &failureCoordinator{
+		done: make(chan struct{}),
+	}
+}
+
+func (f *failureCoordinator) fail(err error) {
+	f.mu.Lock()
+	f.errs = append(f.errs, err)
+	f.mu.Unlock()
+	f.once.Do(func() {
+		close(f.done)
+	})
+}
+
+// add records err after the job is over, unless it has already been reported.
+func (f *failureCoordinator) add(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range f.errs {
+		if errors.Is(e, err) {
+			return
+		}
+	}
+	f.errs = append(f.errs, err)
+}
+
+func (f *failureCoordinator) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *failureCoordinator) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) == 0 {
+		return nil
+	}
+	return append(Errors(nil), f.errs...)
+}
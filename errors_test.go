@@ -0,0 +1,171 @@
+package ssp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEngine_PanicRecovery(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		for i := 0; i < 10*defaultBufferSize; i++ {
+			collector.Collect(values.New("foo"))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			// Type mismatch.
+			collector.Collect(values.New(v.Int64() + 1))
+			return nil
+		})).
+		SetName("mismatch").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			return nil
+		}).SetName("sink"))
+
+	err := Execute(ctx)
+	if err == nil {
+		t.Fatal("expected error got none")
+	}
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 {
+		t.Fatalf("expected exactly one error, got: %v", err)
+	}
+	var oerr *OperatorError
+	if !errors.As(err, &oerr) {
+		t.Fatalf("expected an OperatorError, got: %v", err)
+	}
+	if got, want := oerr.Node, "mismatch"; got != want {
+		t.Errorf("unexpected node -want/+got:\n\t-\t%s\n\t+\t%s", want, got)
+	}
+	if got, want := oerr.Value.String(), "foo"; got != want {
+		t.Errorf("unexpected record -want/+got:\n\t-\t%s\n\t+\t%s", want, got)
+	}
+	var perr *PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a PanicError, got: %v", err)
+	}
+	if got, want := perr.Value, "cannot get int64 out of string"; got != want {
+		t.Errorf("unexpected panic value -want/+got:\n\t-\t%v\n\t+\t%v", want, got)
+	}
+	if !strings.Contains(string(perr.Stack), "engine.go") {
+		t.Errorf("stack trace does not seem to be complete:\n%s", perr.Stack)
+	}
+}
+
+func TestEngine_PanicRecovery_ErrorPolicy(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	sink, log := NewLogSink(values.Int64)
+	mismatch := NewNode(func(collector Collector, v values.Value) error {
+		collector.Collect(values.New(int64(1)))
+		collector.Collect(values.New("foo"))
+		collector.Collect(values.New(int64(2)))
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			collector.Collect(values.New(v.Int64() + 1))
+			return nil
+		}).SetErrorPolicy(DeadLetterOnError)).
+		SetName("mismatch")
+	mismatch.
+		Out().
+		Connect(ctx, sink.SetName("sink"))
+	dlqSink, dlq := NewLogSink(values.Object)
+	DeadLetters(mismatch).Connect(ctx, dlqSink.SetName("dlq"))
+
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(log.GetValues()), 2; got != want {
+		t.Errorf("unexpected number of values -want/+got:\n\t-\t%d\n\t+\t%d", want, got)
+	}
+	if got, want := len(dlq.GetValues()), 1; got != want {
+		t.Errorf("unexpected number of dead letters -want/+got:\n\t-\t%d\n\t+\t%d", want, got)
+	}
+}
+
+func TestEngine_PanicRecovery_KeySelector(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		for i := 0; i < 10*defaultBufferSize; i++ {
+			collector.Collect(values.New(i))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		KeyBy(NewStringValueKeySelector(func(v values.Value) string {
+			// Type mismatch.
+			return strings.ToUpper(v.Get().(string))
+		})).
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			return nil
+		}).SetName("keyed").SetParallelism(2))
+
+	err := Execute(ctx)
+	if err == nil {
+		t.Fatal("expected error got none")
+	}
+	if !strings.Contains(err.Error(), `node "keyed"`) {
+		t.Errorf("error does not name the node: %v", err)
+	}
+	var perr *PanicError
+	if !errors.As(err, &perr) {
+		t.Errorf("expected a PanicError, got: %v", err)
+	}
+}
+
+func TestErrors_Unwrap(t *testing.T) {
+	cause := fmt.Errorf("bad record")
+	oerr := &OperatorError{Node: "failing", Err: cause}
+	var err error = Errors{fmt.Errorf("other"), oerr}
+
+	var got *OperatorError
+	if !errors.As(err, &got) || got != oerr {
+		t.Errorf("expected to find the OperatorError, got: %v", got)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected to find the cause")
+	}
+	var perr *PanicError
+	if errors.As(err, &perr) {
+		t.Errorf("unexpected PanicError: %v", perr)
+	}
+
+	t.Run("close errors", func(t *testing.T) {
+		f := newFailureCoordinator()
+		f.fail(oerr)
+		// Errors returned when closing operators are added once.
+		f.add(oerr)
+		f.add(cause)
+		if diff := cmp.Diff([]string{oerr.Error()}, errorStrings(f.Err())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+		other := fmt.Errorf("other")
+		f.add(other)
+		if diff := cmp.Diff([]string{oerr.Error(), "other"}, errorStrings(f.Err())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
+
+func errorStrings(err error) []string {
+	var ss []string
+	for _, err := range err.(Errors) {
+		ss = append(ss, err.Error())
+	}
+	return ss
+}
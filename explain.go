@@ -0,0 +1,274 @@
+package ssp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// NodeDescription describes a Node in an Explanation.
+type NodeDescription struct {
+	// ID identifies the node in its graph, because names are not unique.
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Parallelism int    `json:"parallelism"`
+	ErrorPolicy string `json:"errorPolicy"`
+	// Chain lists the maps and filters chained in the node, in order.
+	Chain     []string           `json:"chain,omitempty"`
+	Window    *WindowDescription `json:"window,omitempty"`
+	Aggregate string             `json:"aggregate,omitempty"`
+}
+
+// WindowDescription describes the windows of a Node.
+type WindowDescription struct {
+	Size  int `json:"size"`
+	Slide int `json:"slide"`
+}
+
+// ArchDescription describes an Arch in an Explanation.
+type ArchDescription struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Tag  string `json:"tag,omitempty"`
+	// Partitioner tells how values are sent to the instances of the destination node.
+	Partitioner string `json:"partitioner"`
+	Feedback    bool   `json:"feedback,omitempty"`
+}
+
+// GraphDescription describes a Graph in an Explanation.
+// Nodes are listed breadth-first from the roots.
+type GraphDescription struct {
+	Nodes  []NodeDescription `json:"nodes"`
+	Arches []ArchDescription `json:"arches"`
+}
+
+// Describe describes g.
+func Describe(g Graph) *GraphDescription {
+	var nodes []Node
+	ids := make(map[Node]int)
+	visit := func(n Node) {
+		if _, ok := ids[n]; !ok {
+			ids[n] = len(nodes)
+			nodes = append(nodes, n)
+		}
+	}
+	ins := make(map[Node][]*Arch)
+	g.Walk(func(a *Arch) {
+		ins[a.To()] = append(ins[a.To()], a)
+	})
+	for _, r := range g.Roots() {
+		visit(r)
+	}
+	for i := 0; i < len(nodes); i++ {
+		for _, a := range g.Adjacents(nodes[i]) {
+			visit(a.To())
+		}
+	}
+
+	d := &GraphDescription{
+		Nodes:  make([]NodeDescription, 0, len(nodes)),
+		Arches: make([]ArchDescription, 0),
+	}
+	for i, n := range nodes {
+		d.Nodes = append(d.Nodes, describeNode(i, n, len(ins[n]) == 0))
+		for _, a := range g.Adjacents(n) {
+			d.Arches = append(d.Arches, ArchDescription{
+				From:        i,
+				To:          ids[a.To()],
+				Tag:         a.tag,
+				Partitioner: partitioner(a),
+				Feedback:    isFeedback(a),
+			})
+		}
+	}
+	return d
+}
+
+func describeNode(id int, n Node, source bool) NodeDescription {
+	d := NodeDescription{
+		ID:          id,
+		Name:        nodeToString(n),
+		Kind:        "node",
+		Parallelism: n.GetParallelism(),
+		ErrorPolicy: GetErrorPolicy(n).String(),
+	}
+	switch n := n.(type) {
+	case *AnonymousNode:
+		if n.chain != nil {
+			for _, s := range n.chain.steps {
+				d.Chain = append(d.Chain, s.name)
+			}
+			d.Kind = "chain"
+			if len(d.Chain) == 1 {
+				d.Kind = d.Chain[0]
+			}
+		} else if source {
+			d.Kind = "source"
+		}
+	case *windowedNode:
+		d.Kind = "window"
+		d.Window = &WindowDescription{Size: n.size, Slide: n.slide}
+		if n.agg != nil {
+			d.Aggregate = n.agg.Name()
+		}
+	case *preAggregateNode:
+		d.Kind = "pre-aggregate"
+		d.Window = &WindowDescription{Size: n.size, Slide: n.size}
+		d.Aggregate = n.agg.Name()
+	case *unionNode:
+		d.Kind = "union"
+	case *iterationHead:
+		d.Kind = "iteration"
+	}
+	return d
+}
+
+// partitioner describes how values flow through a.
+func partitioner(a *Arch) string {
+	switch ks := a.ks.(type) {
+	case nil:
+		if a.To().GetParallelism() == 1 {
+			return "forward"
+		}
+		return "round-robin"
+	case keptKeySelector:
+		return "kept keys"
+	case fmt.Stringer:
+		return "key " + ks.String()
+	default:
+		return "key " + reflect.TypeOf(ks).Name()
+	}
+}
+
+func (d *GraphDescription) writeText(sb *strings.Builder, indent string) {
+	for _, n := range d.Nodes {
+		sb.WriteString(indent)
+		sb.WriteString(n.String())
+		sb.WriteRune('\n')
+		for _, a := range d.Arches {
+			if a.From != n.ID {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s  -> %s [%s]\n", indent, d.Nodes[a.To].Name, a.label()))
+		}
+	}
+}
+
+func (n NodeDescription) String() string {
+	attrs := []string{n.Kind, fmt.Sprintf("parallelism %d", n.Parallelism)}
+	if n.ErrorPolicy != FailOnError.String() {
+		attrs = append(attrs, "on error "+n.ErrorPolicy)
+	}
+	if len(n.Chain) > 1 {
+		attrs = append(attrs, "chain "+strings.Join(n.Chain, " -> "))
+	}
+	if n.Window != nil {
+		attrs = append(attrs, fmt.Sprintf("size %d", n.Window.Size), fmt.Sprintf("slide %d", n.Window.Slide))
+	}
+	if n.Aggregate != "" {
+		attrs = append(attrs, "aggregate "+n.Aggregate)
+	}
+	return fmt.Sprintf("%s (%s)", n.Name, strings.Join(attrs, ", "))
+}
+
+func (a ArchDescription) label() string {
+	l := a.Partitioner
+	if a.Tag != "" {
+		l += ", tag " + a.Tag
+	}
+	if a.Feedback {
+		l += ", feedback"
+	}
+	return l
+}
+
+func (d *GraphDescription) writeDOT(sb *strings.Builder, prefix string) {
+	for _, n := range d.Nodes {
+		label := n.String()
+		if i := strings.Index(label, " ("); i >= 0 {
+			label = label[:i] + "\n" + strings.Trim(label[i+1:], "()")
+		}
+		sb.WriteString(fmt.Sprintf("    %s%d [label=%q];\n", prefix, n.ID, label))
+	}
+	for _, a := range d.Arches {
+		style := ""
+		if a.Feedback {
+			style = ", style=dashed"
+		}
+		sb.WriteString(fmt.Sprintf("    %s%d -> %s%d [label=%q%s];\n", prefix, a.From, prefix, a.To, a.label(), style))
+	}
+}
+
+// Explanation describes how a Graph is executed: the logical Graph, as built by the user,
+// the physical one, that gets executed, and the rewrites that led from the first to the second.
+type Explanation struct {
+	Logical  *GraphDescription `json:"logical"`
+	Physical *GraphDescription `json:"physical"`
+	Rewrites []Rewrite         `json:"rewrites"`
+}
+
+// Explain explains the execution of g with the given planner.
+// Without a planner, the physical Graph is the logical one.
+func Explain(g Graph, p *Planner) *Explanation {
+	e := &Explanation{
+		Logical:  Describe(g),
+		Rewrites: make([]Rewrite, 0),
+	}
+	if p == nil {
+		e.Physical = e.Logical
+		return e
+	}
+	plan := p.Plan(g)
+	e.Physical = Describe(plan.Physical)
+	e.Rewrites = append(e.Rewrites, plan.Rewrites...)
+	return e
+}
+
+// Explain explains the execution of the Graph in ctx by the Engine.
+func (e *Engine) Explain(ctx context.Context) *Explanation {
+	return Explain(GetGraph(ctx), e.planner)
+}
+
+// Text renders the Explanation as indented text.
+// Every node is followed by its output Arches, with the partitioning of values.
+func (e *Explanation) Text() string {
+	sb := strings.Builder{}
+	sb.WriteString("logical:\n")
+	e.Logical.writeText(&sb, "  ")
+	sb.WriteString("rewrites:\n")
+	for _, r := range e.Rewrites {
+		sb.WriteString("  ")
+		sb.WriteString(r.String())
+		sb.WriteRune('\n')
+	}
+	sb.WriteString("physical:\n")
+	e.Physical.writeText(&sb, "  ")
+	return sb.String()
+}
+
+// DOT renders the Explanation in the Graphviz DOT language,
+// with the logical and physical Graphs side by side.
+func (e *Explanation) DOT() string {
+	sb := strings.Builder{}
+	sb.WriteString("digraph explain {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	sb.WriteString("  subgraph cluster_logical {\n")
+	sb.WriteString("    label=\"logical\";\n")
+	e.Logical.writeDOT(&sb, "l")
+	sb.WriteString("  }\n")
+	sb.WriteString("  subgraph cluster_physical {\n")
+	sb.WriteString("    label=\"physical\";\n")
+	e.Physical.writeDOT(&sb, "p")
+	sb.WriteString("  }\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// JSON renders the Explanation as indented JSON.
+func (e *Explanation) JSON() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
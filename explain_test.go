@@ -0,0 +1,174 @@
+package ssp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := Context()
+	ks := NewStringValueKeySelector(func(v values.Value) string {
+		return v.String()
+	})
+	filter := newSliceSource(ints(1, 2, 3)...).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).Out().
+		Filter(ctx, func(v values.Value) bool {
+			return true
+		})
+	SetErrorPolicy(filter, SkipOnError).Out().
+		KeyBy(ks).Window(4, 4).Aggregate(ctx, Sum()).SetParallelism(2).Out().
+		Sink(ctx, func(v values.Value) error {
+			return nil
+		})
+	e := NewEngine(WithPlanner(DefaultPlanner())).Explain(ctx)
+
+	t.Run("text", func(t *testing.T) {
+		want := `logical:
+  source (source, parallelism 1)
+    -> map [forward]
+  map (map, parallelism 1)
+    -> filter [forward]
+  filter (filter, parallelism 1, on error skip)
+    -> window-sum [key stringValueKeySelector]
+  window-sum (window, parallelism 2, size 4, slide 4, aggregate sum)
+    -> sink [forward]
+  sink (node, parallelism 1)
+rewrites:
+  pre-aggregation: pre-window-sum before window-sum
+physical:
+  source (source, parallelism 1)
+    -> map [forward]
+  map (map, parallelism 1)
+    -> filter [forward]
+  filter (filter, parallelism 1, on error skip)
+    -> pre-window-sum [forward]
+  pre-window-sum (pre-aggregate, parallelism 1, size 4, slide 4, aggregate sum)
+    -> window-sum [kept keys]
+  window-sum (window, parallelism 2, size 4, slide 4, aggregate sum)
+    -> sink [forward]
+  sink (node, parallelism 1)
+`
+		if diff := cmp.Diff(want, e.Text()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+	t.Run("dot", func(t *testing.T) {
+		want := `digraph explain {
+  rankdir=LR;
+  node [shape=box];
+  subgraph cluster_logical {
+    label="logical";
+    l0 [label="source\nsource, parallelism 1"];
+    l1 [label="map\nmap, parallelism 1"];
+    l2 [label="filter\nfilter, parallelism 1, on error skip"];
+    l3 [label="window-sum\nwindow, parallelism 2, size 4, slide 4, aggregate sum"];
+    l4 [label="sink\nnode, parallelism 1"];
+    l0 -> l1 [label="forward"];
+    l1 -> l2 [label="forward"];
+    l2 -> l3 [label="key stringValueKeySelector"];
+    l3 -> l4 [label="forward"];
+  }
+  subgraph cluster_physical {
+    label="physical";
+    p0 [label="source\nsource, parallelism 1"];
+    p1 [label="map\nmap, parallelism 1"];
+    p2 [label="filter\nfilter, parallelism 1, on error skip"];
+    p3 [label="pre-window-sum\npre-aggregate, parallelism 1, size 4, slide 4, aggregate sum"];
+    p4 [label="window-sum\nwindow, parallelism 2, size 4, slide 4, aggregate sum"];
+    p5 [label="sink\nnode, parallelism 1"];
+    p0 -> p1 [label="forward"];
+    p1 -> p2 [label="forward"];
+    p2 -> p3 [label="forward"];
+    p3 -> p4 [label="kept keys"];
+    p4 -> p5 [label="forward"];
+  }
+}
+`
+		if diff := cmp.Diff(want, e.DOT()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		bs, err := e.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := &Explanation{}
+		if err := json.Unmarshal(bs, got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(e, got); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
+
+func TestExplain_Fusion(t *testing.T) {
+	ctx := Context()
+	it := Iterate(ctx, newSliceSource(ints(1)...).Out())
+	body := it.Head().Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).Out().
+		Filter(ctx, func(v values.Value) bool {
+			return false
+		}).SetName("stop")
+	it.Feedback(ctx, body.Out())
+	body.Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).Out().
+		Filter(ctx, func(v values.Value) bool {
+			return true
+		}).SetParallelism(2).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).SetParallelism(2).Out().
+		Sink(ctx, func(v values.Value) error {
+			return nil
+		})
+	want := `logical:
+  source (source, parallelism 1)
+    -> iteration [forward]
+  iteration (iteration, parallelism 1)
+    -> map [forward]
+  map (map, parallelism 1)
+    -> stop [forward]
+  stop (filter, parallelism 1)
+    -> iteration [forward, feedback]
+    -> map-2 [forward]
+  map-2 (map, parallelism 1)
+    -> filter [round-robin]
+  filter (filter, parallelism 2)
+    -> map-3 [round-robin]
+  map-3 (map, parallelism 2)
+    -> sink [forward]
+  sink (node, parallelism 1)
+rewrites:
+  map-filter-fusion: filter and map-3 into filter+map-3
+physical:
+  source (source, parallelism 1)
+    -> iteration [forward]
+  iteration (iteration, parallelism 1)
+    -> map [forward]
+  map (map, parallelism 1)
+    -> stop [forward]
+  stop (filter, parallelism 1)
+    -> iteration [forward, feedback]
+    -> map-2 [forward]
+  map-2 (map, parallelism 1)
+    -> filter+map-3 [round-robin]
+  filter+map-3 (chain, parallelism 2, chain filter -> map)
+    -> sink [forward]
+  sink (node, parallelism 1)
+`
+	got := Explain(GetGraph(ctx), DefaultPlanner()).Text()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
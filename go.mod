@@ -1,8 +1,9 @@
 module github.com/affo/ssp
 
-go 1.13
+go 1.18
 
 require (
 	github.com/fortytw2/leaktest v1.3.0
 	github.com/google/go-cmp v0.4.0
+	gopkg.in/yaml.v3 v3.0.0-20190709130402-674ba3eaed22
 )
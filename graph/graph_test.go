@@ -115,3 +115,236 @@ func Test_Cycle(t *testing.T) {
 		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
 	}
 }
+
+func Test_ArchFields(t *testing.T) {
+	ctx := Context()
+	n1, n2, n3 := BaseNode{ID: "1"}, BaseNode{ID: "2"}, BaseNode{ID: "3"}
+	l := NewLink(n1)
+	l.Connect(ctx, n2)
+	l.WithWeight(3).Connect(ctx, n3)
+	g := GetGraph(ctx)
+	var got []int
+	g.Walk(func(a *Arch) {
+		got = append(got, a.weight)
+	})
+	// The default weight is 1, and Connect copies fields.
+	if diff := cmp.Diff([]int{1, 3}, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if err := g.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	NewLink(n3).WithWeight(0).Connect(ctx, n1)
+	if err := GetGraph(ctx).Check(); err == nil || err.Error() != "arch 3 -> 1: weight must be positive, got 0" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func Test_Meta(t *testing.T) {
+	ctx := Context()
+	n1, n2 := BaseNode{ID: "1"}, BaseNode{ID: "2"}
+	n1.Out().Connect(ctx, n2)
+	g := GetGraph(ctx)
+	g.Meta(n1).Label = "source"
+	g.Meta(n1).Visits++
+	if diff := cmp.Diff(NodeMeta{Label: "source", Visits: 1}, *g.Meta(n1)); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff(NodeMeta{}, *g.Meta(n2)); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	// Nodes out of the Graph do not keep metadata.
+	n3 := BaseNode{ID: "3"}
+	g.Meta(n3).Label = "lost"
+	if diff := cmp.Diff(NodeMeta{}, *g.Meta(n3)); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func Test_Remove(t *testing.T) {
+	build := func() (Graph, []Node) {
+		ctx := Context()
+		ns := make([]Node, 5)
+		for i := range ns {
+			ns[i] = BaseNode{ID: strconv.Itoa(i)}
+		}
+		ns[0].Out().Connect(ctx, ns[1]).Out().Connect(ctx, ns[2])
+		ns[1].Out().Connect(ctx, ns[3])
+		ns[4].Out().Connect(ctx, ns[2])
+		return GetGraph(ctx), ns
+	}
+
+	t.Run("arch", func(t *testing.T) {
+		g, ns := build()
+		g.Meta(ns[0]).Label = "zero"
+		if !g.RemoveArch(g.Adjacents(ns[0])[0]) {
+			t.Fatal("arch not removed")
+		}
+		want := `1 -> 2
+1 -> 3
+4 -> 2
+`
+		if diff := cmp.Diff(want, g.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+		if diff := cmp.Diff([]Node{ns[1], ns[4]}, g.Roots()); diff != "" {
+			t.Errorf("unexpected roots -want/+got:\n\t%s", diff)
+		}
+		if _, ok := g.roots[ns[0]]; ok {
+			t.Error("node without arches should be removed")
+		}
+		if diff := cmp.Diff(NodeMeta{}, *g.Meta(ns[0])); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+		if err := g.Check(); err != nil {
+			t.Fatal(err)
+		}
+		if g.RemoveArch(NewLink(ns[0])) {
+			t.Error("unknown arch removed")
+		}
+	})
+
+	t.Run("node", func(t *testing.T) {
+		g, ns := build()
+		if !g.RemoveNode(ns[1]) {
+			t.Fatal("node not removed")
+		}
+		want := `4 -> 2
+`
+		if diff := cmp.Diff(want, g.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+		if diff := cmp.Diff(map[Node]bool{ns[4]: true, ns[2]: false}, g.roots); diff != "" {
+			t.Errorf("unexpected roots -want/+got:\n\t%s", diff)
+		}
+		if err := g.Check(); err != nil {
+			t.Fatal(err)
+		}
+		if g.RemoveNode(ns[1]) {
+			t.Error("removed node removed again")
+		}
+	})
+}
+
+func Test_ReplaceNode(t *testing.T) {
+	ctx := Context()
+	ns := make([]Node, 4)
+	for i := range ns {
+		ns[i] = BaseNode{ID: strconv.Itoa(i)}
+	}
+	NewLink(ns[0]).WithWeight(2).Connect(ctx, ns[1]).Out().Connect(ctx, ns[2])
+	ns[1].Out().Connect(ctx, ns[1])
+	g := GetGraph(ctx)
+	g.Meta(ns[1]).Label = "one"
+
+	fake := BaseNode{ID: "fake"}
+	if !g.ReplaceNode(ns[1], fake) {
+		t.Fatal("node not replaced")
+	}
+	want := `0 -> fake
+fake -> 2
+fake -> fake
+`
+	if diff := cmp.Diff(want, g.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff(map[Node]bool{ns[0]: true, fake: false, ns[2]: false}, g.roots); diff != "" {
+		t.Errorf("unexpected roots -want/+got:\n\t%s", diff)
+	}
+	if w := g.Adjacents(ns[0])[0].weight; w != 2 {
+		t.Errorf("arch lost its weight: %d", w)
+	}
+	if diff := cmp.Diff("one", g.Meta(fake).Label); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if err := g.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if g.ReplaceNode(ns[3], fake) {
+		t.Error("unknown node replaced")
+	}
+
+	t.Run("root", func(t *testing.T) {
+		fake := BaseNode{ID: "fake source"}
+		if !g.ReplaceNode(ns[0], fake) {
+			t.Fatal("node not replaced")
+		}
+		if diff := cmp.Diff([]Node{fake}, g.Roots()); diff != "" {
+			t.Errorf("unexpected roots -want/+got:\n\t%s", diff)
+		}
+	})
+}
+
+func Test_Splice(t *testing.T) {
+	ctx := Context()
+	n1, n2, n3 := BaseNode{ID: "1"}, BaseNode{ID: "2"}, BaseNode{ID: "3"}
+	NewLink(n1).WithWeight(5).Connect(ctx, n2)
+	n1.Out().Connect(ctx, n3)
+	g := GetGraph(ctx)
+
+	mid := BaseNode{ID: "mid"}
+	in, out := g.Splice(g.Adjacents(n1)[0], mid)
+	if in == nil || out == nil {
+		t.Fatal("arch not spliced")
+	}
+	// The outgoing arch keeps the weight of the spliced one.
+	if out.weight != 5 {
+		t.Errorf("unexpected weight of the outgoing arch: %d", out.weight)
+	}
+	out.WithWeight(7)
+	want := `1 -> mid
+1 -> 3
+mid -> 2
+`
+	if diff := cmp.Diff(want, g.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff([]int{5, 7}, []int{g.Adjacents(n1)[0].weight, g.Adjacents(mid)[0].weight}); diff != "" {
+		t.Errorf("unexpected weights -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff([]Node{n1}, g.Roots()); diff != "" {
+		t.Errorf("unexpected roots -want/+got:\n\t%s", diff)
+	}
+	if err := g.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if in, out := g.Splice(NewLink(n1), mid); in != nil || out != nil {
+		t.Error("unknown arch spliced")
+	}
+}
+
+func Test_Subgraph(t *testing.T) {
+	ctx := Context()
+	ns := make([]Node, 4)
+	for i := range ns {
+		ns[i] = BaseNode{ID: strconv.Itoa(i)}
+	}
+	ns[0].Out().Connect(ctx, ns[1]).Out().Connect(ctx, ns[2]).Out().Connect(ctx, ns[3])
+	g := GetGraph(ctx)
+	g.Meta(ns[2]).Label = "two"
+
+	s := g.Subgraph(ns[1], ns[2], ns[3])
+	want := `1 -> 2
+2 -> 3
+`
+	if diff := cmp.Diff(want, s.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff([]Node{ns[1]}, s.Roots()); diff != "" {
+		t.Errorf("unexpected roots -want/+got:\n\t%s", diff)
+	}
+	s.Meta(ns[2]).Label = "changed"
+	if diff := cmp.Diff("two", g.Meta(ns[2]).Label); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+
+	c := g.Copy()
+	c.RemoveNode(ns[3])
+	if diff := cmp.Diff([]Node{ns[0], ns[1], ns[2], ns[3]}, g.Nodes()); diff != "" {
+		t.Errorf("original changed -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff([]Node{ns[0], ns[1], ns[2]}, c.Nodes()); diff != "" {
+		t.Errorf("unexpected nodes -want/+got:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,14 @@
+package graph
+
+import "fmt"
+
+// checkWeights is the validation hook of Graph: weights of arches must be positive.
+func checkWeights(g Graph) error {
+	var err error
+	g.Walk(func(a *Arch) {
+		if err == nil && a.weight <= 0 {
+			err = fmt.Errorf("arch %v: weight must be positive, got %d", a, a.weight)
+		}
+	})
+	return err
+}
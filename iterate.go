@@ -0,0 +1,371 @@
+package ssp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/affo/ssp/values"
+)
+
+type IterationOption func(it *Iteration)
+
+// WithMaxIterations drops values that would enter the loop more than n times through the feedback.
+// By default, values can iterate indefinitely.
+func WithMaxIterations(n int) IterationOption {
+	return func(it *Iteration) {
+		it.max = n
+	}
+}
+
+// Iteration is a loop in the graph.
+// Values enter the loop from the Head, flow through the nodes in the loop body,
+// and are sent back to the Head through feedback Arches.
+// Every node that is both reachable from the Head and connected to a feedback Arch belongs to the loop body.
+//
+// The loop terminates when the inputs of the Head are closed and no value is in flight in the loop.
+// At that point, the Head closes its output, and Close propagates through the loop body as usual.
+// Loops cannot be nested.
+type Iteration struct {
+	head  *iterationHead
+	tails []*Arch
+	max   int
+}
+
+// Iterate starts a loop fed by a.
+func Iterate(ctx context.Context, a *Arch, opts ...IterationOption) *Iteration {
+	it := &Iteration{}
+	for _, opt := range opts {
+		opt(it)
+	}
+	it.head = &iterationHead{
+		baseNode: newBaseNode(),
+		it:       it,
+	}
+	it.head.SetName(uniqueName(ctx, "iteration"))
+	a.Connect(ctx, it.head)
+	return it
+}
+
+// Head returns the node from which values enter the loop body.
+func (it *Iteration) Head() Node {
+	return it.head
+}
+
+// Feedback sends the values from a back to the Head.
+func (it *Iteration) Feedback(ctx context.Context, a *Arch) {
+	it.tails = append(it.tails, a)
+	a.Connect(ctx, it.head)
+}
+
+// isFeedback returns true if a is a copy of one of the feedback Arches.
+func (it *Iteration) isFeedback(a *Arch) bool {
+	for _, t := range it.tails {
+		if t.From() == a.From() && t.tag == a.tag {
+			return true
+		}
+	}
+	return false
+}
+
+// sortInputs puts feedback Arches after the other inputs of the Head,
+// and returns the number of the other inputs.
+func (it *Iteration) sortInputs(in []*Arch) ([]*Arch, int) {
+	sorted := make([]*Arch, 0, len(in))
+	var feedback []*Arch
+	for _, a := range in {
+		if it.isFeedback(a) {
+			feedback = append(feedback, a)
+		} else {
+			sorted = append(sorted, a)
+		}
+	}
+	return append(sorted, feedback...), len(sorted)
+}
+
+// iterationHead forwards values to the loop body, counting the times they go through it.
+type iterationHead struct {
+	baseNode
+	it *Iteration
+}
+
+func (h *iterationHead) Do(collector Collector, v values.Value) error {
+	n := 0
+	if i, ok := iteration(v, h.it); ok {
+		n = i + 1
+	}
+	if h.it.max > 0 && n > h.it.max {
+		return nil
+	}
+	collector.Collect(&iteratedValue{Value: forward(v), it: h.it, n: n})
+	return nil
+}
+
+func (h *iterationHead) Out() *Arch {
+	return NewLink(h)
+}
+
+func (h *iterationHead) OutTag(tag string) *Arch {
+	return NewTaggedLink(h, tag)
+}
+
+func (h *iterationHead) DeadLetters() *Arch {
+	return h.OutTag(DeadLetterTag)
+}
+
+func (h *iterationHead) SetParallelism(par int) Node {
+	h.par = par
+	return h
+}
+
+func (h *iterationHead) SetName(name string) Node {
+	h.name = name
+	return h
+}
+
+func (h *iterationHead) SetErrorPolicy(p ErrorPolicy) Node {
+	h.ep = p
+	return h
+}
+
+func (h *iterationHead) GetTimestamps() Timestamps {
+	return KeepsTimestamps
+}
+
+func (h *iterationHead) Clone() Node {
+	return &iterationHead{
+		baseNode: h.baseNode.Clone(),
+		it:       h.it,
+	}
+}
+
+// iteratedValue decorates values in a loop with the number of times they went through the feedback.
+type iteratedValue struct {
+	values.Value
+	it *Iteration
+	n  int
+}
+
+func (v *iteratedValue) Unwrap() (values.Value, error) {
+	return v.Value, nil
+}
+
+// iteration returns the number of times v went through the feedback of it.
+func iteration(v values.Value, it *Iteration) (int, bool) {
+	for {
+		if iv, ok := v.(*iteratedValue); ok && iv.it == it {
+			return iv.n, true
+		}
+		u, err := v.Unwrap()
+		if err != nil {
+			return 0, false
+		}
+		v = u
+	}
+}
+
+// iterationCollector decorates the values emitted by the loop body with the iteration
+// of the value being processed.
+type iterationCollector struct {
+	c  Collector
+	it *Iteration
+	n  *int
+}
+
+func (c iterationCollector) decorate(v values.Value) values.Value {
+	if v.Type() == values.Close {
+		return v
+	}
+	return &iteratedValue{Value: v, it: c.it, n: *c.n}
+}
+
+func (c iterationCollector) Collect(v values.Value) {
+	c.c.Collect(c.decorate(v))
+}
+
+func (c iterationCollector) CollectTo(tag string, v values.Value) {
+	CollectTo(c.c, tag, c.decorate(v))
+}
+
+func (c iterationCollector) Done() <-chan struct{} {
+	return Done(c.c)
+}
+
+// loop detects the termination of an Iteration during execution.
+// Values sent to nodes in the loop are counted as in flight until they are processed.
+// Nodes emit values while processing, so the count cannot drop to zero while values can still be produced.
+type loop struct {
+	it *Iteration
+
+	mu       sync.Mutex
+	inFlight int64
+	open     int
+	once     sync.Once
+	done     chan struct{}
+}
+
+func newLoop(it *Iteration) *loop {
+	return &loop{
+		it:   it,
+		done: make(chan struct{}),
+	}
+}
+
+// setInputs sets the number of inputs of the Head, other than the feedback.
+func (l *loop) setInputs(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open = n
+}
+
+// inputClosed signals that an input of the Head, other than the feedback, has been closed.
+func (l *loop) inputClosed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open--
+	l.check()
+}
+
+func (l *loop) sent() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight++
+}
+
+func (l *loop) processed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.check()
+}
+
+func (l *loop) check() {
+	if l.open == 0 && l.inFlight == 0 {
+		l.once.Do(func() {
+			close(l.done)
+		})
+	}
+}
+
+// Done is closed when the loop terminates.
+func (l *loop) Done() <-chan struct{} {
+	return l.done
+}
+
+// collector counts the values sent to c.
+func (l *loop) collector(c Collector) Collector {
+	return loopCollector{c: c, l: l}
+}
+
+type loopCollector struct {
+	c Collector
+	l *loop
+}
+
+func (c loopCollector) Collect(v values.Value) {
+	if v.Type() != values.Close {
+		c.l.sent()
+	}
+	c.c.Collect(v)
+}
+
+// findLoops returns the loop that every node in the body of an Iteration belongs to.
+func findLoops(g Graph) map[Node]*loop {
+	var heads []*iterationHead
+	ins := make(map[Node][]*Arch)
+	Walk(g, func(a *Arch) {
+		if h, ok := a.To().(*iterationHead); ok && len(ins[h]) == 0 {
+			heads = append(heads, h)
+		}
+		ins[a.To()] = append(ins[a.To()], a)
+	})
+	loops := make(map[Node]*loop)
+	for _, h := range heads {
+		fwd := make(map[Node]bool)
+		var visitFwd func(n Node)
+		visitFwd = func(n Node) {
+			if fwd[n] {
+				return
+			}
+			fwd[n] = true
+			for _, a := range g.Adjacents(n) {
+				if a.To() != nil {
+					visitFwd(a.To())
+				}
+			}
+		}
+		visitFwd(h)
+		l := newLoop(h.it)
+		body := make(map[Node]bool)
+		var visitBwd func(n Node)
+		visitBwd = func(n Node) {
+			if body[n] || !fwd[n] {
+				return
+			}
+			body[n] = true
+			loops[n] = l
+			if n == Node(h) {
+				return
+			}
+			for _, a := range ins[n] {
+				visitBwd(a.From())
+			}
+		}
+		for _, t := range h.it.tails {
+			visitBwd(t.From())
+		}
+		loops[h] = l
+	}
+	return loops
+}
+
+// feedbackCollector never blocks producers, by buffering values in memory.
+// Otherwise, a loop could deadlock when both the Head and the loop body block on full streams.
+type feedbackCollector struct {
+	is   *infiniteStream
+	done <-chan struct{}
+
+	mu    sync.Mutex
+	vs    []values.Value
+	ready chan struct{}
+}
+
+func newFeedbackCollector(is *infiniteStream, done <-chan struct{}) *feedbackCollector {
+	c := &feedbackCollector{
+		is:    is,
+		done:  done,
+		ready: make(chan struct{}, 1),
+	}
+	go c.pump()
+	return c
+}
+
+func (c *feedbackCollector) Collect(v values.Value) {
+	c.mu.Lock()
+	c.vs = append(c.vs, v)
+	c.mu.Unlock()
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pump moves buffered values to the stream, until Close.
+func (c *feedbackCollector) pump() {
+	for {
+		select {
+		case <-c.ready:
+		case <-c.done:
+			return
+		}
+		c.mu.Lock()
+		vs := c.vs
+		c.vs = nil
+		c.mu.Unlock()
+		for _, v := range vs {
+			c.is.Collect(v)
+			if v.Type() == values.Close {
+				return
+			}
+		}
+	}
+}
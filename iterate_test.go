@@ -0,0 +1,102 @@
+package ssp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIterate(t *testing.T) {
+	// Values are doubled until they reach 100.
+	double := func(v values.Value) (values.Value, error) {
+		return values.New(v.Int() * 2), nil
+	}
+	build := func(ctx context.Context, in []values.Value, par int, opts ...IterationOption) *values.List {
+		it := Iterate(ctx, newSliceSource(in...).Out(), opts...)
+		doubled := it.Head().Out().Map(ctx, double).SetParallelism(par)
+		it.Feedback(ctx, doubled.Out().Filter(ctx, func(v values.Value) bool {
+			return v.Int() < 100
+		}).Out())
+		sink, log := NewLogSink(values.Int)
+		doubled.Out().Filter(ctx, func(v values.Value) bool {
+			return v.Int() >= 100
+		}).Out().Connect(ctx, sink)
+		return log
+	}
+
+	for _, tc := range []struct {
+		name string
+		in   []values.Value
+		par  int
+		opts []IterationOption
+		want []string
+	}{
+		{
+			name: "terminates",
+			in:   ints(1, 3, 7, 100),
+			par:  1,
+			want: []string{"112", "128", "192", "200"},
+		},
+		{
+			name: "parallel body",
+			in:   ints(1, 3, 7, 100),
+			par:  4,
+			want: []string{"112", "128", "192", "200"},
+		},
+		{
+			name: "max iterations",
+			// 1 would need 7 iterations to reach 100.
+			in:   ints(1, 30, 60),
+			par:  1,
+			opts: []IterationOption{WithMaxIterations(2)},
+			want: []string{"120", "120"},
+		},
+		{
+			name: "no input",
+			par:  1,
+			want: []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer leaktest.Check(t)()
+
+			ctx := Context()
+			log := build(ctx, tc.in, tc.par, tc.opts...)
+			if err := Execute(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, sortedStrings(log.GetValues())); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
+
+func TestWalk_Loop(t *testing.T) {
+	ctx := Context()
+	it := Iterate(ctx, newSliceSource().Out())
+	body := it.Head().Out().Connect(ctx, NewNode(func(Collector, values.Value) error {
+		return nil
+	}).SetName("body"))
+	it.Feedback(ctx, body.Out())
+	body.Out().Connect(ctx, NewNode(func(Collector, values.Value) error {
+		return nil
+	}).SetName("sink"))
+
+	var got []string
+	Walk(GetGraph(ctx), func(a *Arch) {
+		got = append(got, a.String())
+	})
+	want := []string{
+		"source -> iteration",
+		"iteration -> body",
+		"body -> iteration",
+		"body -> sink",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,192 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/affo/ssp/metrics"
+)
+
+// JobID identifies a job submitted to a JobManager.
+type JobID int
+
+func (id JobID) String() string {
+	return fmt.Sprintf("job-%d", int(id))
+}
+
+// JobStatus is the status of a job.
+type JobStatus int
+
+const (
+	JobRunning JobStatus = iota
+	JobFinished
+	JobFailed
+	JobCanceled
+)
+
+func (s JobStatus) String() string {
+	switch s {
+	case JobRunning:
+		return "running"
+	case JobFinished:
+		return "finished"
+	case JobFailed:
+		return "failed"
+	case JobCanceled:
+		return "canceled"
+	default:
+		return fmt.Sprintf("JobStatus(%d)", int(s))
+	}
+}
+
+// JobInfo describes a job at some point in time.
+type JobInfo struct {
+	ID     JobID
+	Name   string
+	Status JobStatus
+	// Err is the error that made the job fail, if any.
+	Err error
+}
+
+type job struct {
+	info   JobInfo
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// JobManager runs multiple graphs concurrently in the same process.
+// Every job is executed by its own Engine, and can be canceled without affecting the other ones.
+type JobManager struct {
+	opts []EngineOption
+
+	mu   sync.Mutex
+	last JobID
+	jobs map[JobID]*job
+}
+
+// NewJobManager creates a JobManager whose jobs are executed by engines with the given options.
+// Metrics reported by jobs, if any, are labeled with the ID of the job, because names need not be unique.
+func NewJobManager(opts ...EngineOption) *JobManager {
+	return &JobManager{
+		opts: opts,
+		jobs: make(map[JobID]*job),
+	}
+}
+
+// Submit starts executing the Graph in ctx as a job named name, and returns its ID.
+// The job gets canceled if ctx is.
+// Graphs are not copied, so the Graph in ctx must not be modified nor submitted again.
+func (m *JobManager) Submit(ctx context.Context, name string) JobID {
+	ctx, cancel := context.WithCancel(ctx)
+	m.mu.Lock()
+	m.last++
+	j := &job{
+		info: JobInfo{
+			ID:     m.last,
+			Name:   name,
+			Status: JobRunning,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.jobs[j.info.ID] = j
+	m.mu.Unlock()
+
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		e := NewEngine(m.opts...)
+		if e.metrics != nil {
+			e.metrics = metrics.With(e.metrics, metrics.Labels{"job": j.info.ID.String()})
+		}
+		err := e.Execute(ctx)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// A job that completes before being canceled is finished.
+		switch {
+		case err == nil:
+			j.info.Status = JobFinished
+		case ctx.Err() != nil:
+			j.info.Status = JobCanceled
+		default:
+			j.info.Status = JobFailed
+			j.info.Err = err
+		}
+	}()
+	return j.info.ID
+}
+
+func (m *JobManager) get(id JobID) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%v not found", id)
+	}
+	return j, nil
+}
+
+// Status returns the current info of the job with the given ID.
+func (m *JobManager) Status(id JobID) (JobInfo, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return j.info, nil
+}
+
+// List returns the current info of every job, sorted by ID.
+func (m *JobManager) List() []JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]JobInfo, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		infos = append(infos, j.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// Cancel cancels the job with the given ID, and waits for it to stop.
+// Canceling a job that is not running has no effect.
+func (m *JobManager) Cancel(id JobID) error {
+	j, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	j.cancel()
+	<-j.done
+	return nil
+}
+
+// Remove forgets the job with the given ID, so that it is not listed anymore.
+// Jobs are kept until removed, and only jobs that are not running can be removed.
+func (m *JobManager) Remove(id JobID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("%v not found", id)
+	}
+	if j.info.Status == JobRunning {
+		return fmt.Errorf("%v is running", id)
+	}
+	delete(m.jobs, id)
+	return nil
+}
+
+// Wait waits for the job with the given ID to stop, and returns its final info.
+func (m *JobManager) Wait(id JobID) (JobInfo, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	<-j.done
+	return m.Status(id)
+}
@@ -0,0 +1,172 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp/metrics"
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newEndlessSource emits values until its job gets canceled, and closes started at the first one.
+func newEndlessSource(started chan struct{}) Node {
+	return NewNode(func(collector Collector, _ values.Value) error {
+		for i := 0; ; i++ {
+			select {
+			case <-Done(collector):
+				return nil
+			default:
+			}
+			collector.Collect(values.New(i))
+			if i == 0 {
+				close(started)
+			}
+		}
+	}).SetName("source")
+}
+
+func TestJobManager(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	m := NewJobManager()
+
+	endless := Context()
+	started := make(chan struct{})
+	newEndlessSource(started).Out().Sink(endless, func(values.Value) error {
+		return nil
+	})
+	endlessID := m.Submit(endless, "endless")
+
+	finite := Context()
+	sink, log := NewLogSink(values.Int)
+	newSliceSource(ints(1, 2, 3)...).Out().Connect(finite, sink.SetName("sink"))
+	<-started
+	finiteID := m.Submit(finite, "finite")
+
+	failing := Context()
+	newSliceSource(ints(1)...).Out().Sink(failing, func(values.Value) error {
+		return fmt.Errorf("bad record")
+	})
+	failingID := m.Submit(failing, "failing")
+
+	if info, err := m.Status(endlessID); err != nil || info.Status != JobRunning {
+		t.Errorf("unexpected status of running job: %v, %v", info, err)
+	}
+	for _, id := range []JobID{finiteID, failingID} {
+		if _, err := m.Wait(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Cancel(endlessID); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []JobInfo{
+		{ID: 1, Name: "endless", Status: JobCanceled},
+		{ID: 2, Name: "finite", Status: JobFinished},
+		{ID: 3, Name: "failing", Status: JobFailed},
+	}
+	got := m.List()
+	if err := got[2].Err; err == nil || !strings.Contains(err.Error(), "bad record") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	got[2].Err = nil
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected jobs -want/+got:\n\t%s", diff)
+	}
+	// Canceling a job does not affect the others.
+	if diff := cmp.Diff([]string{"1", "2", "3"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+
+	t.Run("remove", func(t *testing.T) {
+		running := Context()
+		started := make(chan struct{})
+		newEndlessSource(started).Out().Sink(running, func(values.Value) error {
+			return nil
+		})
+		runningID := m.Submit(running, "running")
+		<-started
+		if err := m.Remove(runningID); err == nil || err.Error() != "job-4 is running" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := m.Cancel(runningID); err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []JobID{endlessID, finiteID, failingID, runningID} {
+			if err := m.Remove(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := m.List(); len(got) != 0 {
+			t.Errorf("unexpected jobs: %v", got)
+		}
+		if _, err := m.Status(finiteID); err == nil {
+			t.Error("expected error got none")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := m.Status(42); err == nil || err.Error() != "job-42 not found" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := m.Cancel(42); err == nil {
+			t.Error("expected error got none")
+		}
+		if err := m.Remove(42); err == nil {
+			t.Error("expected error got none")
+		}
+	})
+}
+
+func TestExecute_Cancel(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx, cancel := context.WithCancel(Context())
+	started := make(chan struct{})
+	newEndlessSource(started).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).SetParallelism(2).Out().
+		Sink(ctx, func(values.Value) error {
+			return nil
+		})
+	go func() {
+		<-started
+		cancel()
+	}()
+	err := Execute(ctx)
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestJobManager_Metrics(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	r := metrics.NewMemoryRegistry()
+	m := NewJobManager(WithMetrics(r))
+	// Jobs with the same name report separate metrics.
+	for _, vs := range [][]values.Value{ints(1, 2), ints(3)} {
+		ctx := Context()
+		newSliceSource(vs...).Out().Sink(ctx, func(values.Value) error {
+			return nil
+		})
+		if _, err := m.Wait(m.Submit(ctx, "words")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]string{
+		`{job="job-1",node="source",partition="0"}`: "0",
+		`{job="job-1",node="sink",partition="0"}`:   "2",
+		`{job="job-2",node="source",partition="0"}`: "0",
+		`{job="job-2",node="sink",partition="0"}`:   "1",
+	}
+	if diff := cmp.Diff(want, samples(t, r, "ssp_records_in_total")); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,130 @@
+package ssp
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/affo/ssp/metrics"
+	"github.com/affo/ssp/values"
+)
+
+// latencyBuckets go from 1µs to about 4s.
+var latencyBuckets = metrics.ExponentialBuckets(1e-6, 4, 12)
+
+// operatorMetrics are the metrics of a partition of an operator.
+type operatorMetrics struct {
+	recordsIn  metrics.Counter
+	recordsOut metrics.Counter
+	bytesIn    metrics.Counter
+	bytesOut   metrics.Counter
+	latency    metrics.Histogram
+	watermark  metrics.Gauge
+	lag        metrics.Gauge
+	keys       metrics.Gauge
+	windows    metrics.Gauge
+	buffered   metrics.Gauge
+	blocked    metrics.Counter
+}
+
+// newOperatorMetrics creates the metrics of a partition of the operator of node.
+// Byte counters are only created if bytes is true, because measuring them encodes every record.
+func newOperatorMetrics(r metrics.Registry, node string, partition int, bytes bool) *operatorMetrics {
+	ls := metrics.Labels{"node": node, "partition": strconv.Itoa(partition)}
+	m := &operatorMetrics{
+		recordsIn:  r.Counter("ssp_records_in_total", "Records processed.", ls),
+		recordsOut: r.Counter("ssp_records_out_total", "Records emitted, to any output.", ls),
+		latency:    r.Histogram("ssp_processing_latency_seconds", "Time spent processing a record.", latencyBuckets, ls),
+		watermark:  r.Gauge("ssp_watermark", "Watermark of the last record processed.", ls),
+		lag:        r.Gauge("ssp_watermark_lag_seconds", "Wall clock time minus the watermark, for timestamps in nanoseconds since the epoch.", ls),
+		keys:       r.Gauge("ssp_keyed_state_keys", "Keys with their own state, for stateful nodes.", ls),
+		windows:    r.Gauge("ssp_open_windows", "Windows that are open, for every key.", ls),
+		buffered:   r.Gauge("ssp_buffered_records", "Records waiting in the input buffer.", ls),
+		blocked:    r.Counter("ssp_backpressure_seconds_total", "Time spent waiting for room in the input buffer.", ls),
+	}
+	if bytes {
+		m.bytesIn = r.Counter("ssp_bytes_in_total", "Encoded size of the records processed.", ls)
+		m.bytesOut = r.Counter("ssp_bytes_out_total", "Encoded size of the records emitted.", ls)
+	}
+	return m
+}
+
+// nodeLabels returns the value of the node label of the metrics of every node in g.
+// Validation makes names unique, unnamed nodes are labeled with their position in g.
+func nodeLabels(g Graph) map[Node]string {
+	ls := make(map[Node]string)
+	var unnamed int
+	for _, n := range g.Nodes() {
+		if name := n.GetName(); name != "" {
+			ls[n] = name
+			continue
+		}
+		unnamed++
+		ls[n] = "#" + strconv.Itoa(unnamed)
+	}
+	return ls
+}
+
+// size returns the encoded size of v, or 0 if it cannot be encoded.
+func size(v values.Value) float64 {
+	b, err := values.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return float64(len(b))
+}
+
+// in records that v entered the operator.
+func (m *operatorMetrics) in(v values.Value) {
+	m.recordsIn.Add(1)
+	if m.bytesIn != nil {
+		m.bytesIn.Add(size(v))
+	}
+	if _, wm, err := values.GetTime(v); err == nil {
+		m.watermark.Set(float64(wm))
+		m.lag.Set(time.Since(values.ConvertTimestamp(wm)).Seconds())
+	}
+}
+
+// processed records the time spent processing a record since start.
+func (m *operatorMetrics) processed(start time.Time) {
+	m.latency.Observe(time.Since(start).Seconds())
+}
+
+// collector counts the values emitted to c.
+func (m *operatorMetrics) collector(c Collector) Collector {
+	return metricsCollector{c: c, m: m}
+}
+
+type metricsCollector struct {
+	c Collector
+	m *operatorMetrics
+}
+
+func (c metricsCollector) count(v values.Value) {
+	if v.Type() == values.Close {
+		return
+	}
+	c.m.recordsOut.Add(1)
+	if c.m.bytesOut != nil {
+		c.m.bytesOut.Add(size(v))
+	}
+}
+
+func (c metricsCollector) Collect(v values.Value) {
+	c.count(v)
+	c.c.Collect(v)
+}
+
+func (c metricsCollector) CollectTo(tag string, v values.Value) {
+	c.count(v)
+	CollectTo(c.c, tag, v)
+}
+
+func (c metricsCollector) Done() <-chan struct{} {
+	return Done(c.c)
+}
+
+// instrumented is implemented by nodes that report metrics of their own.
+type instrumented interface {
+	instrument(m *operatorMetrics)
+}
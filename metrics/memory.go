@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type kind int
+
+const (
+	counterKind kind = iota
+	gaugeKind
+	histogramKind
+)
+
+func (k kind) String() string {
+	switch k {
+	case counterKind:
+		return "counter"
+	case gaugeKind:
+		return "gauge"
+	default:
+		return "histogram"
+	}
+}
+
+// family holds the metrics with the same name.
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	buckets []float64
+	// metrics by the string representation of their labels.
+	metrics map[string]interface{}
+}
+
+// MemoryRegistry is a Registry that keeps metrics in memory.
+// It is an http.Handler that exposes them in the Prometheus text format.
+type MemoryRegistry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		families: make(map[string]*family),
+	}
+}
+
+func (r *MemoryRegistry) get(name, help string, k kind, buckets []float64, labels Labels, newMetric func() interface{}) interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{
+			name:    name,
+			help:    help,
+			kind:    k,
+			buckets: buckets,
+			metrics: make(map[string]interface{}),
+		}
+		r.families[name] = f
+	}
+	if f.kind != k {
+		panic(fmt.Sprintf("metric %s is a %v, not a %v", name, f.kind, k))
+	}
+	if k == histogramKind && !equalBuckets(f.buckets, buckets) {
+		panic(fmt.Sprintf("histogram %s has buckets %v, not %v", name, f.buckets, buckets))
+	}
+	ls := labels.String()
+	m, ok := f.metrics[ls]
+	if !ok {
+		m = newMetric()
+		f.metrics[ls] = m
+	}
+	return m
+}
+
+func equalBuckets(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *MemoryRegistry) Counter(name, help string, labels Labels) Counter {
+	return r.get(name, help, counterKind, nil, labels, func() interface{} {
+		return &value{}
+	}).(*value)
+}
+
+func (r *MemoryRegistry) Gauge(name, help string, labels Labels) Gauge {
+	return r.get(name, help, gaugeKind, nil, labels, func() interface{} {
+		return &value{}
+	}).(*value)
+}
+
+func (r *MemoryRegistry) Histogram(name, help string, buckets []float64, labels Labels) Histogram {
+	return r.get(name, help, histogramKind, buckets, labels, func() interface{} {
+		return &histogram{
+			buckets: buckets,
+			counts:  make([]uint64, len(buckets)),
+		}
+	}).(*histogram)
+}
+
+// value is a Counter and a Gauge.
+type value struct {
+	bits uint64
+}
+
+func (v *value) Set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		n := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+type histogram struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) Observe(v float64) {
+	// The first bucket whose upper bound is greater than or equal to v.
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// snapshot returns the cumulative counts of the buckets, the total count, and the sum of the observations.
+func (h *histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cumulative := make([]uint64, len(h.counts))
+	var c uint64
+	for i, n := range h.counts {
+		c += n
+		cumulative[i] = c
+	}
+	return cumulative, h.count, h.sum
+}
@@ -0,0 +1,108 @@
+// Package metrics provides counters, gauges and histograms, and exposes them in the Prometheus text format.
+//
+// Metrics are created from a Registry by name and labels.
+// Asking a Registry twice for the same name and labels returns the same metric,
+// so that metrics survive, for example, the restart of the component that updates them.
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labels identify a metric among the ones with the same name.
+type Labels map[string]string
+
+// String returns the labels in the Prometheus text format, sorted by name, e.g., `{node="map",partition="0"}`.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for n := range l {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	sb := strings.Builder{}
+	sb.WriteRune('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteString(fmt.Sprintf(`%s="%s"`, n, labelEscaper.Replace(l[n])))
+	}
+	sb.WriteRune('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// merge returns a copy of l with the labels in o, that take precedence.
+func (l Labels) merge(o Labels) Labels {
+	m := make(Labels, len(l)+len(o))
+	for n, v := range l {
+		m[n] = v
+	}
+	for n, v := range o {
+		m[n] = v
+	}
+	return m
+}
+
+// Counter is a value that only increases.
+type Counter interface {
+	Add(delta float64)
+}
+
+// Gauge is a value that can go up and down.
+type Gauge interface {
+	Set(v float64)
+	Add(delta float64)
+}
+
+// Histogram samples observations into buckets.
+type Histogram interface {
+	Observe(v float64)
+}
+
+// Registry creates metrics, or returns the ones already created with the same name and labels.
+// Registering the same name with different kinds of metrics, or different buckets, panics.
+type Registry interface {
+	Counter(name, help string, labels Labels) Counter
+	Gauge(name, help string, labels Labels) Gauge
+	// Histogram creates a histogram with the given upper bounds of its buckets, in increasing order.
+	Histogram(name, help string, buckets []float64, labels Labels) Histogram
+}
+
+// ExponentialBuckets returns n upper bounds of buckets, starting from start, each factor times the previous one.
+func ExponentialBuckets(start, factor float64, n int) []float64 {
+	bs := make([]float64, n)
+	for i := range bs {
+		bs[i] = start
+		start *= factor
+	}
+	return bs
+}
+
+// With returns a Registry that adds labels to the metrics created by r.
+func With(r Registry, labels Labels) Registry {
+	return labeled{r: r, labels: labels}
+}
+
+type labeled struct {
+	r      Registry
+	labels Labels
+}
+
+func (l labeled) Counter(name, help string, labels Labels) Counter {
+	return l.r.Counter(name, help, l.labels.merge(labels))
+}
+
+func (l labeled) Gauge(name, help string, labels Labels) Gauge {
+	return l.r.Gauge(name, help, l.labels.merge(labels))
+}
+
+func (l labeled) Histogram(name, help string, buckets []float64, labels Labels) Histogram {
+	return l.r.Histogram(name, help, buckets, l.labels.merge(labels))
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryRegistry_WriteText(t *testing.T) {
+	r := NewMemoryRegistry()
+	r.Counter("records_total", "Records.", Labels{"node": "b"}).Add(2)
+	c := r.Counter("records_total", "Records.", Labels{"node": "a"})
+	c.Add(1)
+	// The same name and labels return the same metric.
+	r.Counter("records_total", "Records.", Labels{"node": "a"}).Add(1.5)
+	g := r.Gauge("buffered", "Buffered \"records\"\nin\\out.", Labels{"path": "a\"b\\c\nd"})
+	g.Set(10)
+	g.Add(-3)
+	h := r.Histogram("latency_seconds", "", []float64{0.1, 1}, nil)
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.Observe(v)
+	}
+
+	want := `# HELP buffered Buffered "records"\nin\\out.
+# TYPE buffered gauge
+buffered{path="a\"b\\c\nd"} 7
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 2.65
+latency_seconds_count 4
+# HELP records_total Records.
+# TYPE records_total counter
+records_total{node="a"} 2.5
+records_total{node="b"} 2
+`
+	sb := &strings.Builder{}
+	if err := r.WriteText(sb); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+
+	t.Run("http", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("unexpected content type: %s", ct)
+		}
+		if diff := cmp.Diff(want, rec.Body.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
+
+func TestMemoryRegistry_Mismatch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		register func(r Registry)
+		want     string
+	}{
+		{
+			name: "kind",
+			register: func(r Registry) {
+				r.Gauge("m", "", nil)
+			},
+			want: "metric m is a counter, not a gauge",
+		},
+		{
+			name: "buckets",
+			register: func(r Registry) {
+				r.Histogram("h", "", []float64{1, 2}, nil)
+			},
+			want: "histogram h has buckets [1], not [1 2]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewMemoryRegistry()
+			r.Counter("m", "", nil)
+			r.Histogram("h", "", []float64{1}, nil)
+			defer func() {
+				if got := recover(); got != tc.want {
+					t.Errorf("unexpected panic: %v", got)
+				}
+			}()
+			tc.register(r)
+		})
+	}
+}
+
+func TestWith(t *testing.T) {
+	r := NewMemoryRegistry()
+	job := With(r, Labels{"job": "words", "node": "ignored"})
+	job.Counter("records_total", "", Labels{"node": "count"}).Add(1)
+	job.Gauge("buffered", "", nil).Set(1)
+	job.Histogram("latency", "", []float64{1}, nil).Observe(1)
+
+	want := `# TYPE buffered gauge
+buffered{job="words",node="ignored"} 1
+# TYPE latency histogram
+latency_bucket{job="words",node="ignored",le="1"} 1
+latency_bucket{job="words",node="ignored",le="+Inf"} 1
+latency_sum{job="words",node="ignored"} 1
+latency_count{job="words",node="ignored"} 1
+# TYPE records_total counter
+records_total{job="words",node="count"} 1
+`
+	sb := &strings.Builder{}
+	if err := r.WriteText(sb); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, sb.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	if diff := cmp.Diff([]float64{1, 4, 16, 64}, ExponentialBuckets(1, 4, 4)); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// withLabel adds a label to the string representation of labels, at the end.
+func withLabel(labels, name, value string) string {
+	l := fmt.Sprintf(`%s="%s"`, name, value)
+	if labels == "" {
+		return "{" + l + "}"
+	}
+	return labels[:len(labels)-1] + "," + l + "}"
+}
+
+// WriteText writes the metrics to w in the Prometheus text format, sorted by name and labels.
+func (r *MemoryRegistry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	fs := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		c := *f
+		c.metrics = make(map[string]interface{}, len(f.metrics))
+		for ls, m := range f.metrics {
+			c.metrics[ls] = m
+		}
+		fs = append(fs, c)
+	}
+	r.mu.Unlock()
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].name < fs[j].name
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fs {
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.name, helpEscaper.Replace(f.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %v\n", f.name, f.kind)
+		lss := make([]string, 0, len(f.metrics))
+		for ls := range f.metrics {
+			lss = append(lss, ls)
+		}
+		sort.Strings(lss)
+		for _, ls := range lss {
+			switch m := f.metrics[ls].(type) {
+			case *value:
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, ls, formatFloat(m.get()))
+			case *histogram:
+				counts, count, sum := m.snapshot()
+				for i, b := range m.buckets {
+					fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, withLabel(ls, "le", formatFloat(b)), counts[i])
+				}
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, withLabel(ls, "le", "+Inf"), count)
+				fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, ls, formatFloat(sum))
+				fmt.Fprintf(bw, "%s_count%s %d\n", f.name, ls, count)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP exposes the metrics in the Prometheus text format.
+func (r *MemoryRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.WriteText(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,183 @@
+package ssp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp/metrics"
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// samples returns the samples of the metrics in r with the given name, by labels.
+func samples(t *testing.T, r *metrics.MemoryRegistry, name string) map[string]string {
+	t.Helper()
+	sb := &strings.Builder{}
+	if err := r.WriteText(sb); err != nil {
+		t.Fatal(err)
+	}
+	ss := make(map[string]string)
+	for _, l := range strings.Split(sb.String(), "\n") {
+		if !strings.HasPrefix(l, name+"{") {
+			continue
+		}
+		i := strings.LastIndex(l, " ")
+		ss[l[len(name):i]] = l[i+1:]
+	}
+	return ss
+}
+
+func TestEngine_Metrics(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	ts := newSliceSource(ints(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)...).Out().
+		Connect(ctx, AssignTimestamp(func(v values.Value) (values.Timestamp, values.Timestamp) {
+			ts := values.Timestamp(v.Int())
+			return ts, ts
+		}).SetName("timestamps"))
+	ts.Out().KeyBy(FnKeySelector(func(v values.Value) values.Key {
+		return values.Key(v.Int() % 2)
+	})).Window(4, 4).Aggregate(ctx, Count()).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return output(v, values.New(v.String())), nil
+		}).SetName("format").SetParallelism(2).Out().
+		Sink(ctx, func(values.Value) error {
+			return nil
+		})
+
+	r := metrics.NewMemoryRegistry()
+	if err := NewEngine(WithMetrics(r)).Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		want map[string]string
+	}{
+		{
+			name: "ssp_records_in_total",
+			want: map[string]string{
+				`{node="timestamps",partition="0"}`:   "10",
+				`{node="window-count",partition="0"}`: "10",
+				// Windows [0, 4) and [4, 8) close for both keys.
+				`{node="format",partition="0"}`: "2",
+				`{node="format",partition="1"}`: "2",
+				`{node="sink",partition="0"}`:   "4",
+			},
+		},
+		{
+			name: "ssp_records_out_total",
+			want: map[string]string{
+				`{node="source",partition="0"}`:       "10",
+				`{node="timestamps",partition="0"}`:   "10",
+				`{node="window-count",partition="0"}`: "4",
+				`{node="format",partition="0"}`:       "2",
+				`{node="format",partition="1"}`:       "2",
+				`{node="sink",partition="0"}`:         "0",
+			},
+		},
+		{
+			name: "ssp_processing_latency_seconds_count",
+			want: map[string]string{
+				`{node="timestamps",partition="0"}`:   "10",
+				`{node="window-count",partition="0"}`: "10",
+				`{node="format",partition="0"}`:       "2",
+				`{node="format",partition="1"}`:       "2",
+				`{node="sink",partition="0"}`:         "4",
+			},
+		},
+		{
+			name: "ssp_watermark",
+			want: map[string]string{
+				`{node="window-count",partition="0"}`: "9",
+			},
+		},
+		{
+			name: "ssp_keyed_state_keys",
+			want: map[string]string{
+				`{node="window-count",partition="0"}`: "2",
+			},
+		},
+		{
+			name: "ssp_open_windows",
+			want: map[string]string{
+				// The windows [8, 12) of both keys never close.
+				`{node="window-count",partition="0"}`: "2",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := samples(t, r, tc.name)
+			for ls := range got {
+				if _, ok := tc.want[ls]; !ok {
+					delete(got, ls)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+
+	t.Run("bytes", func(t *testing.T) {
+		// Byte metrics are opt-in.
+		if got := samples(t, r, "ssp_bytes_out_total"); len(got) > 0 {
+			t.Errorf("unexpected byte metrics: %v", got)
+		}
+	})
+}
+
+func TestEngine_ByteMetrics(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	newSliceSource(ints(1, 2)...).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).Out().
+		Sink(ctx, func(values.Value) error {
+			return nil
+		})
+	r := metrics.NewMemoryRegistry()
+	if err := NewEngine(WithMetrics(r), WithByteMetrics()).Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for ls, b := range samples(t, r, "ssp_bytes_out_total") {
+		if b == "0" && !strings.Contains(ls, "sink") {
+			t.Errorf("no bytes out for %s", ls)
+		}
+	}
+	for ls, b := range samples(t, r, "ssp_bytes_in_total") {
+		if b == "0" && !strings.Contains(ls, "source") {
+			t.Errorf("no bytes in for %s", ls)
+		}
+	}
+}
+
+func TestEngine_Metrics_Unnamed(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	nop := func(collector Collector, v values.Value) error {
+		collector.Collect(v)
+		return nil
+	}
+	source := newSliceSource(ints(1, 2)...)
+	source.Out().Connect(ctx, NewNode(nop))
+	source.Out().Connect(ctx, NewNode(nop))
+	r := metrics.NewMemoryRegistry()
+	if err := NewEngine(WithMetrics(r)).Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Unnamed nodes report separate metrics.
+	want := map[string]string{
+		`{node="#1",partition="0"}`:     "2",
+		`{node="#2",partition="0"}`:     "2",
+		`{node="source",partition="0"}`: "2",
+	}
+	if diff := cmp.Diff(want, samples(t, r, "ssp_records_out_total")); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
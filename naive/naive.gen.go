@@ -22,6 +22,13 @@ type Graph struct {
 	roots     map[Node]bool
 }
 
+func newGraph() Graph {
+	return Graph{
+		adjacency: make(map[Node][]*Arch),
+		roots:     make(map[Node]bool),
+	}
+}
+
 func (g *Graph) add(a *Arch) {
 	g.adjacency[a.From()] = append(g.adjacency[a.From()], a)
 	if _, ok := g.roots[a.From()]; !ok {
@@ -30,6 +37,154 @@ func (g *Graph) add(a *Arch) {
 	g.roots[a.To()] = false
 }
 
+// RemoveArch removes a from the Graph, together with the nodes that are left without arches.
+// It returns false if a is not in the Graph.
+func (g *Graph) RemoveArch(a *Arch) bool {
+	adj := g.adjacency[a.from]
+	for i, b := range adj {
+		if b != a {
+			continue
+		}
+		// Do not modify the slice in place, it may be shared.
+		adj = append(adj[:i:i], adj[i+1:]...)
+		if len(adj) == 0 {
+			delete(g.adjacency, a.from)
+		} else {
+			g.adjacency[a.from] = adj
+		}
+		g.update(a.from)
+		g.update(a.to)
+		return true
+	}
+	return false
+}
+
+// RemoveNode removes n and its arches from the Graph, together with the nodes that are left without arches.
+// It returns false if n is not in the Graph.
+func (g *Graph) RemoveNode(n Node) bool {
+	if _, ok := g.roots[n]; !ok {
+		return false
+	}
+	for _, a := range g.ins(n) {
+		g.RemoveArch(a)
+	}
+	for _, a := range g.adjacency[n] {
+		g.RemoveArch(a)
+	}
+	return true
+}
+
+// ReplaceNode makes the arches of old start from or end in n instead, and removes old from the Graph.
+// The arches are replaced by copies with the new ends.
+// It returns false if old is not in the Graph.
+func (g *Graph) ReplaceNode(old, n Node) bool {
+	if _, ok := g.roots[old]; !ok {
+		return false
+	}
+	if old == n {
+		return true
+	}
+	swap := func(m Node) Node {
+		if m == old {
+			return n
+		}
+		return m
+	}
+	for from, adj := range g.adjacency {
+		replaced := make([]*Arch, len(adj))
+		for i, a := range adj {
+			if a.from == old || a.to == old {
+				a = a.clone(swap(a.from), swap(a.to))
+			}
+			replaced[i] = a
+		}
+		g.adjacency[from] = replaced
+	}
+	if outs, ok := g.adjacency[old]; ok {
+		adj := g.adjacency[n]
+		g.adjacency[n] = append(adj[:len(adj):len(adj)], outs...)
+		delete(g.adjacency, old)
+	}
+	delete(g.roots, old)
+	g.update(n)
+	return true
+}
+
+// Splice inserts n in the middle of a, by replacing a with an arch from its start to n,
+// and an arch from n to its end. The arch to n keeps the fields of a.
+// The arch from n is a new link from n: it keeps the Downstream fields of a only.
+// It returns the new arches, that can be modified, or nil if a is not in the Graph.
+func (g *Graph) Splice(a *Arch, n Node) (in, out *Arch) {
+	adj := g.adjacency[a.from]
+	for i, b := range adj {
+		if b != a {
+			continue
+		}
+		in = a.clone(a.from, n)
+		out = NewLink(n)
+		out.to = a.to
+		spliced := append(adj[:i:i], in)
+		g.adjacency[a.from] = append(spliced, adj[i+1:]...)
+		g.add(out)
+		g.update(n)
+		return in, out
+	}
+	return nil, nil
+}
+
+// Subgraph returns a copy of the arches between the given nodes, in a new Graph.
+func (g Graph) Subgraph(nodes ...Node) Graph {
+	in := make(map[Node]bool, len(nodes))
+	for _, n := range nodes {
+		in[n] = true
+	}
+	s := newGraph()
+	g.Walk(func(a *Arch) {
+		if in[a.from] && in[a.to] {
+			s.add(a.clone(a.from, a.to))
+		}
+	})
+	return s
+}
+
+// Copy returns a copy of the Graph, that can be modified independently.
+// Graphs returned by GetGraph share their arches, and modifying them modifies the Graph in the context.
+func (g Graph) Copy() Graph {
+	return g.Subgraph(g.Nodes()...)
+}
+
+// Nodes returns the nodes in the Graph, sorted by their representation.
+func (g Graph) Nodes() []Node {
+	ns := make([]Node, 0, len(g.roots))
+	for n := range g.roots {
+		ns = append(ns, n)
+	}
+	sort.Sort(nodesByRepr(ns))
+	return ns
+}
+
+// ins returns the arches that end in n.
+// It walks the whole Graph, so it costs O(E): callers that need the inputs of many nodes should index them once.
+func (g Graph) ins(n Node) []*Arch {
+	var ins []*Arch
+	g.Walk(func(a *Arch) {
+		if a.to == n {
+			ins = append(ins, a)
+		}
+	})
+	return ins
+}
+
+// update fixes the bookkeeping of n after its arches changed.
+func (g *Graph) update(n Node) {
+	hasIns := len(g.ins(n)) > 0
+	if !hasIns && len(g.adjacency[n]) == 0 {
+		delete(g.roots, n)
+		return
+	}
+	g.roots[n] = !hasIns
+}
+
 func (g Graph) Roots() (roots []Node) {
 	for n, root := range g.roots {
 		if root {
@@ -44,6 +199,25 @@ func (g Graph) Adjacents(n Node) []*Arch {
 	return g.adjacency[n]
 }
 
+// Check verifies that the Graph is consistent.
+func (g Graph) Check() error {
+	var err error
+	g.Walk(func(a *Arch) {
+		if err == nil && a.to == nil {
+			err = fmt.Errorf("arch from %v is not connected", a.from)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for n, root := range g.roots {
+		if hasIns := len(g.ins(n)) > 0; root == hasIns {
+			return fmt.Errorf("node %v: inconsistent root", n)
+		}
+	}
+	return nil
+}
+
 type Visitor func(a *Arch)
 
 type nodesByRepr []Node
@@ -101,12 +275,7 @@ func setGraph(ctx context.Context, g Graph) context.Context {
 }
 
 func Context() context.Context {
-	ctx := context.Background()
-	ctx = setGraph(ctx, Graph{
-		adjacency: make(map[Node][]*Arch),
-		roots:     make(map[Node]bool),
-	})
-	return ctx
+	return setGraph(context.Background(), newGraph())
 }
 
 type Link interface {
@@ -120,7 +289,6 @@ type Arch struct {
 	to   Node
 
 	// Fields added by the user.
-
 }
 
 func NewLink(from Node) *Arch {
@@ -129,6 +297,19 @@ func NewLink(from Node) *Arch {
 	}
 }
 
+// newArch creates an Arch with all of its fields.
+func newArch(from, to Node) *Arch {
+	return &Arch{
+		from: from,
+		to:   to,
+	}
+}
+
+// clone copies a, with new ends.
+func (a *Arch) clone(from, to Node) *Arch {
+	return newArch(from, to)
+}
+
 func (a *Arch) From() Node {
 	return a.from
 }
@@ -139,12 +320,7 @@ func (a *Arch) To() Node {
 
 func (a *Arch) Connect(ctx context.Context, node Node) Node {
 	g := getGraph(ctx)
-	clone := &Arch{
-		from: a.from,
-		to:   node,
-		// Fields added by the user.
-
-	}
+	clone := a.clone(a.from, node)
 	g.add(clone)
 	return node
 }
@@ -1,12 +1,16 @@
 package ssp
 
 import (
+	"fmt"
+
 	"github.com/affo/ssp/values"
 )
 
 type Node interface {
 	Do(collector Collector, v values.Value) error
 	Out() *Arch
+	// OutTag provides the stream of values collected to tag via CollectTo.
+	OutTag(tag string) *Arch
 	Clone() Node
 
 	// Options.
@@ -16,9 +20,52 @@ type Node interface {
 	GetName() string
 }
 
+// DeadLetterNode is a Node that provides the stream of the records that made it fail.
+// The nodes in this package implement it.
+type DeadLetterNode interface {
+	Node
+	// DeadLetters provides the stream of records that made this Node fail, if its ErrorPolicy is DeadLetterOnError.
+	DeadLetters() *Arch
+}
+
+// PolicyNode is a Node with an ErrorPolicy.
+// The nodes in this package implement it, other nodes fail on error.
+type PolicyNode interface {
+	Node
+	SetErrorPolicy(p ErrorPolicy) Node
+	GetErrorPolicy() ErrorPolicy
+}
+
+// DeadLetters provides the stream of records that made n fail, if its ErrorPolicy is DeadLetterOnError.
+func DeadLetters(n Node) *Arch {
+	if dn, ok := n.(DeadLetterNode); ok {
+		return dn.DeadLetters()
+	}
+	return n.OutTag(DeadLetterTag)
+}
+
+// GetErrorPolicy returns the ErrorPolicy of n, which is FailOnError for nodes that are not PolicyNodes.
+func GetErrorPolicy(n Node) ErrorPolicy {
+	if pn, ok := n.(PolicyNode); ok {
+		return pn.GetErrorPolicy()
+	}
+	return FailOnError
+}
+
+// SetErrorPolicy sets the ErrorPolicy of n, and returns it.
+// It panics if n is not a PolicyNode.
+func SetErrorPolicy(n Node, p ErrorPolicy) Node {
+	pn, ok := n.(PolicyNode)
+	if !ok {
+		panic(fmt.Sprintf("node %v does not support error policies", nodeToString(n)))
+	}
+	return pn.SetErrorPolicy(p)
+}
+
 type baseNode struct {
 	par  int
 	name string
+	ep   ErrorPolicy
 }
 
 func newBaseNode() baseNode {
@@ -33,6 +80,10 @@ func (n baseNode) GetName() string {
 	return n.name
 }
 
+func (n baseNode) GetErrorPolicy() ErrorPolicy {
+	return n.ep
+}
+
 func (n baseNode) String() string {
 	return n.name
 }
@@ -41,6 +92,7 @@ func (n baseNode) Clone() baseNode {
 	return baseNode{
 		par:  n.par,
 		name: n.name,
+		ep:   n.ep,
 	}
 }
 
@@ -49,18 +101,24 @@ type NodeFunc func(state values.Value, collector Collector, v values.Value) (val
 type AnonymousNode struct {
 	baseNode
 
-	state0 values.Value
-	state  values.Value
-	do     NodeFunc
+	state0   values.Value
+	state    values.Value
+	do       NodeFunc
+	stateful bool
+	ts       Timestamps
+	// chain is set for maps and filters.
+	chain *chain
 }
 
 func NewNode(do func(collector Collector, v values.Value) error) *AnonymousNode {
-	return NewStatefulNode(
+	n := NewStatefulNode(
 		values.NewNull(values.Int64),
 		func(state values.Value, collector Collector, v values.Value) (value values.Value, e error) {
 			return state, do(collector, v)
 		},
 	)
+	n.stateful = false
+	return n
 }
 
 func NewStatefulNode(state0 values.Value, do NodeFunc) *AnonymousNode {
@@ -69,6 +127,7 @@ func NewStatefulNode(state0 values.Value, do NodeFunc) *AnonymousNode {
 		state0:   state0,
 		state:    state0,
 		do:       do,
+		stateful: true,
 	}
 }
 
@@ -85,6 +144,14 @@ func (n *AnonymousNode) Out() *Arch {
 	return NewLink(n)
 }
 
+func (n *AnonymousNode) OutTag(tag string) *Arch {
+	return NewTaggedLink(n, tag)
+}
+
+func (n *AnonymousNode) DeadLetters() *Arch {
+	return n.OutTag(DeadLetterTag)
+}
+
 func (n *AnonymousNode) SetParallelism(par int) Node {
 	n.baseNode.par = par
 	return n
@@ -95,12 +162,47 @@ func (n *AnonymousNode) SetName(name string) Node {
 	return n
 }
 
+func (n *AnonymousNode) SetErrorPolicy(p ErrorPolicy) Node {
+	n.baseNode.ep = p
+	return n
+}
+
+// SetTimestamps declares whether the node emits timestamped values.
+func (n *AnonymousNode) SetTimestamps(ts Timestamps) *AnonymousNode {
+	n.ts = ts
+	return n
+}
+
+func (n *AnonymousNode) GetTimestamps() Timestamps {
+	return n.ts
+}
+
+func (n *AnonymousNode) isStateful() bool {
+	return n.stateful
+}
+
+func (n *AnonymousNode) checkpoint(v values.Value) interface{} {
+	if !n.stateful {
+		return nil
+	}
+	return n.state.Clone()
+}
+
+func (n *AnonymousNode) restore(cp interface{}) {
+	if cp != nil {
+		n.state = cp.(values.Value)
+	}
+}
+
 func (n *AnonymousNode) Clone() Node {
 	return &AnonymousNode{
 		baseNode: n.baseNode.Clone(),
 		state0:   n.state0,
 		state:    n.state0,
 		do:       n.do,
+		stateful: n.stateful,
+		ts:       n.ts,
+		chain:    n.chain,
 	}
 }
 
@@ -47,3 +47,44 @@ func Test_Node_Error(t *testing.T) {
 		t.Fatal("expected error got none")
 	}
 }
+
+// plainNode implements Node only.
+type plainNode struct {
+	name string
+}
+
+func (n *plainNode) Do(collector Collector, v values.Value) error {
+	CollectTo(collector, "even", v)
+	return nil
+}
+
+func (n *plainNode) Out() *Arch                  { return NewLink(n) }
+func (n *plainNode) OutTag(tag string) *Arch     { return NewTaggedLink(n, tag) }
+func (n *plainNode) Clone() Node                 { return n }
+func (n *plainNode) SetParallelism(par int) Node { return n }
+func (n *plainNode) GetParallelism() int         { return 1 }
+func (n *plainNode) SetName(name string) Node    { n.name = name; return n }
+func (n *plainNode) GetName() string             { return n.name }
+func (n *plainNode) String() string              { return n.name }
+
+func Test_Node_Plain(t *testing.T) {
+	ctx := Context()
+	plain := newSliceSource(ints(1, 2)...).Out().Connect(ctx, &plainNode{name: "plain"})
+	sink, log := NewLogSink(values.Int)
+	plain.OutTag("even").Connect(ctx, sink.SetName("sink"))
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1", "2"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if p := GetErrorPolicy(plain); p != FailOnError {
+		t.Errorf("unexpected error policy: %v", p)
+	}
+	defer func() {
+		if r := recover(); r != `node plain does not support error policies` {
+			t.Errorf("unexpected panic: %v", r)
+		}
+	}()
+	SetErrorPolicy(plain, SkipOnError)
+}
@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+)
+
+var builtinTypes = map[string]Factory{
+	"file-source":  newFileSource,
+	"file-sink":    newFileSink,
+	"grep":         newGrep,
+	"split":        newSplit,
+	"count-values": newCountValues,
+}
+
+var builtinWindowedTypes = map[string]Factory{
+	"aggregate": newAggregate,
+}
+
+var builtinKeySelectors = map[string]ssp.KeySelector{
+	"value": ssp.NewStringValueKeySelector(func(v values.Value) string {
+		return v.String()
+	}),
+	"fixed": ssp.NewFixedKeySelector(),
+}
+
+// newFileSource emits the lines of the file at "path" as strings.
+// The path "-" is the standard input.
+func newFileSource(cfg Config) (ssp.Node, error) {
+	path, err := cfg.Require("path")
+	if err != nil {
+		return nil, err
+	}
+	return ssp.NewNode(func(collector ssp.Collector, _ values.Value) error {
+		var r io.Reader = os.Stdin
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			collector.Collect(values.New(s.Text()))
+		}
+		return s.Err()
+	}).SetTimestamps(ssp.NoTimestamps), nil
+}
+
+// newFileSink writes values to the file at "path", one per line.
+// The file is truncated, unless "append" is "true". The path "-" is the standard output.
+// The file is opened when the node is built, so that a run that emits nothing leaves it empty.
+func newFileSink(cfg Config) (ssp.Node, error) {
+	path, err := cfg.Require("path")
+	if err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if cfg.Get("append", "false") == "true" {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f := os.Stdout
+	if path != "-" {
+		if f, err = os.OpenFile(path, flags, 0644); err != nil {
+			return nil, err
+		}
+	}
+	// Instances of the sink share the file.
+	var mu sync.Mutex
+	w := bufio.NewWriter(f)
+	cfg.OnRelease(func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if f == os.Stdout {
+			return nil
+		}
+		return f.Close()
+	})
+	return ssp.NewNode(func(_ ssp.Collector, v values.Value) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := fmt.Fprintln(w, v)
+		return err
+	}), nil
+}
+
+// newGrep forwards the string values that match the regular expression "pattern".
+func newGrep(cfg Config) (ssp.Node, error) {
+	pattern, err := cfg.Require("pattern")
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return ssp.NewNode(func(collector ssp.Collector, v values.Value) error {
+		if s := v.String(); re.MatchString(s) {
+			collector.Collect(output(v, values.New(s)))
+		}
+		return nil
+	}).SetTimestamps(ssp.KeepsTimestamps), nil
+}
+
+// newSplit emits the words of string values, split around white space.
+func newSplit(cfg Config) (ssp.Node, error) {
+	return ssp.NewNode(func(collector ssp.Collector, v values.Value) error {
+		for _, w := range strings.Fields(v.String()) {
+			collector.Collect(output(v, values.New(w)))
+		}
+		return nil
+	}).SetTimestamps(ssp.KeepsTimestamps), nil
+}
+
+// newCountValues emits every value with the number of times it has been seen, as "value,count".
+// Values are counted by their string representation.
+func newCountValues(cfg Config) (ssp.Node, error) {
+	// Counts are created at the first value, because the initial state is shared by every instance of the node.
+	return ssp.NewStatefulNode(values.NewNull(values.Object),
+		func(state values.Value, collector ssp.Collector, v values.Value) (values.Value, error) {
+			counts, ok := state.Get().(map[string]int)
+			if !ok {
+				counts = make(map[string]int)
+				state = values.New(counts)
+			}
+			s := v.String()
+			counts[s]++
+			collector.Collect(output(v, values.New(fmt.Sprintf("%s,%d", s, counts[s]))))
+			return state, nil
+		}).SetTimestamps(ssp.KeepsTimestamps), nil
+}
+
+var aggregators = map[string]func() ssp.Aggregator{
+	"sum":   ssp.Sum,
+	"count": ssp.Count,
+	"min":   ssp.Min,
+	"max":   ssp.Max,
+	"avg":   ssp.Avg,
+}
+
+// newAggregate aggregates values with the "aggregator" sum, count, min, max or avg,
+// in windows if its inputs are windowed.
+func newAggregate(cfg Config) (ssp.Node, error) {
+	name, err := cfg.Require("aggregator")
+	if err != nil {
+		return nil, err
+	}
+	agg, ok := aggregators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown aggregator %q", name)
+	}
+	if w := cfg.Window; w != nil {
+		return ssp.NewWindowAggregateNode(w.Size, w.Slide, agg()), nil
+	}
+	return ssp.NewAggregateNode(agg()), nil
+}
+
+// output gives out the timestamp of in, if any.
+func output(in, out values.Value) values.Value {
+	if ts, wm, err := values.GetTime(in); err == nil {
+		return values.SetTime(ts, wm, out)
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+// Package pipeline builds ssp graphs from declarative specs, written in JSON or YAML.
+//
+// Nodes in a spec reference types registered in a Registry, together with their configuration.
+// Arches can key values with registered key selectors, and group them in windows.
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes a pipeline as data.
+type Spec struct {
+	Name   string     `json:"name" yaml:"name"`
+	Nodes  []NodeSpec `json:"nodes" yaml:"nodes"`
+	Arches []ArchSpec `json:"arches" yaml:"arches"`
+}
+
+// NodeSpec describes a node of a pipeline.
+// Type selects the factory that creates the node, and Params configure it.
+type NodeSpec struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Type        string                 `json:"type" yaml:"type"`
+	Parallelism int                    `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	Params      map[string]interface{} `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// ArchSpec describes an arch between two nodes of a pipeline.
+// If Tag is set, the arch carries the values collected to Tag by From.
+// If KeyBy is set, values are keyed with the registered key selector with that name.
+// If Window is set, To groups values in windows: every input of To must have the same window.
+type ArchSpec struct {
+	From   string      `json:"from" yaml:"from"`
+	To     string      `json:"to" yaml:"to"`
+	Tag    string      `json:"tag,omitempty" yaml:"tag,omitempty"`
+	KeyBy  string      `json:"keyBy,omitempty" yaml:"keyBy,omitempty"`
+	Window *WindowSpec `json:"window,omitempty" yaml:"window,omitempty"`
+}
+
+// WindowSpec describes fixed windows.
+// Windows are tumbling if Slide is not set.
+type WindowSpec struct {
+	Size  int `json:"size" yaml:"size"`
+	Slide int `json:"slide,omitempty" yaml:"slide,omitempty"`
+}
+
+func (w WindowSpec) String() string {
+	return fmt.Sprintf("size %d, slide %d", w.Size, w.Slide)
+}
+
+// Parse parses a JSON spec.
+func Parse(data []byte) (*Spec, error) {
+	s := &Spec{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("cannot parse spec: %v", err)
+	}
+	return s, nil
+}
+
+// ParseYAML parses a YAML spec.
+func ParseYAML(data []byte) (*Spec, error) {
+	s := &Spec{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("cannot parse spec: %v", err)
+	}
+	return s, nil
+}
+
+// Load reads and parses the spec at path.
+// Files with the .yaml or .yml extension are parsed as YAML, the others as JSON.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	default:
+		return Parse(data)
+	}
+}
+
+// Build adds the nodes and arches described by s to the Graph in ctx, using the types in the DefaultRegistry.
+// The returned function releases the resources held by the nodes, like open files,
+// and must be called once the Graph has been executed.
+func (s *Spec) Build(ctx context.Context) (release func() error, err error) {
+	return DefaultRegistry().Build(ctx, s)
+}
@@ -0,0 +1,255 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("the quick fox\njumps over\nthe lazy dog\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse([]byte(`{
+  "name": "words",
+  "nodes": [
+    {"name": "lines", "type": "file-source", "params": {"path": "` + in + `"}},
+    {"name": "words", "type": "split", "parallelism": 2},
+    {"name": "the", "type": "grep", "params": {"pattern": "^(the|fox)$"}},
+    {"name": "out", "type": "file-sink", "params": {"path": "` + out + `"}}
+  ],
+  "arches": [
+    {"from": "lines", "to": "words"},
+    {"from": "words", "to": "the"},
+    {"from": "the", "to": "out"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ssp.Context()
+	release, err := s.Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"fox", "the", "the"}, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	want := `lines -> words
+the -> out
+words -> the
+`
+	if diff := cmp.Diff(want, ssp.GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestBuild_WordCount(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("the quick fox\nthe lazy dog\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseYAML([]byte(`
+name: words
+nodes:
+  - name: lines
+    type: file-source
+    params:
+      path: ` + in + `
+  - name: words
+    type: split
+  - name: count
+    type: count-values
+    parallelism: 4
+  - name: out
+    type: file-sink
+    params:
+      path: ` + out + `
+arches:
+  - from: lines
+    to: words
+  - from: words
+    to: count
+    keyBy: value
+  - from: count
+    to: out
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ssp.Context()
+	release, err := s.Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	sort.Strings(got)
+	want := []string{"dog,1", "fox,1", "lazy,1", "quick,1", "the,1", "the,2"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestBuild_FileSinkTruncates(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(in, []byte("no match\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("previous run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse([]byte(`{
+  "nodes": [
+    {"name": "lines", "type": "file-source", "params": {"path": "` + in + `"}},
+    {"name": "the", "type": "grep", "params": {"pattern": "^the$"}},
+    {"name": "out", "type": "file-sink", "params": {"path": "` + out + `"}}
+  ],
+  "arches": [
+    {"from": "lines", "to": "the"},
+    {"from": "the", "to": "out"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ssp.Context()
+	release, err := s.Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	// The run emits nothing, and the output of the previous one is gone.
+	bs, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("", string(bs)); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "parse",
+			spec: `{"nodes": [`,
+			want: "cannot parse spec: unexpected end of JSON input",
+		},
+		{
+			name: "unknown type",
+			spec: `{"nodes": [{"name": "a", "type": "nope"}]}`,
+			want: `node "a": unknown type "nope"`,
+		},
+		{
+			name: "no name",
+			spec: `{"nodes": [{"type": "split"}]}`,
+			want: `node of type "split" has no name`,
+		},
+		{
+			name: "duplicate name",
+			spec: `{"nodes": [{"name": "a", "type": "split"}, {"name": "a", "type": "split"}]}`,
+			want: `node "a": duplicate name`,
+		},
+		{
+			name: "missing parameter",
+			spec: `{"nodes": [{"name": "a", "type": "grep"}]}`,
+			want: `node "a": missing parameter "pattern"`,
+		},
+		{
+			name: "unknown node",
+			spec: `{"nodes": [{"name": "a", "type": "split"}], "arches": [{"from": "a", "to": "b"}]}`,
+			want: `arch a -> b: unknown node "b"`,
+		},
+		{
+			name: "not connected",
+			spec: `{"nodes": [{"name": "a", "type": "split"}, {"name": "b", "type": "split"}, {"name": "c", "type": "split"}],
+"arches": [{"from": "a", "to": "b"}]}`,
+			want: `node "c": not connected`,
+		},
+		{
+			name: "unknown key selector",
+			spec: `{"nodes": [{"name": "a", "type": "split"}, {"name": "b", "type": "split"}],
+"arches": [{"from": "a", "to": "b", "keyBy": "nope"}]}`,
+			want: `arch a -> b: unknown key selector "nope"`,
+		},
+		{
+			name: "window not supported",
+			spec: `{"nodes": [{"name": "a", "type": "split"}, {"name": "b", "type": "split"}],
+"arches": [{"from": "a", "to": "b", "window": {"size": 2}}]}`,
+			want: `node "b": type "split" does not support windows`,
+		},
+		{
+			name: "invalid window",
+			spec: `{"nodes": [{"name": "a", "type": "split"}, {"name": "b", "type": "aggregate"}],
+"arches": [{"from": "a", "to": "b", "window": {"size": 2, "slide": -1}}]}`,
+			want: `arch a -> b: window size and slide must be positive, got size 2, slide -1`,
+		},
+		{
+			name: "different windows",
+			spec: `{"nodes": [{"name": "a", "type": "split"}, {"name": "b", "type": "split"}, {"name": "c", "type": "aggregate"}],
+"arches": [{"from": "a", "to": "c", "window": {"size": 2}}, {"from": "b", "to": "c"}]}`,
+			want: `node "c": inputs have different windows: size 2, slide 2 and none`,
+		},
+		{
+			name: "unknown aggregator",
+			spec: `{"nodes": [{"name": "a", "type": "aggregate", "params": {"aggregator": "median"}}]}`,
+			want: `node "a": unknown aggregator "median"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse([]byte(tc.spec))
+			if err == nil {
+				_, err = s.Build(ssp.Context())
+			}
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+			if diff := cmp.Diff(tc.want, err.Error()); diff != "" {
+				t.Errorf("unexpected error -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/affo/ssp"
+)
+
+// Config is the configuration of a node, passed to its factory.
+type Config struct {
+	// Name is the name of the node.
+	Name   string
+	Params map[string]interface{}
+	// Window is the window of the inputs of the node, if any.
+	// It is only set for types registered with RegisterWindowed.
+	Window   *WindowSpec
+	releases *[]func() error
+}
+
+// Get returns the value of the parameter key as a string, or def if it is not set.
+func (c Config) Get(key, def string) string {
+	if v, ok := c.Params[key]; ok {
+		return fmt.Sprint(v)
+	}
+	return def
+}
+
+// Require returns the value of the parameter key as a string, or an error if it is not set.
+func (c Config) Require(key string) (string, error) {
+	v, ok := c.Params[key]
+	if !ok {
+		return "", fmt.Errorf("missing parameter %q", key)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// Int returns the value of the parameter key as an int, or def if it is not set.
+func (c Config) Int(key string, def int) (int, error) {
+	v, ok := c.Params[key]
+	if !ok {
+		return def, nil
+	}
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case float64:
+		// JSON numbers.
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("parameter %q must be an integer, got %v", key, v)
+}
+
+// OnRelease registers f to be called when the pipeline is released.
+func (c Config) OnRelease(f func() error) {
+	*c.releases = append(*c.releases, f)
+}
+
+// Factory creates a node from its configuration.
+type Factory func(cfg Config) (ssp.Node, error)
+
+type nodeType struct {
+	f        Factory
+	windowed bool
+}
+
+// Registry holds the node types and key selectors that specs can reference.
+type Registry struct {
+	mu           sync.RWMutex
+	types        map[string]nodeType
+	keySelectors map[string]ssp.KeySelector
+}
+
+// NewRegistry returns a Registry with the builtin types and key selectors.
+//
+// Builtin types are:
+//   - "file-source", that emits the lines of the file at "path" as strings;
+//   - "file-sink", that writes values to the file at "path", one per line;
+//   - "grep", that forwards the values that match the regular expression "pattern";
+//   - "split", that emits the words in values;
+//   - "count-values", that emits every value with the number of times it has been seen, as "value,count";
+//   - "aggregate", windowed, that aggregates values with the "aggregator" sum, count, min, max or avg.
+//
+// Builtin key selectors are:
+//   - "value", that keys values by their string representation;
+//   - "fixed", that sends every value to the same key.
+func NewRegistry() *Registry {
+	r := &Registry{
+		types:        make(map[string]nodeType),
+		keySelectors: make(map[string]ssp.KeySelector),
+	}
+	for typ, f := range builtinTypes {
+		_ = r.Register(typ, f)
+	}
+	for typ, f := range builtinWindowedTypes {
+		_ = r.RegisterWindowed(typ, f)
+	}
+	for name, ks := range builtinKeySelectors {
+		_ = r.RegisterKeySelector(name, ks)
+	}
+	return r
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the Registry used by Spec.Build.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register makes the nodes created by f available as type typ in the DefaultRegistry.
+// It panics if typ is already registered.
+func Register(typ string, f Factory) {
+	if err := defaultRegistry.Register(typ, f); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterWindowed is like Register for types that support windows.
+func RegisterWindowed(typ string, f Factory) {
+	if err := defaultRegistry.RegisterWindowed(typ, f); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterKeySelector makes ks available with the given name in the DefaultRegistry.
+// It panics if name is already registered.
+func RegisterKeySelector(name string, ks ssp.KeySelector) {
+	if err := defaultRegistry.RegisterKeySelector(name, ks); err != nil {
+		panic(err)
+	}
+}
+
+// Register makes the nodes created by f available as type typ.
+func (r *Registry) Register(typ string, f Factory) error {
+	return r.register(typ, nodeType{f: f})
+}
+
+// RegisterWindowed makes the nodes created by f available as type typ.
+// Nodes of type typ can be fed by windowed arches, and f gets the window in Config.Window.
+func (r *Registry) RegisterWindowed(typ string, f Factory) error {
+	return r.register(typ, nodeType{f: f, windowed: true})
+}
+
+func (r *Registry) register(typ string, t nodeType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[typ]; ok {
+		return fmt.Errorf("type %q already registered", typ)
+	}
+	r.types[typ] = t
+	return nil
+}
+
+// RegisterKeySelector makes ks available with the given name.
+func (r *Registry) RegisterKeySelector(name string, ks ssp.KeySelector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keySelectors[name]; ok {
+		return fmt.Errorf("key selector %q already registered", name)
+	}
+	r.keySelectors[name] = ks
+	return nil
+}
+
+// Build adds the nodes and arches described by s to the Graph in ctx.
+// The returned function releases the resources held by the nodes, like open files,
+// and must be called once the Graph has been executed.
+func (r *Registry) Build(ctx context.Context, s *Spec) (release func() error, err error) {
+	var releases []func() error
+	releaseAll := func() error {
+		var errs ssp.Errors
+		for _, r := range releases {
+			if err := r(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			_ = releaseAll()
+		}
+	}()
+
+	windows, err := inputWindows(s)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]ssp.Node, len(s.Nodes))
+	for _, ns := range s.Nodes {
+		if ns.Name == "" {
+			return nil, fmt.Errorf("node of type %q has no name", ns.Type)
+		}
+		if _, ok := nodes[ns.Name]; ok {
+			return nil, fmt.Errorf("node %q: duplicate name", ns.Name)
+		}
+		t, ok := r.nodeType(ns.Type)
+		if !ok {
+			return nil, fmt.Errorf("node %q: unknown type %q", ns.Name, ns.Type)
+		}
+		w := windows[ns.Name]
+		if w != nil && !t.windowed {
+			return nil, fmt.Errorf("node %q: type %q does not support windows", ns.Name, ns.Type)
+		}
+		n, err := t.f(Config{Name: ns.Name, Params: ns.Params, Window: w, releases: &releases})
+		if err != nil {
+			return nil, fmt.Errorf("node %q: %v", ns.Name, err)
+		}
+		n.SetName(ns.Name)
+		if ns.Parallelism != 0 {
+			n.SetParallelism(ns.Parallelism)
+		}
+		nodes[ns.Name] = n
+	}
+	connected := make(map[string]bool, len(nodes))
+	for _, as := range s.Arches {
+		from, ok := nodes[as.From]
+		if !ok {
+			return nil, fmt.Errorf("arch %s -> %s: unknown node %q", as.From, as.To, as.From)
+		}
+		to, ok := nodes[as.To]
+		if !ok {
+			return nil, fmt.Errorf("arch %s -> %s: unknown node %q", as.From, as.To, as.To)
+		}
+		a := from.Out()
+		if as.Tag != "" {
+			a = from.OutTag(as.Tag)
+		}
+		if as.KeyBy != "" {
+			ks, ok := r.keySelector(as.KeyBy)
+			if !ok {
+				return nil, fmt.Errorf("arch %s -> %s: unknown key selector %q", as.From, as.To, as.KeyBy)
+			}
+			a = a.KeyBy(ks)
+		}
+		a.Connect(ctx, to)
+		connected[as.From], connected[as.To] = true, true
+	}
+	for _, ns := range s.Nodes {
+		if !connected[ns.Name] {
+			return nil, fmt.Errorf("node %q: not connected", ns.Name)
+		}
+	}
+	return releaseAll, nil
+}
+
+// nodeType returns the type registered as typ.
+// Factories are called without holding the lock, so that they can use the Registry.
+func (r *Registry) nodeType(typ string) (nodeType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[typ]
+	return t, ok
+}
+
+// keySelector returns the key selector registered as name.
+func (r *Registry) keySelector(name string) (ssp.KeySelector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ks, ok := r.keySelectors[name]
+	return ks, ok
+}
+
+// inputWindows returns the window of the inputs of every node fed by windowed arches.
+func inputWindows(s *Spec) (map[string]*WindowSpec, error) {
+	windows := make(map[string]*WindowSpec)
+	for _, as := range s.Arches {
+		if as.Window == nil {
+			continue
+		}
+		w := *as.Window
+		if w.Slide == 0 {
+			w.Slide = w.Size
+		}
+		if w.Size <= 0 || w.Slide <= 0 {
+			return nil, fmt.Errorf("arch %s -> %s: window size and slide must be positive, got %v", as.From, as.To, w)
+		}
+		if prev, ok := windows[as.To]; ok && *prev != w {
+			return nil, fmt.Errorf("node %q: inputs have different windows: %v and %v", as.To, *prev, w)
+		}
+		windows[as.To] = &w
+	}
+	for _, as := range s.Arches {
+		if _, ok := windows[as.To]; ok && as.Window == nil {
+			return nil, fmt.Errorf("node %q: inputs have different windows: %v and none", as.To, *windows[as.To])
+		}
+	}
+	return windows, nil
+}
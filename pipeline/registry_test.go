@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	// "events" emits n integers, timestamped with their value.
+	if err := r.Register("events", func(cfg Config) (ssp.Node, error) {
+		n, err := cfg.Int("n", 0)
+		if err != nil {
+			return nil, err
+		}
+		return ssp.NewNode(func(collector ssp.Collector, _ values.Value) error {
+			for i := 0; i < n; i++ {
+				ts := values.Timestamp(i)
+				collector.Collect(values.SetTime(ts, ts, values.New(i)))
+			}
+			return nil
+		}).SetTimestamps(ssp.WithTimestamps), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var got []string
+	if err := r.Register("collect", func(cfg Config) (ssp.Node, error) {
+		prefix := cfg.Get("prefix", "")
+		return ssp.NewNode(func(_ ssp.Collector, v values.Value) error {
+			ts, _, _ := values.GetTime(v)
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, fmt.Sprintf("%s%v@%d", prefix, v, ts))
+			return nil
+		}), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterKeySelector("parity", ssp.FnKeySelector(func(v values.Value) values.Key {
+		return values.Key(v.Int() % 2)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ParseYAML([]byte(`
+name: sums
+nodes:
+  - name: events
+    type: events
+    params:
+      n: 10
+  - name: sum
+    type: aggregate
+    parallelism: 2
+    params:
+      aggregator: sum
+  - name: out
+    type: collect
+    params:
+      prefix: "sum "
+arches:
+  - from: events
+    to: sum
+    keyBy: parity
+    window:
+      size: 4
+  - from: sum
+    to: out
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ssp.Context()
+	release, err := r.Build(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	// The last window does not close.
+	want := []string{"sum 10@7", "sum 12@7", "sum 2@3", "sum 4@3"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	wantGraph := `events -> sum
+sum -> out
+`
+	if diff := cmp.Diff(wantGraph, ssp.GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+
+	t.Run("factory using the registry", func(t *testing.T) {
+		r := NewRegistry()
+		// The factory registers a type on first use, without deadlocking.
+		if err := r.Register("lazy", func(cfg Config) (ssp.Node, error) {
+			_ = r.Register("lazy-"+cfg.Name, nil)
+			return ssp.NewNode(func(ssp.Collector, values.Value) error {
+				return nil
+			}), nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		s, err := ParseYAML([]byte(`
+nodes:
+  - name: a
+    type: lazy
+  - name: b
+    type: lazy
+arches:
+  - from: a
+    to: b
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Build(ssp.Context(), s); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Register("lazy-a", nil); err == nil {
+			t.Error("expected the factory to register a type")
+		}
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		if err := r.Register("events", nil); err == nil || err.Error() != `type "events" already registered` {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := r.RegisterWindowed("aggregate", nil); err == nil || err.Error() != `type "aggregate" already registered` {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := r.RegisterKeySelector("parity", nil); err == nil || err.Error() != `key selector "parity" already registered` {
+			t.Errorf("unexpected error: %v", err)
+		}
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected panic, got none")
+			}
+		}()
+		Register("split", nil)
+	})
+}
+
+func TestConfig_Int(t *testing.T) {
+	cfg := Config{Params: map[string]interface{}{"int": 1, "float": 2.0, "string": "3", "bad": 1.5}}
+	var got []int
+	for _, k := range []string{"int", "float", "string", "missing"} {
+		i, err := cfg.Int(k, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, i)
+	}
+	if diff := cmp.Diff([]int{1, 2, 3, 4}, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if _, err := cfg.Int("bad", 0); err == nil || !strings.Contains(err.Error(), `parameter "bad" must be an integer`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
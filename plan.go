@@ -0,0 +1,507 @@
+package ssp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/affo/ssp/values"
+)
+
+// Rule rewrites a logical plan into a more efficient one, with the same results.
+type Rule struct {
+	name  string
+	apply func(p *planGraph) []string
+}
+
+func (r Rule) Name() string {
+	return r.name
+}
+
+// FilterPushdown moves filters that follow a Union before it, so that values are dropped as early as possible.
+func FilterPushdown() Rule {
+	return Rule{name: "filter-pushdown", apply: pushDownFilters}
+}
+
+// MapFilterFusion fuses chains of maps and filters into single nodes, when they have the same parallelism.
+func MapFilterFusion() Rule {
+	return Rule{name: "map-filter-fusion", apply: fuseMapsAndFilters}
+}
+
+// RedundantKeyByRemoval avoids keying values again with a KeySelector equal to the one they were keyed with,
+// when only filters are in between.
+func RedundantKeyByRemoval() Rule {
+	return Rule{name: "redundant-keyby-removal", apply: removeRedundantKeyBys}
+}
+
+// PreAggregation aggregates values in tumbling windows before they get shuffled by key,
+// and merges the partial results after the shuffle.
+// It applies to aggregators that implement MergeableAggregator, fed by nodes with parallelism 1.
+func PreAggregation() Rule {
+	return Rule{name: "pre-aggregation", apply: preAggregate}
+}
+
+// DefaultRules returns every rule, in the order in which they are applied by default.
+func DefaultRules() []Rule {
+	return []Rule{FilterPushdown(), MapFilterFusion(), RedundantKeyByRemoval(), PreAggregation()}
+}
+
+// Rewrite describes the application of a Rule.
+type Rewrite struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+}
+
+func (r Rewrite) String() string {
+	return fmt.Sprintf("%s: %s", r.Rule, r.Description)
+}
+
+// Plan is the result of planning a logical Graph.
+type Plan struct {
+	Logical  Graph
+	Physical Graph
+	Rewrites []Rewrite
+}
+
+func (p *Plan) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("rewrites:\n")
+	for _, r := range p.Rewrites {
+		sb.WriteString("  ")
+		sb.WriteString(r.String())
+		sb.WriteRune('\n')
+	}
+	sb.WriteString("physical:\n")
+	for _, l := range strings.SplitAfter(p.Physical.String(), "\n") {
+		if l != "" {
+			sb.WriteString("  ")
+			sb.WriteString(l)
+		}
+	}
+	return sb.String()
+}
+
+// Planner turns a logical Graph, as built by the user, into a physical one, that gets executed.
+type Planner struct {
+	rules []Rule
+}
+
+// NewPlanner returns a planner that applies rules in order.
+// Every rule is applied until it rewrites nothing anymore.
+func NewPlanner(rules ...Rule) *Planner {
+	return &Planner{rules: rules}
+}
+
+// DefaultPlanner returns a planner with the DefaultRules.
+func DefaultPlanner() *Planner {
+	return NewPlanner(DefaultRules()...)
+}
+
+// Plan plans g. The logical Graph is left untouched: rewritten nodes are replaced by new ones in the physical Graph.
+func (p *Planner) Plan(g Graph) *Plan {
+	pg := newPlanGraph(g)
+	plan := &Plan{Logical: g}
+	for _, r := range p.rules {
+		for {
+			ds := r.apply(pg)
+			if len(ds) == 0 {
+				break
+			}
+			for _, d := range ds {
+				plan.Rewrites = append(plan.Rewrites, Rewrite{Rule: r.name, Description: d})
+			}
+		}
+	}
+	plan.Physical = pg.graph()
+	return plan
+}
+
+// planGraph is a Graph under rewriting.
+type planGraph struct {
+	arches []*Arch
+}
+
+func newPlanGraph(g Graph) *planGraph {
+	p := &planGraph{}
+	g.Walk(func(a *Arch) {
+		p.arches = append(p.arches, a.clone(a.From(), a.To()))
+	})
+	return p
+}
+
+func (p *planGraph) add(from, to Node, ks KeySelector, tag string) *Arch {
+	a := newArch(from, to, ks, tag)
+	p.arches = append(p.arches, a)
+	return a
+}
+
+func (p *planGraph) remove(a *Arch) {
+	for i, b := range p.arches {
+		if a == b {
+			p.arches = append(p.arches[:i], p.arches[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *planGraph) ins(n Node) []*Arch {
+	var as []*Arch
+	for _, a := range p.arches {
+		if a.To() == n {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+func (p *planGraph) outs(n Node) []*Arch {
+	var as []*Arch
+	for _, a := range p.arches {
+		if a.From() == n {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// replace makes the arches of n start from or end in m.
+func (p *planGraph) replace(n, m Node) {
+	for _, a := range p.arches {
+		if a.from == n {
+			a.from = m
+		}
+		if a.to == n {
+			a.to = m
+		}
+	}
+}
+
+func (p *planGraph) graph() Graph {
+	g := newGraph()
+	for _, a := range p.arches {
+		g.add(a)
+	}
+	return g
+}
+
+// chainOf returns the chain of maps and filters of n, if n can be rewritten.
+// Nodes that send values back to an Iteration are not rewritten, because the Iteration refers to them.
+func (p *planGraph) chainOf(n Node) (*chain, bool) {
+	an, ok := n.(*AnonymousNode)
+	if !ok || an.chain == nil {
+		return nil, false
+	}
+	for _, a := range p.outs(n) {
+		if _, ok := a.To().(*iterationHead); ok {
+			return nil, false
+		}
+	}
+	return an.chain, true
+}
+
+// rebuild creates a chain node with the options of n.
+func rebuild(c chain, n Node, name string) Node {
+	return newChainNode(c).
+		SetErrorPolicy(GetErrorPolicy(n)).
+		SetName(name).
+		SetParallelism(n.GetParallelism())
+}
+
+func pushDownFilters(p *planGraph) []string {
+	for _, a := range p.arches {
+		u, ok := a.From().(*unionNode)
+		if !ok || a.tag != "" || a.ks != nil || len(p.outs(u)) != 1 {
+			continue
+		}
+		f := a.To()
+		c, ok := p.chainOf(f)
+		if !ok || !c.filtersOnly() || len(p.ins(f)) != 1 {
+			continue
+		}
+		// The union would take over the tagged outputs and dead letters of the filter, and never emit on them.
+		if hasTagged(p.outs(f)) {
+			continue
+		}
+		// The filter used to strip origins, so the new union does too.
+		nu := u.Clone().(*unionNode)
+		nu.strip = true
+		nu.ins = nil
+		for i, in := range u.sortInputs(p.ins(u)) {
+			// Filters are named after the position of the input of the union they process.
+			nf := rebuild(*c, f, fmt.Sprintf("%s[%d]", f.GetName(), i))
+			p.add(in.From(), nf, in.ks, in.tag)
+			nu.ins = append(nu.ins, p.add(nf, nu, nil, ""))
+			p.remove(in)
+		}
+		p.remove(a)
+		p.replace(f, nu)
+		return []string{fmt.Sprintf("%v before %v", nodeToString(f), nodeToString(u))}
+	}
+	return nil
+}
+
+func fuseMapsAndFilters(p *planGraph) []string {
+	for _, a := range p.arches {
+		from, to := a.From(), a.To()
+		fc, ok := p.chainOf(from)
+		if !ok || fc.keepKeys {
+			continue
+		}
+		tc, ok := p.chainOf(to)
+		if !ok || tc.keepKeys {
+			continue
+		}
+		if a.tag != "" || a.ks != nil || len(p.outs(from)) != 1 || len(p.ins(to)) != 1 ||
+			from.GetParallelism() != to.GetParallelism() || GetErrorPolicy(from) != GetErrorPolicy(to) {
+			continue
+		}
+		// Errors of both nodes would go to the dead letters of the fused one.
+		if hasTagged(p.outs(to)) {
+			continue
+		}
+		c := chain{steps: append(append([]step{}, fc.steps...), tc.steps...)}
+		name := nodeToString(from) + "+" + nodeToString(to)
+		fused := rebuild(c, from, name)
+		p.remove(a)
+		p.replace(from, fused)
+		p.replace(to, fused)
+		return []string{fmt.Sprintf("%v and %v into %v", nodeToString(from), nodeToString(to), name)}
+	}
+	return nil
+}
+
+// keptKeySelector returns the key that values already have.
+type keptKeySelector struct{}
+
+func (keptKeySelector) GetKey(v values.Value) values.Key {
+	k, _ := values.GetKey(v)
+	return k
+}
+
+func (keptKeySelector) String() string {
+	return "keptKeySelector"
+}
+
+// sameKeySelector returns true if a and b are known to be the same KeySelector.
+func sameKeySelector(a, b KeySelector) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func removeRedundantKeyBys(p *planGraph) []string {
+	for _, n := range nodesOf(p) {
+		c, ok := p.chainOf(n)
+		if !ok || c.keepKeys || !c.filtersOnly() {
+			continue
+		}
+		ins, outs := p.ins(n), p.outs(n)
+		if len(ins) != 1 || len(outs) != 1 || ins[0].ks == nil || outs[0].ks == nil || outs[0].tag != "" ||
+			!sameKeySelector(ins[0].ks, outs[0].ks) {
+			continue
+		}
+		kc := *c
+		kc.keepKeys = true
+		nn := rebuild(kc, n, n.GetName())
+		p.replace(n, nn)
+		outs[0].ks = keptKeySelector{}
+		return []string{fmt.Sprintf("%v keeps the keys of its input", nodeToString(n))}
+	}
+	return nil
+}
+
+func preAggregate(p *planGraph) []string {
+	for _, a := range p.arches {
+		w, ok := a.To().(*windowedNode)
+		if !ok || w.agg == nil || w.size != w.slide || len(p.ins(w)) != 1 {
+			continue
+		}
+		agg, ok := w.agg.(MergeableAggregator)
+		if !ok || a.ks == nil || a.From().GetParallelism() != 1 {
+			continue
+		}
+		if _, ok := a.ks.(keptKeySelector); ok {
+			continue
+		}
+		pre := newPreAggregateNode(w.size, a.ks, agg).SetName("pre-" + nodeToString(w))
+		final := newWindowAggregateNode(w.size, w.slide, agg, agg.Merge).
+			SetName(w.GetName()).
+			SetParallelism(w.GetParallelism())
+		SetErrorPolicy(final, w.GetErrorPolicy())
+		p.add(a.From(), pre, nil, a.tag)
+		p.add(pre, final, keptKeySelector{}, "")
+		p.remove(a)
+		p.replace(w, final)
+		return []string{fmt.Sprintf("%v before %v", nodeToString(pre), nodeToString(w))}
+	}
+	return nil
+}
+
+func hasTagged(as []*Arch) bool {
+	for _, a := range as {
+		if a.tag != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// nodesOf returns the nodes in p, in order of appearance.
+func nodesOf(p *planGraph) []Node {
+	var ns []Node
+	seen := make(map[Node]bool)
+	for _, a := range p.arches {
+		for _, n := range []Node{a.From(), a.To()} {
+			if !seen[n] {
+				seen[n] = true
+				ns = append(ns, n)
+			}
+		}
+	}
+	return ns
+}
+
+// preAggregateNode aggregates values in tumbling windows per key,
+// and emits the accumulators keyed, when windows close.
+// Windows of a key close when values for that key arrive, as they would after the shuffle.
+type preAggregateNode struct {
+	baseNode
+	size int
+	ks   KeySelector
+	agg  Aggregator
+	// windows are created at the first value, because they are not shared among clones.
+	windows map[values.Key]*FixedWindowManager
+}
+
+func newPreAggregateNode(size int, ks KeySelector, agg Aggregator) Node {
+	return &preAggregateNode{
+		baseNode: newBaseNode(),
+		size:     size,
+		ks:       ks,
+		agg:      agg,
+	}
+}
+
+func (n *preAggregateNode) Do(collector Collector, v values.Value) error {
+	tsv, err := values.GetTimestampedValue(v)
+	if err != nil {
+		return fmt.Errorf("values entering a window should be timestamped, this is not: %v", err)
+	}
+	if n.windows == nil {
+		n.windows = make(map[values.Key]*FixedWindowManager)
+	}
+	k := n.ks.GetKey(v)
+	m, ok := n.windows[k]
+	if !ok {
+		m = NewFixedWindowManager(n.size, n.size, n.agg.Init())
+		n.windows[k] = m
+	}
+	if err := m.ForEachWindow(tsv.Timestamp(), func(w *Window) error {
+		acc, err := n.agg.Add(w.State, undecorated(v))
+		if err != nil {
+			return err
+		}
+		w.State = acc
+		return nil
+	}); err != nil {
+		return err
+	}
+	return m.ForEachClosedWindow(tsv.Watermark(), func(w *Window) error {
+		collector.Collect(values.SetKey(k, values.SetTime(w.Stop()-1, tsv.Watermark(), w.State)))
+		return nil
+	})
+}
+
+// flush emits the accumulators of the windows that are still open, so that they reach the shuffle.
+func (n *preAggregateNode) flush(collector Collector) error {
+	keys := make([]values.Key, 0, len(n.windows))
+	for k := range n.windows {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		m := n.windows[k]
+		for _, w := range m.sorted() {
+			collector.Collect(values.SetKey(k, values.SetTime(w.Stop()-1, m.wm, w.State)))
+		}
+	}
+	return nil
+}
+
+func (n *preAggregateNode) Out() *Arch {
+	return NewLink(n)
+}
+
+func (n *preAggregateNode) OutTag(tag string) *Arch {
+	return NewTaggedLink(n, tag)
+}
+
+func (n *preAggregateNode) DeadLetters() *Arch {
+	return n.OutTag(DeadLetterTag)
+}
+
+func (n *preAggregateNode) SetParallelism(par int) Node {
+	n.par = par
+	return n
+}
+
+func (n *preAggregateNode) SetName(name string) Node {
+	n.name = name
+	return n
+}
+
+func (n *preAggregateNode) SetErrorPolicy(p ErrorPolicy) Node {
+	n.ep = p
+	return n
+}
+
+func (n *preAggregateNode) GetTimestamps() Timestamps {
+	return WithTimestamps
+}
+
+func (n *preAggregateNode) isStateful() bool {
+	return true
+}
+
+// checkpoint copies the windows of the key of v only, as the ones of other keys do not change.
+func (n *preAggregateNode) checkpoint(v values.Value) interface{} {
+	k := n.ks.GetKey(v)
+	m, ok := n.windows[k]
+	if !ok {
+		return preAggregateCheckpoint{k: k}
+	}
+	return preAggregateCheckpoint{k: k, m: m.copy()}
+}
+
+func (n *preAggregateNode) restore(cp interface{}) {
+	c := cp.(preAggregateCheckpoint)
+	m, ok := n.windows[c.k]
+	switch {
+	case !ok:
+	case c.m == nil:
+		m.track(-float64(len(m.ws)))
+		delete(n.windows, c.k)
+	default:
+		m.restore(c.m)
+	}
+}
+
+// preAggregateCheckpoint is the state of a preAggregateNode for a key, m is nil if it has no windows.
+type preAggregateCheckpoint struct {
+	k values.Key
+	m *FixedWindowManager
+}
+
+func (n *preAggregateNode) Clone() Node {
+	return &preAggregateNode{
+		baseNode: n.baseNode.Clone(),
+		size:     n.size,
+		ks:       n.ks,
+		agg:      n.agg,
+	}
+}
@@ -0,0 +1,254 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanner(t *testing.T) {
+	isOdd := func(v values.Value) bool {
+		return v.Int()%2 == 1
+	}
+	double := func(v values.Value) (values.Value, error) {
+		return values.New(v.Int() * 2), nil
+	}
+	parity := FnKeySelector(func(v values.Value) values.Key {
+		return values.Key(v.Int() % 2)
+	})
+	firstDigit := NewStringValueKeySelector(func(v values.Value) string {
+		return v.String()[:1]
+	})
+	timed := func(is ...int) []values.Value {
+		vs := make([]values.Value, len(is))
+		for i, v := range is {
+			vs[i] = values.SetTime(values.Timestamp(i), values.Timestamp(i), values.New(v))
+		}
+		return vs
+	}
+
+	for _, tc := range []struct {
+		name         string
+		rule         Rule
+		build        func(ctx context.Context) *Arch
+		wantRewrites []string
+		wantPlan     string
+		want         []string
+	}{
+		{
+			name: "filter pushdown",
+			rule: FilterPushdown(),
+			build: func(ctx context.Context) *Arch {
+				a := newSliceSource(ints(1, 2, 3)...).SetName("a").Out()
+				b := newSliceSource(ints(4, 5)...).SetName("b").Out()
+				return a.Union(ctx, b).Out().Filter(ctx, isOdd).Out()
+			},
+			wantRewrites: []string{"filter-pushdown: filter before union"},
+			wantPlan: `a -> filter[0]
+b -> filter[1]
+filter[0] -> union
+filter[1] -> union
+union -> sink
+`,
+			want: []string{"1", "3", "5"},
+		},
+		{
+			name: "no filter pushdown with dead letters",
+			rule: FilterPushdown(),
+			build: func(ctx context.Context) *Arch {
+				a := newSliceSource(ints(1, 2, 3)...).SetName("a").Out()
+				b := newSliceSource(values.New("x")).SetName("b").Out()
+				f := a.Union(ctx, b).Out().Filter(ctx, isOdd)
+				SetErrorPolicy(f, DeadLetterOnError)
+				DeadLetters(f).Sink(ctx, func(values.Value) error { return nil })
+				return f.Out()
+			},
+			wantRewrites: nil,
+			wantPlan: `a -> union
+b -> union
+filter -> sink
+filter -> sink-2
+union -> filter
+`,
+			want: []string{"1", "3"},
+		},
+		{
+			name: "map filter fusion",
+			rule: MapFilterFusion(),
+			build: func(ctx context.Context) *Arch {
+				return newSliceSource(ints(1, 2, 3)...).Out().
+					Map(ctx, double).Out().
+					Filter(ctx, func(v values.Value) bool {
+						return v.Int() > 2
+					}).Out().
+					Map(ctx, double).SetName("double").Out()
+			},
+			wantRewrites: []string{
+				"map-filter-fusion: filter and double into filter+double",
+				"map-filter-fusion: map and filter+double into map+filter+double",
+			},
+			wantPlan: `map+filter+double -> sink
+source -> map+filter+double
+`,
+			want: []string{"12", "8"},
+		},
+		{
+			name: "no fusion with different parallelism",
+			rule: MapFilterFusion(),
+			build: func(ctx context.Context) *Arch {
+				return newSliceSource(ints(1, 2, 3)...).Out().
+					Map(ctx, double).Out().
+					Filter(ctx, isOdd).SetParallelism(2).Out()
+			},
+			wantRewrites: nil,
+			wantPlan: `filter -> sink
+map -> filter
+source -> map
+`,
+			want: []string{},
+		},
+		{
+			name: "redundant keyby removal",
+			rule: RedundantKeyByRemoval(),
+			build: func(ctx context.Context) *Arch {
+				return newSliceSource(ints(10, 11, 20, 12, 21)...).Out().
+					KeyBy(firstDigit).Filter(ctx, func(v values.Value) bool {
+					return v.Int() != 12
+				}).SetParallelism(2).Out().
+					KeyBy(firstDigit).Aggregate(ctx, Count()).SetParallelism(3).Out()
+			},
+			wantRewrites: []string{"redundant-keyby-removal: filter keeps the keys of its input"},
+			wantPlan: `count -> sink
+filter -> count
+source -> filter
+`,
+			want: []string{"1", "1", "2", "2"},
+		},
+		{
+			name: "pre-aggregation",
+			rule: PreAggregation(),
+			build: func(ctx context.Context) *Arch {
+				return newSliceSource(timed(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)...).Out().
+					KeyBy(parity).Window(4, 4).Aggregate(ctx, Sum()).SetParallelism(2).Out().
+					Map(ctx, func(v values.Value) (values.Value, error) {
+						ts, _, _ := values.GetTime(v)
+						return values.New(fmt.Sprintf("%v@%d", undecorated(v), ts)), nil
+					}).Out()
+			},
+			wantRewrites: []string{"pre-aggregation: pre-window-sum before window-sum"},
+			wantPlan: `map -> sink
+pre-window-sum -> window-sum
+source -> pre-window-sum
+window-sum -> map
+`,
+			// The last window does not close.
+			want: []string{"12@7", "14@7", "4@3", "6@3"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer leaktest.Check(t)()
+
+			run := func(p *Planner) ([]string, *Plan) {
+				ctx := Context()
+				var mu sync.Mutex
+				var got []values.Value
+				tc.build(ctx).Sink(ctx, func(v values.Value) error {
+					mu.Lock()
+					defer mu.Unlock()
+					got = append(got, undecorated(v))
+					return nil
+				})
+				var plan *Plan
+				if p != nil {
+					plan = p.Plan(GetGraph(ctx))
+				}
+				if err := NewEngine(WithPlanner(p)).Execute(ctx); err != nil {
+					t.Fatal(err)
+				}
+				return sortedStrings(got), plan
+			}
+
+			want, _ := run(nil)
+			if diff := cmp.Diff(tc.want, want); diff != "" {
+				t.Errorf("unexpected result without planning -want/+got:\n\t%s", diff)
+			}
+			got, plan := run(NewPlanner(tc.rule))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected result with planning -want/+got:\n\t%s", diff)
+			}
+			var rewrites []string
+			for _, r := range plan.Rewrites {
+				rewrites = append(rewrites, r.String())
+			}
+			if diff := cmp.Diff(tc.wantRewrites, rewrites); diff != "" {
+				t.Errorf("unexpected rewrites -want/+got:\n\t%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantPlan, plan.Physical.String()); diff != "" {
+				t.Errorf("unexpected plan -want/+got:\n\t%s", diff)
+			}
+			if !strings.Contains(plan.String(), "physical:\n") {
+				t.Errorf("unexpected plan description: %s", plan)
+			}
+		})
+	}
+}
+
+func TestPreAggregateNode_Flush(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	parity := FnKeySelector(func(v values.Value) values.Key {
+		return values.Key(v.Int() % 2)
+	})
+	in := NewInfiniteStream()
+	out := NewInfiniteStream()
+	out.bufferSize = 10
+	o := NewOperator(newPreAggregateNode(4, parity, Count()))
+	o.In(in)
+	o.Out(out)
+	o.Open()
+
+	for _, ts := range []int{1, 2, 5} {
+		in.Collect(values.SetKey(0, values.SetTime(values.Timestamp(ts), values.Timestamp(ts), values.New(ts))))
+	}
+	// The stream closes before the windows [0, 4) of key 0 and [4, 8) of key 1 do.
+	SendClose(in)
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for v := out.Next(); v != nil; v = out.Next() {
+		k, _ := values.GetKey(v)
+		ts, _, _ := values.GetTime(v)
+		got = append(got, fmt.Sprintf("%d:%v@%d", k, undecorated(v), ts))
+	}
+	want := []string{"1:1@3", "0:1@3", "1:1@7"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestPlanner_LogicalUntouched(t *testing.T) {
+	ctx := Context()
+	newSliceSource(ints(1)...).Out().
+		Map(ctx, func(v values.Value) (values.Value, error) {
+			return v, nil
+		}).Out().
+		Filter(ctx, func(values.Value) bool {
+			return true
+		}).Out().
+		Sink(ctx, func(values.Value) error {
+			return nil
+		})
+	before := GetGraph(ctx).String()
+	DefaultPlanner().Plan(GetGraph(ctx))
+	if diff := cmp.Diff(before, GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected logical graph -want/+got:\n\t%s", diff)
+	}
+}
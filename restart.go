@@ -0,0 +1,183 @@
+package ssp
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/affo/ssp/values"
+)
+
+// RestartStrategy decides whether to restart after a failure and how long to wait before doing it.
+type RestartStrategy interface {
+	// Restart receives the times of the failures happened so far, the last one included.
+	// It returns the delay to wait before restarting, or false if no restart should happen.
+	Restart(failures []time.Time) (time.Duration, bool)
+}
+
+type fixedDelayRestartStrategy struct {
+	attempts int
+	delay    time.Duration
+}
+
+// NewFixedDelayRestartStrategy restarts at most attempts times, waiting delay before every restart.
+func NewFixedDelayRestartStrategy(attempts int, delay time.Duration) RestartStrategy {
+	return fixedDelayRestartStrategy{
+		attempts: attempts,
+		delay:    delay,
+	}
+}
+
+func (s fixedDelayRestartStrategy) Restart(failures []time.Time) (time.Duration, bool) {
+	if len(failures) > s.attempts {
+		return 0, false
+	}
+	return s.delay, true
+}
+
+type exponentialDelayRestartStrategy struct {
+	attempts   int
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+}
+
+// NewExponentialDelayRestartStrategy restarts at most attempts times.
+// The delay starts from initial and gets multiplied by multiplier at every failure, up to max.
+func NewExponentialDelayRestartStrategy(attempts int, initial, max time.Duration, multiplier float64) RestartStrategy {
+	return exponentialDelayRestartStrategy{
+		attempts:   attempts,
+		initial:    initial,
+		max:        max,
+		multiplier: multiplier,
+	}
+}
+
+func (s exponentialDelayRestartStrategy) Restart(failures []time.Time) (time.Duration, bool) {
+	n := len(failures)
+	if n > s.attempts {
+		return 0, false
+	}
+	d := float64(s.initial) * math.Pow(s.multiplier, float64(n-1))
+	if d > float64(s.max) {
+		return s.max, true
+	}
+	return time.Duration(d), true
+}
+
+type failureRateRestartStrategy struct {
+	maxFailures int
+	interval    time.Duration
+	delay       time.Duration
+}
+
+// NewFailureRateRestartStrategy restarts after delay, unless more than maxFailures failures happened in the last interval.
+func NewFailureRateRestartStrategy(maxFailures int, interval, delay time.Duration) RestartStrategy {
+	return failureRateRestartStrategy{
+		maxFailures: maxFailures,
+		interval:    interval,
+		delay:       delay,
+	}
+}
+
+func (s failureRateRestartStrategy) Restart(failures []time.Time) (time.Duration, bool) {
+	if len(failures) == 0 {
+		return s.delay, true
+	}
+	last := failures[len(failures)-1]
+	n := 0
+	for _, f := range failures {
+		if last.Sub(f) < s.interval {
+			n++
+		}
+	}
+	if n > s.maxFailures {
+		return 0, false
+	}
+	return s.delay, true
+}
+
+func (s failureRateRestartStrategy) retention() time.Duration {
+	return s.interval
+}
+
+// retainer is implemented by RestartStrategies that only consider the failures happened in the last retention.
+type retainer interface {
+	retention() time.Duration
+}
+
+// FailoverScope determines what gets restarted on failure.
+type FailoverScope int
+
+const (
+	// FailoverJob re-executes the whole job from its sources, with fresh state.
+	// Nothing is checkpointed: values emitted by failed attempts are not retracted, and sinks can receive them again.
+	FailoverJob FailoverScope = iota
+	// FailoverRegion restarts the failing ParallelOperator only, while the rest of the job keeps running.
+	// The keyed state of the failing Node gets restored to the one it had before the failing record,
+	// and the record gets processed again. Other keys are not affected, as they did not see the record.
+	// The state is copied before every record, and values held in Go objects (see values.New) are shared, not copied.
+	// Values emitted by failed attempts are not retracted: delivery is at-least-once.
+	// Sources are not restarted, as they would emit their values again, and their failures fail the job.
+	FailoverRegion
+)
+
+// checkpointer is implemented by stateful Nodes whose state can be restored when their region gets restarted.
+type checkpointer interface {
+	// checkpoint returns a copy of the state of the Node that processing v can change.
+	checkpoint(v values.Value) interface{}
+	// restore brings the Node back to a state returned by checkpoint.
+	restore(cp interface{})
+}
+
+// restartTracker keeps track of the failures of a job or a region.
+// Failures older than the retention of the RestartStrategy, if any, are forgotten.
+type restartTracker struct {
+	mu       sync.Mutex
+	rs       RestartStrategy
+	failures []time.Time
+}
+
+func newRestartTracker(rs RestartStrategy) *restartTracker {
+	return &restartTracker{
+		rs: rs,
+	}
+}
+
+func (r *restartTracker) fail() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if rs, ok := r.rs.(retainer); ok {
+		i := 0
+		for i < len(r.failures) && now.Sub(r.failures[i]) >= rs.retention() {
+			i++
+		}
+		r.failures = r.failures[i:]
+	}
+	r.failures = append(r.failures, now)
+	return r.rs.Restart(r.failures)
+}
+
+// succeed records a successful restart, so that the failures before it do not count anymore.
+// Strategies with a retention keep them, as they limit the failure rate instead.
+func (r *restartTracker) succeed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.rs.(retainer); !ok {
+		r.failures = r.failures[:0]
+	}
+}
+
+// wait blocks for d, or until done gets closed.
+// It returns false if done got closed.
+func wait(d time.Duration, done <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
@@ -0,0 +1,337 @@
+package ssp
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func failuresAt(ds ...time.Duration) []time.Time {
+	t0 := time.Unix(0, 0)
+	ts := make([]time.Time, len(ds))
+	for i, d := range ds {
+		ts[i] = t0.Add(d)
+	}
+	return ts
+}
+
+func TestRestartStrategies(t *testing.T) {
+	type restart struct {
+		Delay time.Duration
+		Ok    bool
+	}
+	for _, tc := range []struct {
+		name     string
+		rs       RestartStrategy
+		failures []time.Time
+		want     restart
+	}{
+		{
+			name:     "fixed delay",
+			rs:       NewFixedDelayRestartStrategy(3, time.Second),
+			failures: failuresAt(0, 0, 0),
+			want:     restart{Delay: time.Second, Ok: true},
+		},
+		{
+			name:     "fixed delay exhausted",
+			rs:       NewFixedDelayRestartStrategy(3, time.Second),
+			failures: failuresAt(0, 0, 0, 0),
+			want:     restart{},
+		},
+		{
+			name:     "exponential delay",
+			rs:       NewExponentialDelayRestartStrategy(5, time.Second, time.Minute, 2),
+			failures: failuresAt(0, 0, 0),
+			want:     restart{Delay: 4 * time.Second, Ok: true},
+		},
+		{
+			name:     "exponential delay capped",
+			rs:       NewExponentialDelayRestartStrategy(10, time.Second, 10*time.Second, 2),
+			failures: failuresAt(0, 0, 0, 0, 0),
+			want:     restart{Delay: 10 * time.Second, Ok: true},
+		},
+		{
+			name:     "exponential delay exhausted",
+			rs:       NewExponentialDelayRestartStrategy(1, time.Second, time.Minute, 2),
+			failures: failuresAt(0, 0),
+			want:     restart{},
+		},
+		{
+			name:     "failure rate",
+			rs:       NewFailureRateRestartStrategy(2, time.Minute, time.Second),
+			failures: failuresAt(0, 2*time.Minute, 2*time.Minute+time.Second),
+			want:     restart{Delay: time.Second, Ok: true},
+		},
+		{
+			name:     "failure rate exceeded",
+			rs:       NewFailureRateRestartStrategy(2, time.Minute, time.Second),
+			failures: failuresAt(0, time.Second, 2*time.Second),
+			want:     restart{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, ok := tc.rs.Restart(tc.failures)
+			if diff := cmp.Diff(tc.want, restart{Delay: d, Ok: ok}); diff != "" {
+				t.Errorf("unexpected restart -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
+
+func TestEngine_FailoverRegion(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// Every "transient" record fails the first 2 times it gets processed.
+	failures := make(map[string]int)
+	sink, log := NewLogSink(values.String)
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		for _, w := range []string{"a", "b", "transient", "a", "transient", "b"} {
+			collector.Collect(values.New(w))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		KeyBy(NewStringValueKeySelector(func(v values.Value) string {
+			return v.String()
+		})).
+		Connect(ctx, NewStatefulNode(values.New(0),
+			func(state values.Value, collector Collector, v values.Value) (values.Value, error) {
+				count := state.Int() + 1
+				if v.String() == "transient" && failures[fmt.Sprintf("%s-%d", v, count)] < 2 {
+					failures[fmt.Sprintf("%s-%d", v, count)]++
+					return nil, fmt.Errorf("lookup failed")
+				}
+				collector.Collect(values.New(fmt.Sprintf("%v: %d", v, count)))
+				return values.New(count), nil
+			})).
+		SetName("counter").
+		Out().
+		Connect(ctx, sink.SetName("sink"))
+
+	e := NewEngine(WithFailover(FailoverRegion, NewFixedDelayRestartStrategy(4, time.Millisecond)))
+	if err := e.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, v := range log.GetValues() {
+		got = append(got, v.String())
+	}
+	sort.Strings(got)
+	want := []string{"a: 1", "a: 2", "b: 1", "b: 2", "transient: 1", "transient: 2"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestEngine_FailoverRegion_RestoreState(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	t.Run("stateful node", func(t *testing.T) {
+		// The node changes its state before failing.
+		failed := false
+		sink, log := NewLogSink(values.Int)
+		ctx := Context()
+		newSliceSource(values.New("a"), values.New("transient"), values.New("b")).
+			Out().
+			Connect(ctx, NewStatefulNode(values.NewList(values.String),
+				func(state values.Value, collector Collector, v values.Value) (values.Value, error) {
+					seen := state.(*values.List)
+					if err := seen.AddValue(v); err != nil {
+						return nil, err
+					}
+					if v.String() == "transient" && !failed {
+						failed = true
+						return nil, fmt.Errorf("lookup failed")
+					}
+					collector.Collect(values.New(len(seen.GetValues())))
+					return seen, nil
+				})).
+			SetName("seen").
+			Out().
+			Connect(ctx, sink.SetName("sink"))
+
+		e := NewEngine(WithFailover(FailoverRegion, NewFixedDelayRestartStrategy(1, time.Millisecond)))
+		if err := e.Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"1", "2", "3"}, sortedStrings(log.GetValues())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		failed := false
+		sink, log := NewLogSink(values.Int)
+		ctx := Context()
+		NewNode(func(collector Collector, v values.Value) error {
+			collector.Collect(values.SetTime(0, 0, values.New("a")))
+			collector.Collect(values.SetTime(1, 0, values.New("transient")))
+			collector.Collect(values.SetTime(2, 2, values.New("b")))
+			return nil
+		}).SetName("source").
+			Out().
+			Connect(ctx, NewWindowedNode(2, 2, values.New(0),
+				func(w *Window, collector Collector, v values.TimestampedValue) error {
+					w.State = values.New(w.State.Int() + 1)
+					if v.String() == "transient" && !failed {
+						failed = true
+						return fmt.Errorf("lookup failed")
+					}
+					return nil
+				},
+				func(w *Window, collector Collector) error {
+					collector.Collect(w.State)
+					return nil
+				})).
+			SetName("count").
+			Out().
+			Connect(ctx, sink.SetName("sink"))
+
+		e := NewEngine(WithFailover(FailoverRegion, NewFixedDelayRestartStrategy(1, time.Millisecond)))
+		if err := e.Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// The window [0, 2) counts "a" and "transient" once.
+		if diff := cmp.Diff([]string{"2"}, sortedStrings(log.GetValues())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
+
+func TestEngine_FailoverRegion_ResetOnSuccess(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// Every record fails once: the job survives as long as restarts succeed.
+	failed := make(map[string]bool)
+	sink, log := NewLogSink(values.String)
+	ctx := Context()
+	newSliceSource(values.New("a"), values.New("b"), values.New("c"), values.New("d")).
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			if !failed[v.String()] {
+				failed[v.String()] = true
+				return fmt.Errorf("lookup failed")
+			}
+			collector.Collect(v)
+			return nil
+		})).
+		SetName("lookup").
+		Out().
+		Connect(ctx, sink.SetName("sink"))
+
+	e := NewEngine(WithFailover(FailoverRegion, NewFixedDelayRestartStrategy(1, time.Millisecond)))
+	if err := e.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c", "d"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestEngine_FailoverRegion_Exhausted(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		collector.Collect(values.New(42))
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			return fmt.Errorf("permanent error")
+		}).SetName("failing"))
+
+	e := NewEngine(WithFailover(FailoverRegion, NewFixedDelayRestartStrategy(2, time.Millisecond)))
+	if err := e.Execute(ctx); err == nil {
+		t.Fatal("expected error got none")
+	}
+}
+
+func TestEngine_FailoverRegion_Source(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	runs := 0
+	ctx := Context()
+	NewNode(func(collector Collector, v values.Value) error {
+		runs++
+		collector.Collect(values.New(runs))
+		return fmt.Errorf("source failed")
+	}).SetName("source").
+		Out().
+		Sink(ctx, func(values.Value) error {
+			return nil
+		})
+
+	e := NewEngine(WithFailover(FailoverRegion, NewFixedDelayRestartStrategy(2, time.Millisecond)))
+	if err := e.Execute(ctx); err == nil {
+		t.Fatal("expected error got none")
+	}
+	// The source would emit its values again.
+	if runs != 1 {
+		t.Errorf("unexpected number of runs: %d", runs)
+	}
+}
+
+func TestRestartTracker_Retention(t *testing.T) {
+	r := newRestartTracker(NewFailureRateRestartStrategy(2, 10*time.Millisecond, 0))
+	for i := 0; i < 2; i++ {
+		if _, ok := r.fail(); !ok {
+			t.Fatal("unexpected failure")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := r.fail(); !ok {
+		t.Fatal("unexpected failure")
+	}
+	// Failures out of the interval are forgotten.
+	if len(r.failures) != 1 {
+		t.Errorf("unexpected number of failures: %d", len(r.failures))
+	}
+}
+
+func TestEngine_FailoverJob(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	attempts := 0
+	ctx := Context()
+	sink, log := NewLogSink(values.Int)
+	NewNode(func(collector Collector, v values.Value) error {
+		attempts++
+		for i := 0; i < 5; i++ {
+			collector.Collect(values.New(i))
+		}
+		return nil
+	}).SetName("source").
+		Out().
+		Connect(ctx, NewNode(func(collector Collector, v values.Value) error {
+			if attempts < 3 {
+				return fmt.Errorf("failing at attempt %d", attempts)
+			}
+			collector.Collect(v)
+			return nil
+		})).SetName("failing").
+		Out().
+		Connect(ctx, sink.SetName("sink"))
+
+	e := NewEngine(WithFailover(FailoverJob, NewFixedDelayRestartStrategy(3, time.Millisecond)))
+	if err := e.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("unexpected number of attempts: %d", attempts)
+	}
+	var got []int
+	for _, v := range log.GetValues() {
+		got = append(got, v.Int())
+	}
+	if diff := cmp.Diff([]int{0, 1, 2, 3, 4}, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,423 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+)
+
+// query is a compiled query.
+type query struct {
+	in    *values.Schema
+	where *compiled
+	// star queries emit their input records.
+	star  bool
+	items []compiled
+	out   *values.Schema
+
+	grouped bool
+	// keys are the indexes of the GROUP BY columns.
+	keys   []int
+	window *tumble
+	aggs   []aggregate
+}
+
+func compile(s *selectStmt, in *values.Schema) (*query, error) {
+	q := &query{in: in, star: s.star}
+	if s.where != nil {
+		c, err := compileExpr(s.where, &scope{schema: in, table: s.table(), clause: "WHERE"})
+		if err != nil {
+			return nil, err
+		}
+		if c.t != values.Bool {
+			return nil, fmt.Errorf("WHERE requires a bool, got %v", c.t)
+		}
+		q.where = &c
+	}
+
+	q.grouped = len(s.groupBy) > 0
+	for _, item := range s.items {
+		q.grouped = q.grouped || containsAggregate(item.e)
+	}
+	if q.star {
+		if q.grouped {
+			return nil, fmt.Errorf("SELECT * is not allowed in grouped queries")
+		}
+		q.out = in
+		return q, nil
+	}
+	keys, err := q.compileGroupBy(s, in)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &scope{
+		schema:  in,
+		table:   s.table(),
+		clause:  "SELECT",
+		grouped: q.grouped,
+		keys:    keys,
+		window:  q.window,
+		aggs:    &q.aggs,
+	}
+	fields := make([]values.Field, len(s.items))
+	for i, item := range s.items {
+		c, err := compileExpr(item.e, sc)
+		if err != nil {
+			return nil, err
+		}
+		q.items = append(q.items, c)
+		f := values.Field{Name: item.alias, Type: c.t, Nullable: true}
+		if col, ok := item.e.(*columnRef); ok {
+			// Columns keep their definition, lists and records included.
+			j, _ := in.Index(col.name)
+			f = in.Field(j)
+			f.Nullable = true
+			if item.alias != "" {
+				f.Name = item.alias
+			}
+		}
+		if f.Name == "" {
+			f.Name = item.e.String()
+		}
+		fields[i] = f
+	}
+	if q.out, err = values.NewSchema(fields...); err != nil {
+		return nil, fmt.Errorf("invalid output: %v", err)
+	}
+	return q, nil
+}
+
+// compileGroupBy sets the keys and the window of q, and returns the names of the GROUP BY columns.
+func (q *query) compileGroupBy(s *selectStmt, in *values.Schema) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	for _, e := range s.groupBy {
+		switch e := e.(type) {
+		case *columnRef:
+			if _, err := compileColumn(e, &scope{schema: in, table: s.table(), clause: "GROUP BY"}); err != nil {
+				return nil, err
+			}
+			if keys[e.name] {
+				continue
+			}
+			i, _ := in.Index(e.name)
+			keys[e.name] = true
+			q.keys = append(q.keys, i)
+		case *callExpr:
+			if e.name != "TUMBLE" {
+				return nil, fmt.Errorf("GROUP BY %v: only columns and TUMBLE are allowed", e)
+			}
+			if q.window != nil {
+				return nil, fmt.Errorf("GROUP BY %v: only one TUMBLE is allowed", e)
+			}
+			w, err := compileTumble(e, s, in)
+			if err != nil {
+				return nil, err
+			}
+			q.window = w
+		default:
+			return nil, fmt.Errorf("GROUP BY %v: only columns and TUMBLE are allowed", e)
+		}
+	}
+	return keys, nil
+}
+
+// compileTumble compiles TUMBLE(column, INTERVAL ...).
+// The column must be a time or an integer, and it cannot be null.
+func compileTumble(e *callExpr, s *selectStmt, in *values.Schema) (*tumble, error) {
+	if len(e.args) != 2 {
+		return nil, fmt.Errorf("%v: expected 2 arguments, got %d", e, len(e.args))
+	}
+	col, ok := e.args[0].(*columnRef)
+	if !ok {
+		return nil, fmt.Errorf("%v: the first argument must be a column", e)
+	}
+	if _, err := compileColumn(col, &scope{schema: in, table: s.table(), clause: "GROUP BY"}); err != nil {
+		return nil, err
+	}
+	i, _ := in.Index(col.name)
+	f := in.Field(i)
+	if f.Repeated || (f.Type != values.Time && !isInteger(f.Type)) {
+		return nil, fmt.Errorf("%v: column %q must be a time or an integer, got %v", e, col.name, f)
+	}
+	if f.Nullable {
+		return nil, fmt.Errorf("%v: column %q must not be nullable", e, col.name)
+	}
+	size, ok := e.args[1].(*intervalExpr)
+	if !ok {
+		return nil, fmt.Errorf("%v: the second argument must be an INTERVAL", e)
+	}
+	if size.n <= 0 {
+		return nil, fmt.Errorf("%v: the interval must be positive", e)
+	}
+	t := values.Time
+	if f.Type != values.Time {
+		t = values.Int64
+	}
+	return &tumble{col: i, name: col.name, t: t, size: size.n}, nil
+}
+
+// build adds the nodes of q to the Graph in ctx, after t.
+func (q *query) build(ctx context.Context, t Table, o queryOptions) Table {
+	a := t.Out
+	if q.window != nil {
+		col, delay := q.window.col, values.Timestamp(o.delay)
+		a = a.Connect(ctx, ssp.AssignTimestamp(func(v values.Value) (values.Timestamp, values.Timestamp) {
+			r, err := q.record(v)
+			if err != nil {
+				// The error is reported by the next node.
+				return 0, 0
+			}
+			ts := toTimestamp(r.GetFieldAt(col))
+			return ts, ts - delay
+		}).SetName("timestamps")).Out()
+	}
+	if q.where != nil {
+		a = a.Connect(ctx, q.whereNode()).Out()
+	}
+	switch {
+	case q.star:
+	case !q.grouped:
+		a = a.Map(ctx, func(v values.Value) (values.Value, error) {
+			r, err := q.record(v)
+			if err != nil {
+				return nil, err
+			}
+			return q.row(&env{r: r})
+		}).SetName("select").Out()
+	default:
+		var n ssp.Node
+		if q.window != nil {
+			n = q.windowNode()
+		} else {
+			n = q.groupNode()
+		}
+		// KeyBy modifies the Arch.
+		keyed := *a
+		a = keyed.KeyBy(q.keySelector()).Connect(ctx, n.SetParallelism(o.par)).Out()
+	}
+	return Table{Schema: q.out, Out: a}
+}
+
+// record returns the record in v, that must have the input schema.
+func (q *query) record(v values.Value) (*values.Record, error) {
+	for {
+		u, err := v.Unwrap()
+		if err != nil {
+			break
+		}
+		v = u
+	}
+	r, ok := v.(*values.Record)
+	if !ok {
+		return nil, fmt.Errorf("expected a record, got %v", v)
+	}
+	if s := r.Schema(); s != q.in && !s.Equal(q.in) {
+		return nil, fmt.Errorf("unexpected schema %v, want %v", s, q.in)
+	}
+	return r, nil
+}
+
+// row computes the output record of q.
+func (q *query) row(e *env) (*values.Record, error) {
+	vs := make([]values.Value, len(q.items))
+	for i, c := range q.items {
+		v, err := c.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		vs[i] = v
+	}
+	return values.NewRecord(q.out, vs...)
+}
+
+func (q *query) whereNode() ssp.Node {
+	return ssp.NewNode(func(collector ssp.Collector, v values.Value) error {
+		r, err := q.record(v)
+		if err != nil {
+			return err
+		}
+		ok, err := q.where.eval(&env{r: r})
+		if err != nil {
+			return err
+		}
+		if !isNull(ok) && ok.Bool() {
+			collector.Collect(output(v, r))
+		}
+		return nil
+	}).SetTimestamps(ssp.KeepsTimestamps).SetName("where")
+}
+
+// keySelector distributes records by the hash of their GROUP BY columns.
+// Records with different keys can end up together, so nodes tell groups apart.
+func (q *query) keySelector() ssp.KeySelector {
+	if len(q.keys) == 0 {
+		return ssp.NewFixedKeySelector()
+	}
+	return ssp.FnKeySelector(func(v values.Value) values.Key {
+		r, err := q.record(v)
+		if err != nil {
+			return 0
+		}
+		return values.Key(q.hash(r))
+	})
+}
+
+func (q *query) hash(r *values.Record) uint64 {
+	var h uint64
+	for _, i := range q.keys {
+		h = 31*h + values.Hash(r.GetFieldAt(i))
+	}
+	return h
+}
+
+// groupNode emits the updated row of the group of every record.
+func (q *query) groupNode() ssp.Node {
+	// Groups are created at the first value, because the initial state is shared by every instance of the node.
+	return ssp.NewStatefulNode(values.NewNull(values.Object),
+		func(state values.Value, collector ssp.Collector, v values.Value) (values.Value, error) {
+			gs, ok := state.Get().(*groups)
+			if !ok {
+				gs = newGroups()
+				state = values.New(gs)
+			}
+			r, err := q.record(v)
+			if err != nil {
+				return nil, err
+			}
+			g := gs.get(q, r)
+			if err := q.add(g, r); err != nil {
+				return nil, err
+			}
+			row, err := q.groupRow(g, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			collector.Collect(output(v, row))
+			return state, nil
+		}).SetTimestamps(ssp.KeepsTimestamps).SetName("group")
+}
+
+// windowNode emits the rows of the groups in every window that closes.
+func (q *query) windowNode() ssp.Node {
+	size := int(q.window.size)
+	return ssp.NewWindowedNode(size, size, values.NewNull(values.Object),
+		func(w *ssp.Window, collector ssp.Collector, v values.TimestampedValue) error {
+			gs, ok := w.State.Get().(*groups)
+			if !ok {
+				gs = newGroups()
+				w.State = values.New(gs)
+			}
+			r, err := q.record(v)
+			if err != nil {
+				return err
+			}
+			return q.add(gs.get(q, r), r)
+		},
+		func(w *ssp.Window, collector ssp.Collector) error {
+			gs, ok := w.State.Get().(*groups)
+			if !ok {
+				return nil
+			}
+			ts := w.Stop() - 1
+			for _, g := range gs.order {
+				row, err := q.groupRow(g, w.Start(), w.Stop())
+				if err != nil {
+					return err
+				}
+				collector.Collect(values.SetTime(ts, ts, row))
+			}
+			return nil
+		}).SetName("tumble")
+}
+
+// group is the state of a group of records.
+type group struct {
+	// r is the first record in the group, for evaluating the GROUP BY columns.
+	r    *values.Record
+	accs []values.Value
+}
+
+// groups holds groups in the order they are created.
+type groups struct {
+	byHash map[uint64][]*group
+	order  []*group
+}
+
+func newGroups() *groups {
+	return &groups{byHash: make(map[uint64][]*group)}
+}
+
+// get returns the group of r, creating it if needed.
+func (gs *groups) get(q *query, r *values.Record) *group {
+	h := q.hash(r)
+	for _, g := range gs.byHash[h] {
+		if q.sameGroup(g.r, r) {
+			return g
+		}
+	}
+	g := &group{r: r, accs: make([]values.Value, len(q.aggs))}
+	for i, a := range q.aggs {
+		g.accs[i] = a.agg.Init()
+	}
+	gs.byHash[h] = append(gs.byHash[h], g)
+	gs.order = append(gs.order, g)
+	return g
+}
+
+func (q *query) sameGroup(a, b *values.Record) bool {
+	for _, i := range q.keys {
+		if !values.Equal(a.GetFieldAt(i), b.GetFieldAt(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds r to the aggregates of g.
+// Null arguments are ignored, COUNT(*) counts every record.
+func (q *query) add(g *group, r *values.Record) error {
+	for i, a := range q.aggs {
+		v := values.Value(r)
+		if a.arg != nil {
+			var err error
+			if v, err = a.arg.eval(&env{r: r}); err != nil {
+				return err
+			}
+			if isNull(v) {
+				continue
+			}
+		}
+		acc, err := a.agg.Add(g.accs[i], v)
+		if err != nil {
+			return err
+		}
+		g.accs[i] = acc
+	}
+	return nil
+}
+
+// groupRow computes the output record of g, in the window from start to stop, if any.
+func (q *query) groupRow(g *group, start, stop values.Timestamp) (*values.Record, error) {
+	e := &env{r: g.r, aggs: make([]values.Value, len(q.aggs)), start: start, stop: stop}
+	for i, a := range q.aggs {
+		res, err := a.agg.Result(g.accs[i])
+		if err != nil {
+			return nil, err
+		}
+		if e.aggs[i], err = values.Convert(res, a.t); err != nil {
+			return nil, err
+		}
+	}
+	return q.row(e)
+}
+
+// output gives out the timestamp of in, if any.
+func output(in, out values.Value) values.Value {
+	if ts, wm, err := values.GetTime(in); err == nil {
+		return values.SetTime(ts, wm, out)
+	}
+	return out
+}
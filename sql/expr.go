@@ -0,0 +1,453 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+)
+
+// env is what expressions are evaluated against.
+type env struct {
+	r *values.Record
+	// aggs are the results of the aggregates in the query, for grouped queries.
+	aggs []values.Value
+	// start and stop are the bounds of the window, for queries grouped by TUMBLE.
+	start, stop values.Timestamp
+}
+
+// compiled is a type-checked expression.
+type compiled struct {
+	t    values.Type
+	eval func(e *env) (values.Value, error)
+}
+
+// aggregate is an aggregate function in a query.
+type aggregate struct {
+	agg ssp.Aggregator
+	// arg is nil for COUNT(*).
+	arg *compiled
+	t   values.Type
+}
+
+// tumble is the TUMBLE window in the GROUP BY clause of a query.
+type tumble struct {
+	col  int
+	name string
+	t    values.Type
+	size int64
+}
+
+// matches returns true if args are the arguments of the TUMBLE, for TUMBLE_START and TUMBLE_END.
+func (w *tumble) matches(args []expr) bool {
+	if len(args) != 2 {
+		return false
+	}
+	col, ok := args[0].(*columnRef)
+	if !ok || col.name != w.name {
+		return false
+	}
+	size, ok := args[1].(*intervalExpr)
+	return ok && size.n == w.size
+}
+
+// scope tells what expressions can refer to, where they appear.
+type scope struct {
+	schema *values.Schema
+	table  string
+	clause string
+	// grouped is set for the SELECT clause of queries with GROUP BY or aggregates:
+	// columns must be in keys.
+	grouped bool
+	keys    map[string]bool
+	window  *tumble
+	// aggs collects aggregates, if they are allowed.
+	aggs *[]aggregate
+}
+
+func isNull(v values.Value) bool {
+	return v.IsNull() && v.Type() != values.Object
+}
+
+func isInteger(t values.Type) bool {
+	return values.IsNumeric(t) && t != values.Float32 && t != values.Float64 && t != values.Decimal
+}
+
+func compileExpr(e expr, s *scope) (compiled, error) {
+	switch e := e.(type) {
+	case *literal:
+		v := e.v
+		return compiled{t: v.Type(), eval: func(*env) (values.Value, error) {
+			return v, nil
+		}}, nil
+	case *columnRef:
+		return compileColumn(e, s)
+	case *unaryExpr:
+		return compileUnary(e, s)
+	case *binaryExpr:
+		return compileBinary(e, s)
+	case *isNullExpr:
+		c, err := compileExpr(e.e, s)
+		if err != nil {
+			return compiled{}, err
+		}
+		not := e.not
+		return compiled{t: values.Bool, eval: func(en *env) (values.Value, error) {
+			v, err := c.eval(en)
+			if err != nil {
+				return nil, err
+			}
+			return values.New(isNull(v) != not), nil
+		}}, nil
+	case *callExpr:
+		return compileCall(e, s)
+	case *intervalExpr:
+		return compiled{}, fmt.Errorf("%v: intervals are only allowed in TUMBLE", e)
+	default:
+		return compiled{}, fmt.Errorf("unsupported expression %v", e)
+	}
+}
+
+func compileColumn(e *columnRef, s *scope) (compiled, error) {
+	if e.table != "" && e.table != s.table {
+		return compiled{}, fmt.Errorf("unknown table %q in %s.%s", e.table, e.table, e.name)
+	}
+	i, ok := s.schema.Index(e.name)
+	if !ok {
+		return compiled{}, fmt.Errorf("unknown column %q in %v", e.name, s.schema)
+	}
+	if s.grouped && !s.keys[e.name] {
+		return compiled{}, fmt.Errorf("column %q must appear in GROUP BY or be used in an aggregate", e.name)
+	}
+	f := s.schema.Field(i)
+	t := f.Type
+	if f.Repeated {
+		t = values.ListType
+	}
+	return compiled{t: t, eval: func(en *env) (values.Value, error) {
+		return en.r.GetFieldAt(i), nil
+	}}, nil
+}
+
+func compileUnary(e *unaryExpr, s *scope) (compiled, error) {
+	c, err := compileExpr(e.e, s)
+	if err != nil {
+		return compiled{}, err
+	}
+	if e.op == "NOT" {
+		if c.t != values.Bool {
+			return compiled{}, fmt.Errorf("%v: NOT requires a bool, got %v", e, c.t)
+		}
+		return compiled{t: values.Bool, eval: func(en *env) (values.Value, error) {
+			v, err := c.eval(en)
+			if err != nil || isNull(v) {
+				return v, err
+			}
+			return values.New(!v.Bool()), nil
+		}}, nil
+	}
+	if !values.IsNumeric(c.t) {
+		return compiled{}, fmt.Errorf("%v: - requires a number, got %v", e, c.t)
+	}
+	t := values.Float64
+	if isInteger(c.t) {
+		t = values.Int64
+	}
+	zero := compiled{t: values.Int64, eval: func(*env) (values.Value, error) {
+		return values.New(int64(0)), nil
+	}}
+	return compiled{t: t, eval: arithmetic("-", t, zero, c)}, nil
+}
+
+func compileBinary(e *binaryExpr, s *scope) (compiled, error) {
+	l, err := compileExpr(e.l, s)
+	if err != nil {
+		return compiled{}, err
+	}
+	r, err := compileExpr(e.r, s)
+	if err != nil {
+		return compiled{}, err
+	}
+	switch e.op {
+	case "AND", "OR":
+		if l.t != values.Bool || r.t != values.Bool {
+			return compiled{}, fmt.Errorf("%v: %s requires bools, got %v and %v", e, e.op, l.t, r.t)
+		}
+		return compiled{t: values.Bool, eval: logical(e.op == "AND", l, r)}, nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		if _, err := values.CommonType(l.t, r.t); err != nil {
+			return compiled{}, fmt.Errorf("%v: cannot compare %v and %v", e, l.t, r.t)
+		}
+		return compiled{t: values.Bool, eval: comparison(e.op, l, r)}, nil
+	default:
+		if !values.IsNumeric(l.t) || !values.IsNumeric(r.t) {
+			return compiled{}, fmt.Errorf("%v: %s requires numbers, got %v and %v", e, e.op, l.t, r.t)
+		}
+		t := values.Float64
+		if isInteger(l.t) && isInteger(r.t) {
+			t = values.Int64
+		}
+		return compiled{t: t, eval: arithmetic(e.op, t, l, r)}, nil
+	}
+}
+
+// logical follows three-valued logic: nulls are unknown.
+func logical(and bool, l, r compiled) func(en *env) (values.Value, error) {
+	return func(en *env) (values.Value, error) {
+		a, err := l.eval(en)
+		if err != nil {
+			return nil, err
+		}
+		// Short-circuit.
+		if !isNull(a) && a.Bool() != and {
+			return a, nil
+		}
+		b, err := r.eval(en)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !isNull(b) && b.Bool() != and:
+			return b, nil
+		case isNull(a) || isNull(b):
+			return values.NewNull(values.Bool), nil
+		default:
+			return values.New(and), nil
+		}
+	}
+}
+
+func comparison(op string, l, r compiled) func(en *env) (values.Value, error) {
+	return func(en *env) (values.Value, error) {
+		a, err := l.eval(en)
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.eval(en)
+		if err != nil {
+			return nil, err
+		}
+		if isNull(a) || isNull(b) {
+			return values.NewNull(values.Bool), nil
+		}
+		c, err := values.Compare(a, b)
+		if err != nil {
+			return nil, err
+		}
+		var res bool
+		switch op {
+		case "=":
+			res = c == 0
+		case "!=":
+			res = c != 0
+		case "<":
+			res = c < 0
+		case "<=":
+			res = c <= 0
+		case ">":
+			res = c > 0
+		case ">=":
+			res = c >= 0
+		}
+		return values.New(res), nil
+	}
+}
+
+func arithmetic(op string, t values.Type, l, r compiled) func(en *env) (values.Value, error) {
+	return func(en *env) (values.Value, error) {
+		a, err := l.eval(en)
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.eval(en)
+		if err != nil {
+			return nil, err
+		}
+		if isNull(a) || isNull(b) {
+			return values.NewNull(t), nil
+		}
+		if a, err = values.Convert(a, t); err != nil {
+			return nil, err
+		}
+		if b, err = values.Convert(b, t); err != nil {
+			return nil, err
+		}
+		if t == values.Int64 {
+			x, y := a.Int64(), b.Int64()
+			switch op {
+			case "+":
+				return values.New(x + y), nil
+			case "-":
+				return values.New(x - y), nil
+			case "*":
+				return values.New(x * y), nil
+			}
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero: %v %s %v", x, op, y)
+			}
+			if op == "/" {
+				return values.New(x / y), nil
+			}
+			return values.New(x % y), nil
+		}
+		x, y := a.Float64(), b.Float64()
+		switch op {
+		case "+":
+			return values.New(x + y), nil
+		case "-":
+			return values.New(x - y), nil
+		case "*":
+			return values.New(x * y), nil
+		case "/":
+			return values.New(x / y), nil
+		default:
+			return nil, fmt.Errorf("%% requires integers, got %v and %v", x, y)
+		}
+	}
+}
+
+var aggregators = map[string]func() ssp.Aggregator{
+	"COUNT": ssp.Count,
+	"SUM":   ssp.Sum,
+	"MIN":   ssp.Min,
+	"MAX":   ssp.Max,
+	"AVG":   ssp.Avg,
+}
+
+func compileCall(e *callExpr, s *scope) (compiled, error) {
+	if newAgg, ok := aggregators[e.name]; ok {
+		return compileAggregate(e, newAgg(), s)
+	}
+	if e.star {
+		return compiled{}, fmt.Errorf("%v: * is only allowed in COUNT", e)
+	}
+	switch e.name {
+	case "UPPER", "LOWER":
+		if len(e.args) != 1 {
+			return compiled{}, fmt.Errorf("%v: expected 1 argument, got %d", e, len(e.args))
+		}
+		c, err := compileExpr(e.args[0], s)
+		if err != nil {
+			return compiled{}, err
+		}
+		if c.t != values.String {
+			return compiled{}, fmt.Errorf("%v: expected a string, got %v", e, c.t)
+		}
+		f := strings.ToUpper
+		if e.name == "LOWER" {
+			f = strings.ToLower
+		}
+		return compiled{t: values.String, eval: func(en *env) (values.Value, error) {
+			v, err := c.eval(en)
+			if err != nil || isNull(v) {
+				return v, err
+			}
+			return values.New(f(v.String())), nil
+		}}, nil
+	case "TUMBLE_START", "TUMBLE_END":
+		if s.window == nil || !s.grouped || !s.window.matches(e.args) {
+			return compiled{}, fmt.Errorf("%v: requires GROUP BY TUMBLE%s", e, argsString(e.args))
+		}
+		start := e.name == "TUMBLE_START"
+		t := s.window.t
+		return compiled{t: t, eval: func(en *env) (values.Value, error) {
+			ts := en.stop
+			if start {
+				ts = en.start
+			}
+			return fromTimestamp(ts, t), nil
+		}}, nil
+	case "TUMBLE":
+		return compiled{}, fmt.Errorf("%v: TUMBLE is only allowed in GROUP BY", e)
+	default:
+		return compiled{}, fmt.Errorf("%v: unknown function %s", e, e.name)
+	}
+}
+
+func compileAggregate(e *callExpr, agg ssp.Aggregator, s *scope) (compiled, error) {
+	if s.aggs == nil {
+		return compiled{}, fmt.Errorf("%v: aggregates are not allowed in %s", e, s.clause)
+	}
+	a := aggregate{agg: agg, t: values.Int64}
+	if !e.star {
+		if len(e.args) != 1 {
+			return compiled{}, fmt.Errorf("%v: expected 1 argument, got %d", e, len(e.args))
+		}
+		// Arguments are evaluated on single records.
+		c, err := compileExpr(e.args[0], &scope{schema: s.schema, table: s.table, clause: "aggregates"})
+		if err != nil {
+			return compiled{}, err
+		}
+		a.arg = &c
+		switch e.name {
+		case "SUM":
+			if c.t == values.Duration {
+				a.t = values.Duration
+			} else if a.t, err = values.CommonType(values.Int64, c.t); err != nil {
+				return compiled{}, fmt.Errorf("%v: cannot sum %v", e, c.t)
+			}
+		case "AVG":
+			if !values.IsNumeric(c.t) {
+				return compiled{}, fmt.Errorf("%v: cannot average %v", e, c.t)
+			}
+			a.t = values.Float64
+		case "MIN", "MAX":
+			a.t = c.t
+		}
+	} else if e.name != "COUNT" {
+		return compiled{}, fmt.Errorf("%v: * is only allowed in COUNT", e)
+	}
+	i := len(*s.aggs)
+	*s.aggs = append(*s.aggs, a)
+	return compiled{t: a.t, eval: func(en *env) (values.Value, error) {
+		return en.aggs[i], nil
+	}}, nil
+}
+
+func argsString(args []expr) string {
+	as := make([]string, len(args))
+	for i, a := range args {
+		as[i] = a.String()
+	}
+	return "(" + strings.Join(as, ", ") + ")"
+}
+
+// containsAggregate returns true if e calls an aggregate function.
+func containsAggregate(e expr) bool {
+	switch e := e.(type) {
+	case *unaryExpr:
+		return containsAggregate(e.e)
+	case *binaryExpr:
+		return containsAggregate(e.l) || containsAggregate(e.r)
+	case *isNullExpr:
+		return containsAggregate(e.e)
+	case *callExpr:
+		if _, ok := aggregators[e.name]; ok {
+			return true
+		}
+		for _, a := range e.args {
+			if containsAggregate(a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toTimestamp converts times with values.ConvertTime, and integers to timestamps as they are.
+func toTimestamp(v values.Value) values.Timestamp {
+	if v.Type() == values.Time {
+		return values.ConvertTime(v.Time())
+	}
+	ts, _ := values.AsInt64(v)
+	return values.Timestamp(ts)
+}
+
+// fromTimestamp is the inverse of toTimestamp.
+func fromTimestamp(ts values.Timestamp, t values.Type) values.Value {
+	if t == values.Time {
+		return values.New(values.ConvertTimestamp(ts))
+	}
+	return values.New(int64(ts))
+}
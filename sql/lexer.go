@@ -0,0 +1,141 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	keywordToken
+	numberToken
+	stringToken
+	opToken
+)
+
+type token struct {
+	kind tokenKind
+	// text is upper case for keywords.
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case eofToken:
+		return "end of query"
+	case stringToken:
+		return fmt.Sprintf("'%s'", t.text)
+	default:
+		return t.text
+	}
+}
+
+var keywords = map[string]bool{
+	"SELECT":   true,
+	"FROM":     true,
+	"WHERE":    true,
+	"GROUP":    true,
+	"BY":       true,
+	"AS":       true,
+	"AND":      true,
+	"OR":       true,
+	"NOT":      true,
+	"IS":       true,
+	"NULL":     true,
+	"TRUE":     true,
+	"FALSE":    true,
+	"INTERVAL": true,
+	// Reserved, so that they are not taken for aliases.
+	"ORDER":  true,
+	"HAVING": true,
+	"LIMIT":  true,
+	"JOIN":   true,
+}
+
+// Operators, longest first.
+var operators = []string{"<=", ">=", "<>", "!=", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ".", ";"}
+
+func lex(q string) ([]token, error) {
+	var ts []token
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			// Comment until the end of the line.
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			text := string(rs[start:i])
+			if up := strings.ToUpper(text); keywords[up] {
+				ts = append(ts, token{kind: keywordToken, text: up, pos: start})
+			} else {
+				ts = append(ts, token{kind: identToken, text: text, pos: start})
+			}
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			ts = append(ts, token{kind: numberToken, text: string(rs[start:i]), pos: start})
+		case r == '\'' || r == '"':
+			// Strings are single quoted, identifiers can be double quoted.
+			start := i
+			s, n, err := quoted(rs[i:], r)
+			if err != nil {
+				return nil, fmt.Errorf("at %d: %v", start, err)
+			}
+			i += n
+			kind := stringToken
+			if r == '"' {
+				kind = identToken
+			}
+			ts = append(ts, token{kind: kind, text: s, pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(string(rs[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("at %d: unexpected character %q", i, r)
+			}
+			ts = append(ts, token{kind: opToken, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(ts, token{kind: eofToken, pos: len(rs)}), nil
+}
+
+// quoted reads a string delimited by quote, where quote is escaped by repeating it.
+// It returns the string and the number of runes read.
+func quoted(rs []rune, quote rune) (string, int, error) {
+	sb := strings.Builder{}
+	for i := 1; i < len(rs); i++ {
+		if rs[i] != quote {
+			sb.WriteRune(rs[i])
+			continue
+		}
+		if i+1 < len(rs) && rs[i+1] == quote {
+			sb.WriteRune(quote)
+			i++
+			continue
+		}
+		return sb.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated %c", quote)
+}
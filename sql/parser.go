@@ -0,0 +1,497 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/affo/ssp/values"
+)
+
+// selectStmt is a parsed query.
+type selectStmt struct {
+	star  bool
+	items []selectItem
+	from  string
+	// alias is the name the query uses for the table, if any.
+	alias   string
+	where   expr
+	groupBy []expr
+}
+
+// table returns the name the query uses for the table.
+func (s *selectStmt) table() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.from
+}
+
+type selectItem struct {
+	e     expr
+	alias string
+}
+
+// expr is an expression in a query.
+// Its String is used for naming output fields and for matching expressions.
+type expr interface {
+	String() string
+}
+
+type literal struct {
+	v values.Value
+}
+
+func (e *literal) String() string {
+	if e.v.Type() == values.String {
+		return "'" + strings.ReplaceAll(e.v.String(), "'", "''") + "'"
+	}
+	return e.v.String()
+}
+
+type columnRef struct {
+	table string
+	name  string
+}
+
+func (e *columnRef) String() string {
+	return e.name
+}
+
+type unaryExpr struct {
+	op string
+	e  expr
+}
+
+func (e *unaryExpr) String() string {
+	if e.op == "NOT" {
+		return "NOT " + e.e.String()
+	}
+	return e.op + e.e.String()
+}
+
+type binaryExpr struct {
+	op   string
+	l, r expr
+}
+
+func (e *binaryExpr) String() string {
+	return fmt.Sprintf("%v %s %v", e.l, e.op, e.r)
+}
+
+type isNullExpr struct {
+	e   expr
+	not bool
+}
+
+func (e *isNullExpr) String() string {
+	if e.not {
+		return e.e.String() + " IS NOT NULL"
+	}
+	return e.e.String() + " IS NULL"
+}
+
+type callExpr struct {
+	// name is upper case.
+	name string
+	args []expr
+	// star is true for COUNT(*).
+	star bool
+}
+
+func (e *callExpr) String() string {
+	if e.star {
+		return strings.ToLower(e.name) + "(*)"
+	}
+	as := make([]string, len(e.args))
+	for i, a := range e.args {
+		as[i] = a.String()
+	}
+	return fmt.Sprintf("%s(%s)", strings.ToLower(e.name), strings.Join(as, ", "))
+}
+
+// intervalExpr is a length of time, in nanoseconds if it has a unit.
+type intervalExpr struct {
+	n    int64
+	text string
+}
+
+func (e *intervalExpr) String() string {
+	return "INTERVAL " + e.text
+}
+
+type parser struct {
+	ts []token
+	i  int
+}
+
+func parse(q string) (*selectStmt, error) {
+	ts, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{ts: ts}
+	s, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptOp(";")
+	if t := p.peek(); t.kind != eofToken {
+		return nil, p.unexpected(t)
+	}
+	return s, nil
+}
+
+func (p *parser) peek() token {
+	return p.ts[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.ts[p.i]
+	if t.kind != eofToken {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("at %d: unexpected %v", t.pos, t)
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if t := p.peek(); t.kind == keywordToken && t.text == kw {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		t := p.peek()
+		return fmt.Errorf("at %d: expected %s, got %v", t.pos, kw, t)
+	}
+	return nil
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if t := p.peek(); t.kind == opToken && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		t := p.peek()
+		return fmt.Errorf("at %d: expected %q, got %v", t.pos, op, t)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (string, error) {
+	t := p.next()
+	if t.kind != identToken {
+		return "", fmt.Errorf("at %d: expected identifier, got %v", t.pos, t)
+	}
+	return t.text, nil
+}
+
+func (p *parser) parseSelect() (*selectStmt, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	s := &selectStmt{}
+	if p.acceptOp("*") {
+		s.star = true
+	} else {
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item := selectItem{e: e}
+			if p.acceptKeyword("AS") {
+				if item.alias, err = p.expectIdent(); err != nil {
+					return nil, err
+				}
+			} else if p.peek().kind == identToken {
+				item.alias = p.next().text
+			}
+			s.items = append(s.items, item)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.from, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("AS") {
+		if s.alias, err = p.expectIdent(); err != nil {
+			return nil, err
+		}
+	} else if p.peek().kind == identToken {
+		s.alias = p.next().text
+	}
+	if p.acceptKeyword("WHERE") {
+		if s.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			s.groupBy = append(s.groupBy, e)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	return s, nil
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "OR", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "AND", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", e: e}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{e: l, not: not}, nil
+	}
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.acceptOp(op) {
+			r, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			return &binaryExpr{op: op, l: l, r: r}, nil
+		}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != opToken || (t.text != "+" && t.text != "-") {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != opToken || (t.text != "*" && t.text != "/" && t.text != "%") {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptOp("-") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "-", e: e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case numberToken:
+		return parseNumber(t)
+	case stringToken:
+		return &literal{v: values.New(t.text)}, nil
+	case keywordToken:
+		switch t.text {
+		case "TRUE":
+			return &literal{v: values.New(true)}, nil
+		case "FALSE":
+			return &literal{v: values.New(false)}, nil
+		case "INTERVAL":
+			return p.parseInterval()
+		}
+	case opToken:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	case identToken:
+		if p.acceptOp("(") {
+			return p.parseCall(t.text)
+		}
+		if p.acceptOp(".") {
+			name, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			return &columnRef{table: t.text, name: name}, nil
+		}
+		return &columnRef{name: t.text}, nil
+	}
+	return nil, p.unexpected(t)
+}
+
+func parseNumber(t token) (expr, error) {
+	if strings.Contains(t.text, ".") {
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("at %d: invalid number %s", t.pos, t.text)
+		}
+		return &literal{v: values.New(f)}, nil
+	}
+	i, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("at %d: invalid number %s", t.pos, t.text)
+	}
+	return &literal{v: values.New(i)}, nil
+}
+
+func (p *parser) parseCall(name string) (expr, error) {
+	c := &callExpr{name: strings.ToUpper(name)}
+	if p.acceptOp("*") {
+		c.star = true
+		return c, p.expectOp(")")
+	}
+	if p.acceptOp(")") {
+		return c, nil
+	}
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, e)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return c, p.expectOp(")")
+}
+
+var intervalUnits = map[string]time.Duration{
+	"MILLISECOND": time.Millisecond,
+	"SECOND":      time.Second,
+	"MINUTE":      time.Minute,
+	"HOUR":        time.Hour,
+	"DAY":         24 * time.Hour,
+}
+
+// parseInterval parses INTERVAL '<n>' [unit], after INTERVAL.
+// Intervals with a unit are in nanoseconds, like the timestamps of times, the others are in the unit of timestamps.
+func (p *parser) parseInterval() (expr, error) {
+	t := p.next()
+	if t.kind != stringToken && t.kind != numberToken {
+		return nil, fmt.Errorf("at %d: expected interval length, got %v", t.pos, t)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(t.text), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("at %d: invalid interval length %v", t.pos, t)
+	}
+	e := &intervalExpr{n: n, text: t.String()}
+	if u := p.peek(); u.kind == identToken {
+		d, ok := intervalUnits[strings.TrimSuffix(strings.ToUpper(u.text), "S")]
+		if !ok {
+			return nil, fmt.Errorf("at %d: unknown interval unit %v", u.pos, u)
+		}
+		p.next()
+		e.n *= int64(d)
+		e.text += " " + strings.ToUpper(u.text)
+	}
+	return e, nil
+}
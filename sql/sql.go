@@ -0,0 +1,112 @@
+// Package sql compiles SQL queries over streams of records into ssp graphs.
+//
+// Queries have the form:
+//
+//	SELECT <expressions> FROM <stream> [WHERE <condition>] [GROUP BY <columns>[, TUMBLE(<column>, INTERVAL <n> [unit])]]
+//
+// Streams are registered in a Catalog as Tables, that are streams of values.Record with a known schema.
+// Queries without aggregates emit a record for every input record that satisfies the condition.
+// Queries with aggregates and no window emit the updated record of a group for every input record.
+// Queries grouped by TUMBLE emit a record per group when a window closes,
+// timestamped with the last instant in the window.
+//
+// Expressions support arithmetic, comparisons, AND, OR, NOT, IS [NOT] NULL,
+// the functions UPPER and LOWER, the aggregates COUNT, SUM, MIN, MAX and AVG,
+// and TUMBLE_START and TUMBLE_END for the bounds of windows.
+// Nulls follow the rules of SQL: expressions with a null operand are null, and conditions that are null are not satisfied.
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+)
+
+// Table is a stream of records with the same schema.
+type Table struct {
+	Schema *values.Schema
+	// Out is the stream of records.
+	Out *ssp.Arch
+}
+
+// Catalog holds the tables that queries can select from.
+type Catalog struct {
+	mu     sync.RWMutex
+	tables map[string]Table
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{tables: make(map[string]Table)}
+}
+
+// Register makes t available to queries with the given name.
+func (c *Catalog) Register(name string, t Table) error {
+	if t.Schema == nil || t.Out == nil {
+		return fmt.Errorf("table %q: schema and stream are required", name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.tables[name]; ok {
+		return fmt.Errorf("table %q already registered", name)
+	}
+	c.tables[name] = t
+	return nil
+}
+
+func (c *Catalog) table(name string) (Table, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t, ok := c.tables[name]
+	if !ok {
+		return Table{}, fmt.Errorf("unknown table %q", name)
+	}
+	return t, nil
+}
+
+type queryOptions struct {
+	par   int
+	delay int64
+}
+
+type QueryOption func(o *queryOptions)
+
+// WithParallelism sets the parallelism of the node that groups records.
+func WithParallelism(par int) QueryOption {
+	return func(o *queryOptions) {
+		o.par = par
+	}
+}
+
+// WithWatermarkDelay makes windows wait for records up to delay after their timestamp,
+// in the unit of timestamps.
+// By default, the watermark is the timestamp of the last record, and records that arrive late are dropped.
+func WithWatermarkDelay(delay int64) QueryOption {
+	return func(o *queryOptions) {
+		o.delay = delay
+	}
+}
+
+// Query compiles q and adds its nodes to the Graph in ctx.
+// The returned Table is the result of the query, and it can be registered for other queries to select from.
+func (c *Catalog) Query(ctx context.Context, q string, opts ...QueryOption) (Table, error) {
+	o := queryOptions{par: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s, err := parse(q)
+	if err != nil {
+		return Table{}, fmt.Errorf("cannot parse query: %v", err)
+	}
+	t, err := c.table(s.from)
+	if err != nil {
+		return Table{}, err
+	}
+	p, err := compile(s, t.Schema)
+	if err != nil {
+		return Table{}, fmt.Errorf("cannot compile query: %v", err)
+	}
+	return p.build(ctx, t, o), nil
+}
@@ -0,0 +1,268 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+var eventSchema = values.MustNewSchema(
+	values.Field{Name: "user", Type: values.String},
+	values.Field{Name: "amount", Type: values.Int64, Nullable: true},
+	values.Field{Name: "at", Type: values.Time},
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func event(user string, amount int64, sec int) values.Value {
+	a := values.New(amount)
+	if amount < 0 {
+		a = values.NewNull(values.Int64)
+	}
+	return values.MustNewRecord(eventSchema, values.New(user), a, values.New(epoch.Add(time.Duration(sec)*time.Second)))
+}
+
+var events = []values.Value{
+	event("ann", 10, 0),
+	event("bob", 5, 20),
+	event("ann", -1, 40),
+	event("ann", 7, 70),
+	event("bob", 1, 90),
+	// Close every window.
+	event("ann", 0, 300),
+	event("bob", 0, 300),
+}
+
+func newCatalog(ctx context.Context) *Catalog {
+	source := ssp.NewNode(func(collector ssp.Collector, _ values.Value) error {
+		for _, v := range events {
+			collector.Collect(v)
+		}
+		return nil
+	}).SetName("events")
+	c := NewCatalog()
+	if err := c.Register("events", Table{Schema: eventSchema, Out: source.Out()}); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		q      string
+		opts   []QueryOption
+		schema string
+		want   []string
+	}{
+		{
+			name:   "star",
+			q:      "SELECT * FROM events WHERE amount >= 7",
+			schema: "record<user: string, amount: int64?, at: time>",
+			want: []string{
+				"{user: ann, amount: 10, at: 2020-01-01 00:00:00 +0000 UTC}",
+				"{user: ann, amount: 7, at: 2020-01-01 00:01:10 +0000 UTC}",
+			},
+		},
+		{
+			name:   "projection",
+			q:      "SELECT UPPER(user) AS name, amount * 2, amount IS NULL nothing FROM events e WHERE e.user <> 'bob' AND NOT amount = 0",
+			schema: "record<name: string?, amount * 2: int64?, nothing: bool?>",
+			want: []string{
+				"{name: ANN, amount * 2: 14, nothing: false}",
+				"{name: ANN, amount * 2: 20, nothing: false}",
+			},
+		},
+		{
+			name:   "null condition",
+			q:      "SELECT user FROM events WHERE amount < 1 OR amount > 9",
+			schema: "record<user: string?>",
+			want:   []string{"{user: ann}", "{user: ann}", "{user: bob}"},
+		},
+		{
+			name:   "group by",
+			q:      "SELECT user, COUNT(*) AS n, COUNT(amount) AS amounts, SUM(amount) AS total FROM events GROUP BY user",
+			opts:   []QueryOption{WithParallelism(2)},
+			schema: "record<user: string?, n: int64?, amounts: int64?, total: int64?>",
+			want: []string{
+				"{user: ann, n: 1, amounts: 1, total: 10}",
+				"{user: ann, n: 2, amounts: 1, total: 10}",
+				"{user: ann, n: 3, amounts: 2, total: 17}",
+				"{user: ann, n: 4, amounts: 3, total: 17}",
+				"{user: bob, n: 1, amounts: 1, total: 5}",
+				"{user: bob, n: 2, amounts: 2, total: 6}",
+				"{user: bob, n: 3, amounts: 3, total: 6}",
+			},
+		},
+		{
+			name:   "global aggregate",
+			q:      "SELECT MAX(amount) - MIN(amount) AS spread FROM events WHERE amount > 0",
+			schema: "record<spread: int64?>",
+			want: []string{
+				"{spread: 0}",
+				"{spread: 5}",
+				"{spread: 5}",
+				"{spread: 9}",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			defer leaktest.Check(t)()
+
+			ctx := ssp.Context()
+			res, err := newCatalog(ctx).Query(ctx, tc.q, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.schema, res.Schema.String()); diff != "" {
+				t.Errorf("unexpected schema -want/+got:\n\t%s", diff)
+			}
+			got := collect(ctx, res, false)
+			if err := ssp.Execute(ctx); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(*got)
+			if diff := cmp.Diff(tc.want, *got); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuery_Tumble(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	res, err := newCatalog(ctx).Query(ctx, `
+		-- Average amount per user and minute.
+		SELECT user, TUMBLE_START(at, INTERVAL '1' MINUTE) AS start, TUMBLE_END(at, INTERVAL '1' MINUTE) AS stop, AVG(amount) AS avg
+		FROM events
+		GROUP BY user, TUMBLE(at, INTERVAL '1' MINUTES)`, WithParallelism(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(ctx, res, true)
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(*got)
+	ts := func(sec int) values.Timestamp {
+		return values.ConvertTime(epoch.Add(time.Duration(sec)*time.Second)) - 1
+	}
+	want := []string{
+		fmt.Sprintf("{user: ann, start: 2020-01-01 00:00:00 +0000 UTC, stop: 2020-01-01 00:01:00 +0000 UTC, avg: 10}@%d", ts(60)),
+		fmt.Sprintf("{user: ann, start: 2020-01-01 00:01:00 +0000 UTC, stop: 2020-01-01 00:02:00 +0000 UTC, avg: 7}@%d", ts(120)),
+		fmt.Sprintf("{user: bob, start: 2020-01-01 00:00:00 +0000 UTC, stop: 2020-01-01 00:01:00 +0000 UTC, avg: 5}@%d", ts(60)),
+		fmt.Sprintf("{user: bob, start: 2020-01-01 00:01:00 +0000 UTC, stop: 2020-01-01 00:02:00 +0000 UTC, avg: 1}@%d", ts(120)),
+	}
+	if diff := cmp.Diff(want, *got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestQuery_Chained(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	c := newCatalog(ctx)
+	big, err := c.Query(ctx, "SELECT user, amount FROM events WHERE amount > 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Register("big", big); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Query(ctx, "SELECT COUNT(*) AS n FROM big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(ctx, res, false)
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(*got)
+	if diff := cmp.Diff([]string{"{n: 1}", "{n: 2}"}, *got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		q    string
+		want string
+	}{
+		{q: "SELECT user WHERE amount > 1", want: "cannot parse query: at 12: expected FROM, got WHERE"},
+		{q: "SELECT 'user FROM events", want: "cannot parse query: at 7: unterminated '"},
+		{q: "SELECT user FROM events WHERE", want: "cannot parse query: at 29: unexpected end of query"},
+		{q: "SELECT user FROM events LIMIT 1", want: "cannot parse query: at 24: unexpected LIMIT"},
+		{q: "SELECT user FROM clicks", want: `unknown table "clicks"`},
+		{q: "SELECT name FROM events", want: `cannot compile query: unknown column "name" in record<user: string, amount: int64?, at: time>`},
+		{q: "SELECT c.user FROM events", want: `cannot compile query: unknown table "c" in c.user`},
+		{q: "SELECT user FROM events WHERE amount", want: "cannot compile query: WHERE requires a bool, got int64"},
+		{q: "SELECT user FROM events WHERE COUNT(*) > 1", want: "cannot compile query: count(*): aggregates are not allowed in WHERE"},
+		{q: "SELECT user + 1 FROM events", want: "cannot compile query: user + 1: + requires numbers, got string and int64"},
+		{q: "SELECT user = 1 FROM events", want: "cannot compile query: user = 1: cannot compare string and int64"},
+		{q: "SELECT * FROM events GROUP BY user", want: "cannot compile query: SELECT * is not allowed in grouped queries"},
+		{q: "SELECT amount, COUNT(*) FROM events GROUP BY user", want: `cannot compile query: column "amount" must appear in GROUP BY or be used in an aggregate`},
+		{q: "SELECT SUM(user) FROM events", want: "cannot compile query: sum(user): cannot sum string"},
+		{q: "SELECT COUNT(*) FROM events GROUP BY amount + 1", want: "cannot compile query: GROUP BY amount + 1: only columns and TUMBLE are allowed"},
+		{q: "SELECT COUNT(*) FROM events GROUP BY TUMBLE(user, INTERVAL '1' SECOND)", want: `cannot compile query: tumble(user, INTERVAL '1' SECOND): column "user" must be a time or an integer, got user: string`},
+		{q: "SELECT COUNT(*) FROM events GROUP BY TUMBLE(amount, INTERVAL '1')", want: `cannot compile query: tumble(amount, INTERVAL '1'): column "amount" must not be nullable`},
+		{q: "SELECT COUNT(*) FROM events GROUP BY TUMBLE(at, INTERVAL '1' WEEK)", want: "cannot parse query: at 61: unknown interval unit WEEK"},
+		{q: "SELECT TUMBLE_END(at, INTERVAL '2' SECOND) FROM events GROUP BY TUMBLE(at, INTERVAL '1' SECOND)", want: "cannot compile query: tumble_end(at, INTERVAL '2' SECOND): requires GROUP BY TUMBLE(at, INTERVAL '2' SECOND)"},
+		{q: "SELECT TUMBLE(at, INTERVAL '1' SECOND) FROM events", want: "cannot compile query: tumble(at, INTERVAL '1' SECOND): TUMBLE is only allowed in GROUP BY"},
+		{q: "SELECT user, LOWER(user) AS user FROM events", want: `cannot compile query: invalid output: duplicate field "user"`},
+	} {
+		t.Run(tc.q, func(t *testing.T) {
+			ctx := ssp.Context()
+			_, err := newCatalog(ctx).Query(ctx, tc.q)
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+			if diff := cmp.Diff(tc.want, err.Error()); diff != "" {
+				t.Errorf("unexpected error -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuery_EvalErrors(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	res, err := newCatalog(ctx).Query(ctx, "SELECT amount / (amount - 5) FROM events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(ctx, res, false)
+	if err := ssp.Execute(ctx); err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+// collect collects the string representation of the records in t, with their timestamps if withTime is set.
+func collect(ctx context.Context, t Table, withTime bool) *[]string {
+	var mu sync.Mutex
+	var got []string
+	t.Out.Sink(ctx, func(v values.Value) error {
+		s := fmt.Sprint(v)
+		if withTime {
+			ts, _, _ := values.GetTime(v)
+			s = fmt.Sprintf("%v@%d", v, ts)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, s)
+		return nil
+	})
+	return &got
+}
@@ -15,5 +15,25 @@ func AssignTimestamp(tse TimestampExtractorFn) Node {
 		ts, wm := tse(v)
 		collector.Collect(values.SetTime(ts, wm, v))
 		return nil
-	})
+	}).SetTimestamps(WithTimestamps)
+}
+
+// Timestamps tells whether a Node emits timestamped values.
+type Timestamps int
+
+const (
+	// UnknownTimestamps is for Nodes that do not declare anything.
+	UnknownTimestamps Timestamps = iota
+	// KeepsTimestamps is for Nodes whose values are timestamped if their input values are.
+	KeepsTimestamps
+	// WithTimestamps is for Nodes that always emit timestamped values.
+	WithTimestamps
+	// NoTimestamps is for Nodes that never emit timestamped values.
+	NoTimestamps
+)
+
+// TimestampedNode is implemented by Nodes that declare whether they emit timestamped values.
+// Validate uses it for finding windows fed by values without timestamps.
+type TimestampedNode interface {
+	GetTimestamps() Timestamps
 }
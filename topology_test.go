@@ -1,6 +1,7 @@
 package ssp
 
 import (
+	"fmt"
 	"strconv"
 	"testing"
 
@@ -47,3 +48,103 @@ func Test_NewTopology(t *testing.T) {
 		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
 	}
 }
+
+func Test_ReplaceSource(t *testing.T) {
+	ctx := Context()
+	source := newSliceSource(ints(1, 2, 3)...)
+	double := source.Out().Map(ctx, func(v values.Value) (values.Value, error) {
+		return values.New(v.Int() * 2), nil
+	})
+	sink, log := NewLogSink(values.Int)
+	double.Out().Connect(ctx, sink)
+
+	// The graph in ctx shares the arches of the one returned by GetGraph.
+	g := GetGraph(ctx)
+	if !g.ReplaceNode(source, newSliceSource(ints(10)...).SetName("fake")) {
+		t.Fatal("source not replaced")
+	}
+	if err := g.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"20"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func Test_SpliceKeyed(t *testing.T) {
+	ctx := Context()
+	parity := FnKeySelector(func(v values.Value) values.Key {
+		return values.Key(v.Int() % 2)
+	})
+	source := newSliceSource(ints(1, 2, 3, 4)...)
+	sink, log := NewLogSink(values.Int64)
+	source.Out().KeyBy(parity).Aggregate(ctx, Count()).SetParallelism(2).Out().Connect(ctx, sink.SetName("sink"))
+	g := GetGraph(ctx)
+
+	// The spliced node sits between the source and the keyed count.
+	identity := NewNode(func(collector Collector, v values.Value) error {
+		collector.Collect(forward(v))
+		return nil
+	}).SetName("identity")
+	in, out := g.Splice(g.Adjacents(source)[0], identity)
+	if in == nil || out == nil {
+		t.Fatal("arch not spliced")
+	}
+	if out.ks == nil {
+		t.Fatal("the outgoing arch lost its key selector")
+	}
+	if _, warns := Validate(g); len(warns) > 0 {
+		t.Errorf("unexpected warnings: %v", warns)
+	}
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"1", "1", "2", "2"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func Test_SpliceDeadLetters(t *testing.T) {
+	ctx := Context()
+	parser := NewNode(func(collector Collector, v values.Value) error {
+		if v.Int() < 0 {
+			return fmt.Errorf("negative value")
+		}
+		collector.Collect(v)
+		return nil
+	}).SetErrorPolicy(DeadLetterOnError).SetName("parser")
+	newSliceSource(ints(1, -2, -3)...).Out().Connect(ctx, parser).Out().Sink(ctx, func(values.Value) error {
+		return nil
+	})
+	sink, log := NewLogSink(values.Int)
+	DeadLetters(parser).Connect(ctx, sink.SetName("dlq"))
+	g := GetGraph(ctx)
+
+	// The spliced node turns dead letters into the values that caused them.
+	extract := NewNode(func(collector Collector, v values.Value) error {
+		collector.Collect(v.Get().(DeadLetter).Value)
+		return nil
+	}).SetName("extract")
+	var dlq *Arch
+	for _, a := range g.Adjacents(parser) {
+		if a.tag == DeadLetterTag {
+			dlq = a
+		}
+	}
+	in, out := g.Splice(dlq, extract)
+	if in == nil || out == nil {
+		t.Fatal("arch not spliced")
+	}
+	if in.tag != DeadLetterTag || out.tag != "" {
+		t.Fatalf("unexpected tags: %q, %q", in.tag, out.tag)
+	}
+	if err := Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"-2", "-3"}, sortedStrings(log.GetValues())); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
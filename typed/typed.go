@@ -0,0 +1,278 @@
+// Package typed provides a type-safe API for building ssp graphs.
+//
+// Streams carry Go values of a static type T. They compile down to the usual ssp Nodes and Arches,
+// so that typed and untyped graphs can be mixed and executed with ssp.Execute.
+// Values of type T travel as values.Value, using values.New for wrapping them.
+// Timestamps of input records are kept by the outputs of Map, Filter, FlatMap and Reduce.
+package typed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+)
+
+// Stream is a stream of values of type T.
+type Stream[T any] struct {
+	ctx  context.Context
+	node ssp.Node
+}
+
+// From returns the stream of values produced by an untyped Node.
+// It is up to the caller to guarantee that the node produces values of type T.
+func From[T any](ctx context.Context, n ssp.Node) Stream[T] {
+	return Stream[T]{ctx: ctx, node: n}
+}
+
+// Source creates a stream from a function that emits values.
+func Source[T any](ctx context.Context, f func(emit func(T)) error) Stream[T] {
+	return From[T](ctx, ssp.NewNode(func(collector ssp.Collector, _ values.Value) error {
+		return f(func(t T) {
+			collector.Collect(toValue(t))
+		})
+	}).SetTimestamps(ssp.NoTimestamps))
+}
+
+// Of creates a stream that emits the given values.
+func Of[T any](ctx context.Context, ts ...T) Stream[T] {
+	return Source(ctx, func(emit func(T)) error {
+		for _, t := range ts {
+			emit(t)
+		}
+		return nil
+	})
+}
+
+// Node returns the Node that produces the stream.
+func (s Stream[T]) Node() ssp.Node {
+	return s.node
+}
+
+// Out returns the Arch that outputs the stream, for connecting untyped Nodes.
+func (s Stream[T]) Out() *ssp.Arch {
+	return s.node.Out()
+}
+
+func (s Stream[T]) Name(name string) Stream[T] {
+	s.node.SetName(name)
+	return s
+}
+
+func (s Stream[T]) Parallelism(par int) Stream[T] {
+	s.node.SetParallelism(par)
+	return s
+}
+
+func (s Stream[T]) ErrorPolicy(p ssp.ErrorPolicy) Stream[T] {
+	ssp.SetErrorPolicy(s.node, p)
+	return s
+}
+
+// KeyedStream is a stream of values of type T, partitioned by keys of type K.
+type KeyedStream[T any, K comparable] struct {
+	s  Stream[T]
+	ks ssp.KeySelector
+}
+
+// KeyBy partitions the stream by the key returned by f.
+// Values with the same key are processed by the same instance of the next node.
+func KeyBy[T any, K comparable](s Stream[T], f func(T) K) KeyedStream[T, K] {
+	return KeyedStream[T, K]{
+		s: s,
+		ks: ssp.FnKeySelector(func(v values.Value) values.Key {
+			t, err := fromValue[T](v)
+			if err != nil {
+				panic(err)
+			}
+			return values.Key(values.Hash(toValue(f(t))))
+		}),
+	}
+}
+
+func connect[T, U any](s Stream[T], ks ssp.KeySelector, n ssp.Node) Stream[U] {
+	a := s.node.Out()
+	if ks != nil {
+		a = a.KeyBy(ks)
+	}
+	a.Connect(s.ctx, n)
+	return From[U](s.ctx, n)
+}
+
+// Map applies f to every value in the stream.
+func Map[T, U any](s Stream[T], f func(T) (U, error)) Stream[U] {
+	return connect[T, U](s, nil, ssp.NewNode(func(collector ssp.Collector, v values.Value) error {
+		t, err := fromValue[T](v)
+		if err != nil {
+			return err
+		}
+		u, err := f(t)
+		if err != nil {
+			return err
+		}
+		collector.Collect(output(v, u))
+		return nil
+	}).SetTimestamps(ssp.KeepsTimestamps))
+}
+
+// Filter keeps the values for which f returns true.
+func Filter[T any](s Stream[T], f func(T) bool) Stream[T] {
+	return connect[T, T](s, nil, ssp.NewNode(func(collector ssp.Collector, v values.Value) error {
+		t, err := fromValue[T](v)
+		if err != nil {
+			return err
+		}
+		if f(t) {
+			collector.Collect(output(v, t))
+		}
+		return nil
+	}).SetTimestamps(ssp.KeepsTimestamps))
+}
+
+// FlatMap applies f to every value in the stream, and emits every value returned.
+func FlatMap[T, U any](s Stream[T], f func(T) ([]U, error)) Stream[U] {
+	return connect[T, U](s, nil, ssp.NewNode(func(collector ssp.Collector, v values.Value) error {
+		t, err := fromValue[T](v)
+		if err != nil {
+			return err
+		}
+		us, err := f(t)
+		if err != nil {
+			return err
+		}
+		for _, u := range us {
+			collector.Collect(output(v, u))
+		}
+		return nil
+	}).SetTimestamps(ssp.KeepsTimestamps))
+}
+
+// reduced holds the state of Reduce.
+type reduced[T any] struct {
+	acc T
+}
+
+// Reduce combines the values with the same key using f, and emits the result at every value.
+// The first value for a key is emitted as it is.
+func Reduce[T any, K comparable](s KeyedStream[T, K], f func(acc, v T) T) Stream[T] {
+	// The initial state is null, which holds no reduced value.
+	state0 := values.NewNull(values.Object)
+	return connect[T, T](s.s, s.ks, ssp.NewStatefulNode(state0,
+		func(state values.Value, collector ssp.Collector, v values.Value) (values.Value, error) {
+			t, err := fromValue[T](v)
+			if err != nil {
+				return nil, err
+			}
+			if r, ok := state.Get().(reduced[T]); ok {
+				t = f(r.acc, t)
+			}
+			collector.Collect(output(v, t))
+			return values.New(reduced[T]{acc: t}), nil
+		}).SetTimestamps(ssp.KeepsTimestamps))
+}
+
+// Composite is a reusable fragment of a graph, from a stream of I to a stream of O.
+type Composite[I, O any] struct {
+	c *ssp.Composite
+}
+
+// Compose creates a Composite named name, whose fragment is built by f when the Composite is applied to a stream.
+func Compose[I, O any](name string, f func(in Stream[I]) Stream[O]) Composite[I, O] {
+	return Composite[I, O]{c: ssp.NewComposite(name, func(ctx context.Context, in ssp.Node) ssp.Node {
+		return f(From[I](ctx, in)).node
+	})}
+}
+
+// Node returns the untyped Composite.
+func (c Composite[I, O]) Node() *ssp.Composite {
+	return c.c
+}
+
+// Apply connects the stream to c, and returns the output of its fragment.
+func Apply[I, O any](s Stream[I], c Composite[I, O]) Stream[O] {
+	s.node.Out().Connect(s.ctx, c.c)
+	return From[O](s.ctx, c.c.Output(s.ctx))
+}
+
+// Sink consumes the values in the stream with f.
+func Sink[T any](s Stream[T], f func(T) error) ssp.Node {
+	n := ssp.NewNode(func(_ ssp.Collector, v values.Value) error {
+		t, err := fromValue[T](v)
+		if err != nil {
+			return err
+		}
+		return f(t)
+	})
+	s.node.Out().Connect(s.ctx, n)
+	return n
+}
+
+// Log collects the values received by a sink.
+type Log[T any] struct {
+	mu sync.Mutex
+	ts []T
+}
+
+// Values returns the values collected so far.
+func (l *Log[T]) Values() []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ts := make([]T, len(l.ts))
+	copy(ts, l.ts)
+	return ts
+}
+
+// LogSink connects a sink that collects every value in the stream into a Log.
+func LogSink[T any](s Stream[T]) (ssp.Node, *Log[T]) {
+	l := &Log[T]{}
+	n := Sink(s, func(t T) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.ts = append(l.ts, t)
+		return nil
+	})
+	return n, l
+}
+
+// isValue returns true for streams of values.Value, that must not be wrapped.
+func isValue[T any]() bool {
+	_, ok := any((*T)(nil)).(*values.Value)
+	return ok
+}
+
+// toValue wraps t into a value.
+func toValue[T any](t T) values.Value {
+	if isValue[T]() {
+		return any(t).(values.Value)
+	}
+	return values.New(t)
+}
+
+// fromValue unwraps a value of type T.
+func fromValue[T any](v values.Value) (T, error) {
+	if isValue[T]() {
+		return any(v).(T), nil
+	}
+	var t T
+	o := v.Get()
+	if o == nil {
+		// Null values map to the zero value.
+		return t, nil
+	}
+	t, ok := o.(T)
+	if !ok {
+		return t, fmt.Errorf("unexpected value %v of type %T, want %T", v, o, t)
+	}
+	return t, nil
+}
+
+// output wraps u into a value, keeping the timestamp of the input value, if any.
+func output[U any](in values.Value, u U) values.Value {
+	v := toValue(u)
+	if ts, wm, err := values.GetTime(in); err == nil {
+		v = values.SetTime(ts, wm, v)
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package typed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/affo/ssp"
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+type count struct {
+	Word string
+	N    int
+}
+
+func TestWordCount(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	lines := Of(ctx, "a b", "", "b c a", "a").Name("lines")
+	words := FlatMap(lines, func(l string) ([]string, error) {
+		return strings.Fields(l), nil
+	}).Name("words")
+	counts := Map(words, func(w string) (count, error) {
+		return count{Word: w, N: 1}, nil
+	})
+	summed := Reduce(KeyBy(counts, func(c count) string {
+		return c.Word
+	}), func(acc, c count) count {
+		acc.N += c.N
+		return acc
+	}).Name("sum").Parallelism(4)
+	_, log := LogSink(Filter(summed, func(c count) bool {
+		return c.N > 1
+	}))
+
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := log.Values()
+	sort.Slice(got, func(i, j int) bool {
+		if got[i].Word != got[j].Word {
+			return got[i].Word < got[j].Word
+		}
+		return got[i].N < got[j].N
+	})
+	want := []count{{"a", 2}, {"a", 3}, {"b", 2}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestMixed(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	// An untyped node producing timestamped ints.
+	source := ssp.NewNode(func(collector ssp.Collector, _ values.Value) error {
+		for i := 1; i <= 3; i++ {
+			collector.Collect(values.SetTime(values.Timestamp(i*10), 0, values.New(i)))
+		}
+		return nil
+	})
+	doubled := Map(From[int](ctx, source), func(i int) (int, error) {
+		return i * 2, nil
+	})
+	// Back to untyped, in order to check timestamps.
+	_, log := LogSink(Map(From[values.Value](ctx, doubled.Node()), func(v values.Value) (string, error) {
+		ts, _, err := values.GetTime(v)
+		return fmt.Sprintf("%d@%d", v.Int(), ts), err
+	}))
+
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"2@10", "4@20", "6@30"}, log.Values()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	strs := Of(ctx, "a", "b")
+	Sink(From[int](ctx, strs.Node()), func(int) error {
+		return nil
+	})
+	err := ssp.Execute(ctx)
+	if err == nil || !strings.Contains(err.Error(), "unexpected value a of type string, want int") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx := ssp.Context()
+	words := Compose("words", func(in Stream[string]) Stream[string] {
+		ws := FlatMap(in, func(l string) ([]string, error) {
+			return strings.Fields(l), nil
+		}).Name("split")
+		return Map(ws, func(w string) (string, error) {
+			return strings.ToLower(w), nil
+		}).Name("lower")
+	})
+	sink, log := LogSink(Apply(Of(ctx, "A b", "c").Name("lines"), words))
+	sink.SetName("sink")
+
+	want := `lines -> words/input
+words/input -> words/split
+words/lower -> sink
+words/split -> words/lower
+`
+	if diff := cmp.Diff(want, ssp.GetGraph(ctx).String()); diff != "" {
+		t.Errorf("unexpected graph -want/+got:\n\t%s", diff)
+	}
+	if err := ssp.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, log.Values()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,134 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/affo/ssp/values"
+)
+
+type UnionOption func(n *unionNode)
+
+// WithStrippedSource makes Union emit values without their values.Origin.
+func WithStrippedSource() UnionOption {
+	return func(n *unionNode) {
+		n.strip = true
+	}
+}
+
+// WithUnionType makes Union fail on values whose type is not t.
+// Nulls of any type are accepted.
+func WithUnionType(t values.Type) UnionOption {
+	return func(n *unionNode) {
+		n.t = t
+		n.typed = true
+	}
+}
+
+// unionNode merges the values from multiple Arches.
+type unionNode struct {
+	baseNode
+	ins   []*Arch
+	strip bool
+	t     values.Type
+	typed bool
+}
+
+// Union merges the values from the given Arches into a single stream.
+// Values are tagged with the values.Origin equal to the position of their Arch in arches,
+// unless WithStrippedSource is used. The values.Source of the values is set by the inputs of
+// the nodes receiving them, as for any other node.
+// The watermark of the union is the minimum watermark of its inputs:
+// it does not advance until every input sends a timestamped value, or closes.
+func Union(ctx context.Context, arches []*Arch, opts ...UnionOption) Node {
+	n := &unionNode{
+		baseNode: newBaseNode(),
+		ins:      arches,
+	}
+	n.SetName(uniqueName(ctx, "union"))
+	for _, opt := range opts {
+		opt(n)
+	}
+	for _, a := range arches {
+		a.Connect(ctx, n)
+	}
+	return n
+}
+
+func (n *unionNode) Do(collector Collector, v values.Value) error {
+	if n.typed {
+		if u := undecorated(v); u.Type() != n.t && !(u.IsNull() && u.Type() != values.Object) {
+			return fmt.Errorf("union of %v values got a value of type %v: %v", n.t, u.Type(), u)
+		}
+	}
+	out := forward(v)
+	if s, err := values.GetSource(v); err == nil && !n.strip {
+		out = values.SetOrigin(values.Origin(s), out)
+	}
+	collector.Collect(out)
+	return nil
+}
+
+// sortInputs sorts the arches connected to the node in the order in which they were passed to Union.
+// Arches in the graph are copies of the ones passed to Union, so they are matched by origin and tag.
+func (n *unionNode) sortInputs(in []*Arch) []*Arch {
+	sorted := make([]*Arch, 0, len(in))
+	used := make([]bool, len(in))
+	for _, a := range n.ins {
+		for i, b := range in {
+			if !used[i] && a.From() == b.From() && a.tag == b.tag {
+				used[i] = true
+				sorted = append(sorted, b)
+				break
+			}
+		}
+	}
+	// Arches connected to the union other than via Union come last.
+	for i, b := range in {
+		if !used[i] {
+			sorted = append(sorted, b)
+		}
+	}
+	return sorted
+}
+
+func (n *unionNode) Out() *Arch {
+	return NewLink(n)
+}
+
+func (n *unionNode) OutTag(tag string) *Arch {
+	return NewTaggedLink(n, tag)
+}
+
+func (n *unionNode) DeadLetters() *Arch {
+	return n.OutTag(DeadLetterTag)
+}
+
+func (n *unionNode) SetParallelism(par int) Node {
+	n.par = par
+	return n
+}
+
+func (n *unionNode) SetName(name string) Node {
+	n.name = name
+	return n
+}
+
+func (n *unionNode) SetErrorPolicy(p ErrorPolicy) Node {
+	n.ep = p
+	return n
+}
+
+func (n *unionNode) GetTimestamps() Timestamps {
+	return KeepsTimestamps
+}
+
+func (n *unionNode) Clone() Node {
+	return &unionNode{
+		baseNode: n.baseNode.Clone(),
+		ins:      n.ins,
+		strip:    n.strip,
+		t:        n.t,
+		typed:    n.typed,
+	}
+}
@@ -0,0 +1,143 @@
+package ssp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnion(t *testing.T) {
+	// Every source emits its own index.
+	// Names are in reverse order, so that the order of the Arches in the graph differs from the one in Union.
+	sources := func(n int) []*Arch {
+		as := make([]*Arch, n)
+		for i := range as {
+			as[i] = newSliceSource(ints(i, i)...).SetName(string(rune('a' + n - i))).Out()
+		}
+		return as
+	}
+	// describe returns the value, the source and the origin of v.
+	describe := func(v values.Value) values.Value {
+		s, _ := values.GetSource(v)
+		o, err := values.GetOrigin(v)
+		if err != nil {
+			return values.New(fmt.Sprintf("%v@%d", undecorated(v), s))
+		}
+		return values.New(fmt.Sprintf("%v@%d/%d", undecorated(v), s, o))
+	}
+	run := func(t *testing.T, build func(ctx context.Context) Node) ([]string, error) {
+		ctx := Context()
+		var got []values.Value
+		build(ctx).Out().Connect(ctx, NewStatefulNode(values.NewNull(values.Object),
+			func(state values.Value, _ Collector, v values.Value) (values.Value, error) {
+				got = append(got, describe(v))
+				return state, nil
+			}))
+		err := Execute(ctx)
+		return sortedStrings(got), err
+	}
+
+	t.Run("source", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		got, err := run(t, func(ctx context.Context) Node {
+			return Union(ctx, sources(3))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"0@0/0", "0@0/0", "1@0/1", "1@0/1", "2@0/2", "2@0/2"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("stripped source", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		got, err := run(t, func(ctx context.Context) Node {
+			return Union(ctx, sources(3), WithStrippedSource())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"0@0", "0@0", "1@0", "1@0", "2@0", "2@0"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("next to other inputs", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		// j gets the other source from its input 0, and the union from its input 1.
+		ctx := Context()
+		as := sources(2)
+		c := newSliceSource(ints(3)...).SetName("other").Out()
+		j := NewNode(func(collector Collector, v values.Value) error {
+			collector.Collect(describe(v))
+			return nil
+		}).SetName("j")
+		Union(ctx, as).Out().Connect(ctx, j)
+		c.Connect(ctx, j)
+		sink, log := NewLogSink(values.String)
+		j.Out().Connect(ctx, sink.SetName("sink"))
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"0@1/0", "0@1/0", "1@1/1", "1@1/1", "3@0"}
+		if diff := cmp.Diff(want, sortedStrings(log.GetValues())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("type", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		_, err := run(t, func(ctx context.Context) Node {
+			a := newSliceSource(ints(1)...).SetName("a").Out()
+			b := newSliceSource(values.New("x")).SetName("b").Out()
+			return Union(ctx, []*Arch{a, b}, WithUnionType(values.Int))
+		})
+		if err == nil {
+			t.Fatal("expected error, got none")
+		}
+	})
+
+	t.Run("watermark", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		// The fast source would close every window, if the watermark was not aligned to the slow one.
+		ctx := Context()
+		fast := newSliceSource(
+			values.SetTime(0, 0, values.New(1)),
+			values.SetTime(10, 10, values.New(2)),
+			values.SetTime(30, 30, values.New(5)),
+		).SetName("fast").Out()
+		slow := newSliceSource(
+			values.SetTime(1, 1, values.New(3)),
+			values.SetTime(20, 20, values.New(4)),
+			values.SetTime(30, 30, values.New(6)),
+		).SetName("slow").Out()
+		sink, log := NewLogSink(values.String)
+		Union(ctx, []*Arch{fast, slow}).Out().
+			Window(5, 5).
+			Aggregate(ctx, Sum()).Out().
+			Map(ctx, func(v values.Value) (values.Value, error) {
+				ts, _, _ := values.GetTime(v)
+				return values.New(fmt.Sprintf("%v@%d", undecorated(v), ts)), nil
+			}).Out().
+			Connect(ctx, sink)
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"2@14", "4@24", "4@4"}
+		if diff := cmp.Diff(want, sortedStrings(log.GetValues())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
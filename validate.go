@@ -0,0 +1,322 @@
+package ssp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ValidationError is a problem in the topology of a Graph, found by Validate.
+type ValidationError struct {
+	Node string
+	// Warning is true for problems that do not prevent execution, but are likely to be mistakes.
+	Warning bool
+	Msg     string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Warning {
+		return fmt.Sprintf("warning: node %q: %s", e.Node, e.Msg)
+	}
+	return fmt.Sprintf("node %q: %s", e.Node, e.Msg)
+}
+
+// statefulNode is implemented by Nodes that can tell if they hold state.
+type statefulNode interface {
+	isStateful() bool
+}
+
+// validator holds the topology of a Graph under validation.
+type validator struct {
+	nodes []Node
+	ins   map[Node][]*Arch
+	outs  map[Node][]*Arch
+	ts    map[Node]Timestamps
+
+	errs, warns Errors
+}
+
+// Validate checks the topology of g.
+// It returns the errors that prevent g from being executed, and the warnings about likely mistakes,
+// in a deterministic order.
+// Execute runs Validate, fails on errors, and passes warnings to the handler set WithWarnings, if any.
+//
+// Names identify nodes in errors, metrics and plans, so distinct nodes cannot share a name.
+// Unnamed nodes are not checked.
+// Node instances can be shared by Graphs, like the ones returned by Graph.Copy, because operators run clones of them.
+// Sources are the exception, as they run on their own instance: a source instance is reported if it is
+// running in another job, while Graphs sharing it can be executed one after the other.
+func Validate(g Graph) (errs, warnings Errors) {
+	v := newValidator(g)
+	v.checkNames()
+	v.checkSources()
+	for _, n := range v.nodes {
+		v.checkNode(n)
+	}
+	v.checkCycles()
+	return v.errs, v.warns
+}
+
+func newValidator(g Graph) *validator {
+	v := &validator{
+		ins:  make(map[Node][]*Arch),
+		outs: make(map[Node][]*Arch),
+		ts:   make(map[Node]Timestamps),
+	}
+	seen := make(map[Node]bool)
+	add := func(n Node) {
+		if !seen[n] {
+			seen[n] = true
+			v.nodes = append(v.nodes, n)
+		}
+	}
+	g.Walk(func(a *Arch) {
+		add(a.From())
+		add(a.To())
+		v.outs[a.From()] = append(v.outs[a.From()], a)
+		v.ins[a.To()] = append(v.ins[a.To()], a)
+	})
+	sort.Stable(nodesByRepr(v.nodes))
+	return v
+}
+
+func (v *validator) errorf(n Node, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Node: nodeToString(n), Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(n Node, format string, args ...interface{}) {
+	v.warns = append(v.warns, &ValidationError{Node: nodeToString(n), Warning: true, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) checkNode(n Node) {
+	if par := n.GetParallelism(); par <= 0 {
+		v.errorf(n, "parallelism must be positive, got %d", par)
+	}
+	if s, ok := n.(statefulNode); ok && s.isStateful() && n.GetParallelism() > 1 {
+		for _, a := range v.ins[n] {
+			if a.ks == nil {
+				v.warnf(n, "stateful node with parallelism %d is fed by %q without KeyBy: its state is split among instances at random",
+					n.GetParallelism(), nodeToString(a.From()))
+			}
+		}
+	}
+	if _, ok := n.(*windowedNode); ok {
+		for _, a := range v.ins[n] {
+			switch v.timestamps(a.From()) {
+			case NoTimestamps:
+				v.errorf(n, "window is fed by %q, that does not emit timestamped values", nodeToString(a.From()))
+			case UnknownTimestamps:
+				v.warnf(n, "window is fed by %q, that is not known to emit timestamped values: declare it with SetTimestamps", nodeToString(a.From()))
+			}
+		}
+	}
+	if GetErrorPolicy(n) == DeadLetterOnError && !v.hasTag(n, DeadLetterTag) {
+		v.errorf(n, "error policy is %v, but the dead-letter stream is not connected", DeadLetterOnError)
+	}
+	if len(v.outs[n]) == 0 && emits(n) {
+		v.warnf(n, "output is not connected: values are discarded")
+	}
+	if h, ok := n.(*iterationHead); ok {
+		v.checkIteration(h)
+	}
+}
+
+// checkNames reports names that are used by more than one node.
+func (v *validator) checkNames() {
+	byName := make(map[string][]Node)
+	for _, n := range v.nodes {
+		if name := n.GetName(); name != "" {
+			byName[name] = append(byName[name], n)
+		}
+	}
+	for _, n := range v.nodes {
+		if ns := byName[n.GetName()]; len(ns) > 1 && ns[0] == n {
+			v.errorf(n, "the name is used by %d nodes", len(ns))
+		}
+	}
+}
+
+// runningSources holds the source instances of the jobs being executed.
+var runningSources = struct {
+	mu sync.Mutex
+	ns map[Node]bool
+}{ns: make(map[Node]bool)}
+
+// sources returns the nodes without inputs.
+func (v *validator) sources() []Node {
+	var ns []Node
+	for _, n := range v.nodes {
+		if len(v.ins[n]) == 0 {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// checkSources reports source instances that are running in another job.
+func (v *validator) checkSources() {
+	runningSources.mu.Lock()
+	defer runningSources.mu.Unlock()
+	v.checkRunning()
+}
+
+// checkRunning is checkSources, with runningSources locked.
+func (v *validator) checkRunning() {
+	for _, n := range v.sources() {
+		if runningSources.ns[n] {
+			v.errorf(n, "the source instance is running in another job: sources run on their own instance, use a new one")
+		}
+	}
+}
+
+// acquireSources marks the sources of g as running, until release gets called.
+// It fails if any of them is already running in another job.
+func acquireSources(g Graph) (release func(), errs Errors) {
+	v := newValidator(g)
+	ns := v.sources()
+	runningSources.mu.Lock()
+	defer runningSources.mu.Unlock()
+	if v.checkRunning(); len(v.errs) > 0 {
+		return nil, v.errs
+	}
+	for _, n := range ns {
+		runningSources.ns[n] = true
+	}
+	return func() {
+		runningSources.mu.Lock()
+		defer runningSources.mu.Unlock()
+		for _, n := range ns {
+			delete(runningSources.ns, n)
+		}
+	}, nil
+}
+
+// hasTag returns true if the output of n tagged with tag is connected.
+func (v *validator) hasTag(n Node, tag string) bool {
+	for _, a := range v.outs[n] {
+		if a.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// emits returns true for nodes that are known to emit values.
+func emits(n Node) bool {
+	switch n.(type) {
+	case *unionNode, *iterationHead, *windowedNode:
+		return true
+	}
+	tn, ok := n.(TimestampedNode)
+	return ok && tn.GetTimestamps() != UnknownTimestamps
+}
+
+// timestamps infers whether n emits timestamped values.
+func (v *validator) timestamps(n Node) Timestamps {
+	if ts, ok := v.ts[n]; ok {
+		return ts
+	}
+	// Nodes in loops are unknown until proved otherwise.
+	v.ts[n] = UnknownTimestamps
+	tn, ok := n.(TimestampedNode)
+	if !ok {
+		return UnknownTimestamps
+	}
+	ts := tn.GetTimestamps()
+	if ts == KeepsTimestamps {
+		ts = UnknownTimestamps
+		for i, a := range v.ins[n] {
+			its := v.timestamps(a.From())
+			if its == UnknownTimestamps || (i > 0 && its != ts) {
+				ts = UnknownTimestamps
+				break
+			}
+			ts = its
+		}
+	}
+	v.ts[n] = ts
+	return ts
+}
+
+func (v *validator) checkIteration(h *iterationHead) {
+	if len(h.it.tails) == 0 {
+		v.warnf(h, "iteration has no feedback")
+	}
+	// The feedback must come from the loop body, i.e., from a node reachable from the head.
+	reachable := make(map[Node]bool)
+	var visit func(n Node)
+	visit = func(n Node) {
+		if reachable[n] {
+			return
+		}
+		reachable[n] = true
+		for _, a := range v.outs[n] {
+			visit(a.To())
+		}
+	}
+	for _, a := range v.outs[h] {
+		visit(a.To())
+	}
+	for _, t := range h.it.tails {
+		if !reachable[t.From()] {
+			v.errorf(h, "feedback from %q does not close a loop", nodeToString(t.From()))
+		}
+	}
+}
+
+// isFeedback returns true if a is the feedback of an Iteration.
+func isFeedback(a *Arch) bool {
+	h, ok := a.To().(*iterationHead)
+	return ok && h.it.isFeedback(a)
+}
+
+// checkCycles reports cycles that are not closed by the feedback of an Iteration.
+func (v *validator) checkCycles() {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[Node]int)
+	var path []Node
+	var visit func(n Node)
+	visit = func(n Node) {
+		state[n] = visiting
+		path = append(path, n)
+		for _, a := range v.outs[n] {
+			if isFeedback(a) {
+				continue
+			}
+			switch state[a.To()] {
+			case unvisited:
+				visit(a.To())
+			case visiting:
+				v.errorf(a.To(), "cycle not closed by the feedback of an Iteration: %s", cycle(path, a.To()))
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+	}
+	for _, n := range v.nodes {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+}
+
+// cycle formats the cycle in path that starts from n.
+func cycle(path []Node, n Node) string {
+	sb := strings.Builder{}
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == n {
+			for _, m := range path[i:] {
+				sb.WriteString(nodeToString(m))
+				sb.WriteString(" -> ")
+			}
+			break
+		}
+	}
+	sb.WriteString(nodeToString(n))
+	return sb.String()
+}
@@ -0,0 +1,257 @@
+package ssp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/affo/ssp/values"
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	nop := func(name string) *AnonymousNode {
+		n := NewNode(func(Collector, values.Value) error {
+			return nil
+		})
+		n.SetName(name)
+		return n
+	}
+	stateful := func(name string) *AnonymousNode {
+		n := NewStatefulNode(values.NewNull(values.Int64), func(s values.Value, _ Collector, _ values.Value) (values.Value, error) {
+			return s, nil
+		})
+		n.SetName(name)
+		return n
+	}
+	window := func(name string) Node {
+		return NewWindowedNode(1, 1, values.NewNull(values.Int64),
+			func(*Window, Collector, values.TimestampedValue) error { return nil },
+			func(*Window, Collector) error { return nil }).SetName(name)
+	}
+	toStrings := func(es Errors) []string {
+		ss := make([]string, len(es))
+		for i, e := range es {
+			ss[i] = e.Error()
+		}
+		return ss
+	}
+
+	for _, tc := range []struct {
+		name      string
+		build     func(ctx context.Context)
+		wantErrs  []string
+		wantWarns []string
+	}{
+		{
+			name: "valid",
+			build: func(ctx context.Context) {
+				nop("source").Out().Map(ctx, func(v values.Value) (values.Value, error) {
+					return v, nil
+				}).Out().KeyBy(NewFixedKeySelector()).Connect(ctx, stateful("sink").SetParallelism(2))
+			},
+			wantErrs:  []string{},
+			wantWarns: []string{},
+		},
+		{
+			name: "parallelism",
+			build: func(ctx context.Context) {
+				nop("source").Out().Connect(ctx, nop("sink").SetParallelism(0))
+			},
+			wantErrs:  []string{`node "sink": parallelism must be positive, got 0`},
+			wantWarns: []string{},
+		},
+		{
+			name: "not keyed",
+			build: func(ctx context.Context) {
+				nop("source").Out().Connect(ctx, stateful("sink").SetParallelism(2))
+			},
+			wantErrs: []string{},
+			wantWarns: []string{
+				`warning: node "sink": stateful node with parallelism 2 is fed by "source" without KeyBy: its state is split among instances at random`,
+			},
+		},
+		{
+			name: "window without timestamps",
+			build: func(ctx context.Context) {
+				source := nop("source").SetTimestamps(NoTimestamps)
+				source.Out().Filter(ctx, func(values.Value) bool {
+					return true
+				}).Out().Connect(ctx, window("window"))
+			},
+			wantErrs:  []string{`node "window": window is fed by "filter", that does not emit timestamped values`},
+			wantWarns: []string{`warning: node "window": output is not connected: values are discarded`},
+		},
+		{
+			name: "window with unknown timestamps",
+			build: func(ctx context.Context) {
+				nop("source").Out().Connect(ctx, window("window")).Out().
+					Sink(ctx, func(values.Value) error { return nil })
+			},
+			wantErrs: []string{},
+			wantWarns: []string{
+				`warning: node "window": window is fed by "source", that is not known to emit timestamped values: declare it with SetTimestamps`,
+			},
+		},
+		{
+			name: "window with timestamps",
+			build: func(ctx context.Context) {
+				nop("source").Out().
+					Connect(ctx, AssignTimestamp(func(values.Value) (values.Timestamp, values.Timestamp) {
+						return 0, 0
+					}).SetName("assign")).Out().
+					Window(1, 1).Aggregate(ctx, Count()).Out().
+					Sink(ctx, func(values.Value) error { return nil })
+			},
+			wantErrs:  []string{},
+			wantWarns: []string{},
+		},
+		{
+			name: "no sink",
+			build: func(ctx context.Context) {
+				nop("source").Out().Map(ctx, func(v values.Value) (values.Value, error) {
+					return v, nil
+				})
+			},
+			wantErrs:  []string{},
+			wantWarns: []string{`warning: node "map": output is not connected: values are discarded`},
+		},
+		{
+			name: "duplicate names",
+			build: func(ctx context.Context) {
+				source := nop("source")
+				source.Out().Connect(ctx, nop("sink"))
+				source.Out().Connect(ctx, nop("sink"))
+				source.Out().Connect(ctx, nop(""))
+				source.Out().Connect(ctx, nop(""))
+			},
+			wantErrs:  []string{`node "sink": the name is used by 2 nodes`},
+			wantWarns: []string{},
+		},
+		{
+			name: "cycle",
+			build: func(ctx context.Context) {
+				a, b := nop("a"), nop("b")
+				nop("source").Out().Connect(ctx, a)
+				a.Out().Connect(ctx, b)
+				b.Out().Connect(ctx, a)
+			},
+			wantErrs:  []string{`node "a": cycle not closed by the feedback of an Iteration: a -> b -> a`},
+			wantWarns: []string{},
+		},
+		{
+			name: "iteration",
+			build: func(ctx context.Context) {
+				it := Iterate(ctx, nop("source").Out())
+				body := it.Head().Out().Connect(ctx, nop("body"))
+				it.Feedback(ctx, body.Out())
+			},
+			wantErrs:  []string{},
+			wantWarns: []string{},
+		},
+		{
+			name: "feedback out of loop",
+			build: func(ctx context.Context) {
+				it := Iterate(ctx, nop("source").Out())
+				it.Head().Out().Connect(ctx, nop("body"))
+				it.Feedback(ctx, nop("other").Out())
+			},
+			wantErrs:  []string{`node "iteration": feedback from "other" does not close a loop`},
+			wantWarns: []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := Context()
+			tc.build(ctx)
+			errs, warns := Validate(GetGraph(ctx))
+			if diff := cmp.Diff(tc.wantErrs, toStrings(errs)); diff != "" {
+				t.Errorf("unexpected errors -want/+got:\n\t%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantWarns, toStrings(warns)); diff != "" {
+				t.Errorf("unexpected warnings -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+
+	t.Run("copied graph", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx := Context()
+		sink, log := NewLogSink(values.Int)
+		newSliceSource(ints(1, 2)...).Out().Connect(ctx, sink.SetName("sink"))
+		if err := Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// The copy shares its nodes with the original Graph.
+		if err := Execute(setGraph(context.Background(), GetGraph(ctx).Copy())); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"1", "1", "2", "2"}, sortedStrings(log.GetValues())); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("running source", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		started, stop := make(chan struct{}), make(chan struct{})
+		source := NewNode(func(collector Collector, v values.Value) error {
+			close(started)
+			<-stop
+			return nil
+		}).SetName("source")
+		ctx := Context()
+		source.Out().Sink(ctx, func(values.Value) error { return nil })
+		done := make(chan error)
+		go func() {
+			done <- Execute(ctx)
+		}()
+		<-started
+
+		// The copy shares the running source.
+		errs, ok := Execute(setGraph(context.Background(), GetGraph(ctx).Copy())).(Errors)
+		want := []string{`node "source": the source instance is running in another job: sources run on their own instance, use a new one`}
+		if !ok {
+			t.Errorf("expected validation errors, got %v", errs)
+		} else if diff := cmp.Diff(want, toStrings(errs)); diff != "" {
+			t.Errorf("unexpected errors -want/+got:\n\t%s", diff)
+		}
+		close(stop)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		// The source is not running anymore.
+		if errs, _ := Validate(GetGraph(ctx)); len(errs) > 0 {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+
+	t.Run("warnings", func(t *testing.T) {
+		defer leaktest.Check(t)()
+
+		ctx := Context()
+		nop("source").Out().Connect(ctx, nop("map").SetTimestamps(KeepsTimestamps))
+		var got Errors
+		if err := NewEngine(WithWarnings(func(warns Errors) {
+			got = warns
+		})).Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{`warning: node "map": output is not connected: values are discarded`}
+		if diff := cmp.Diff(want, toStrings(got)); diff != "" {
+			t.Errorf("unexpected warnings -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("execute", func(t *testing.T) {
+		ctx := Context()
+		nop("source").Out().Connect(ctx, nop("sink").SetParallelism(-1))
+		errs, ok := Execute(ctx).(Errors)
+		if !ok || len(errs) != 1 {
+			t.Fatalf("expected one validation error, got %v", errs)
+		}
+		if _, ok := errs[0].(*ValidationError); !ok {
+			t.Errorf("expected validation error, got %v", errs[0])
+		}
+	})
+}
@@ -0,0 +1,823 @@
+package values
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// CodecVersion is the version of the binary format produced by Encoder.
+// It must be bumped on every incompatible change to the format.
+const CodecVersion byte = 1
+
+// Identifiers of the kind of value encoded.
+const (
+	kindPrimitive byte = iota + 1
+	kindNull
+	kindObject
+	kindList
+	kindMeta
+	kindKeyed
+	kindWithSource
+	kindTimestamped
+	kindRecord
+	kindMap
+	kindSet
+	kindWithOrigin
+)
+
+// typeIDs are the wire identifiers of types.
+// As opposed to Type values, they never change when adding new types.
+var typeIDs = map[Type]byte{
+	Object:  1,
+	Int:     2,
+	Int8:    3,
+	Int16:   4,
+	Int32:   5,
+	Int64:   6,
+	Uint8:   7,
+	Uint16:  8,
+	Uint32:  9,
+	Uint64:  10,
+	Float32: 11,
+	Float64: 12,
+	String:  13,
+	Bool:    14,
+	Close:   15,
+
+	RecordType: 16,
+	ListType:   17,
+	MapType:    18,
+	SetType:    19,
+
+	Time:     20,
+	Duration: 21,
+	Bytes:    22,
+	Decimal:  23,
+}
+
+var idTypes = func() map[byte]Type {
+	m := make(map[byte]Type, len(typeIDs))
+	for t, id := range typeIDs {
+		m[id] = t
+	}
+	return m
+}()
+
+// ObjectCodec encodes and decodes Go objects wrapped in values of type Object.
+type ObjectCodec interface {
+	Encode(o interface{}) ([]byte, error)
+	Decode(b []byte) (interface{}, error)
+}
+
+type gobObjectCodec struct {
+	t reflect.Type
+}
+
+// NewGobObjectCodec returns an ObjectCodec that uses encoding/gob for objects of the same type of sample.
+func NewGobObjectCodec(sample interface{}) ObjectCodec {
+	return gobObjectCodec{t: reflect.TypeOf(sample)}
+}
+
+func (c gobObjectCodec) Encode(o interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gobObjectCodec) Decode(b []byte) (interface{}, error) {
+	o := reflect.New(c.t)
+	if err := gob.NewDecoder(bytes.NewReader(b)).DecodeValue(o); err != nil {
+		return nil, err
+	}
+	return o.Elem().Interface(), nil
+}
+
+type registeredObject struct {
+	name  string
+	codec ObjectCodec
+}
+
+var objectRegistry = struct {
+	sync.RWMutex
+	byType map[reflect.Type]registeredObject
+	byName map[string]registeredObject
+}{
+	byType: make(map[reflect.Type]registeredObject),
+	byName: make(map[string]registeredObject),
+}
+
+// RegisterObject registers a codec for objects of the same type of sample.
+// The name identifies the type on the wire, so it must be the same for encoders and decoders.
+func RegisterObject(name string, sample interface{}, c ObjectCodec) {
+	objectRegistry.Lock()
+	defer objectRegistry.Unlock()
+	t := reflect.TypeOf(sample)
+	if _, ok := objectRegistry.byName[name]; ok {
+		panic(fmt.Sprintf("object type already registered with name %q", name))
+	}
+	ro := registeredObject{name: name, codec: c}
+	objectRegistry.byType[t] = ro
+	objectRegistry.byName[name] = ro
+}
+
+func lookupObjectByType(o interface{}) (registeredObject, error) {
+	objectRegistry.RLock()
+	defer objectRegistry.RUnlock()
+	ro, ok := objectRegistry.byType[reflect.TypeOf(o)]
+	if !ok {
+		return ro, fmt.Errorf("no codec registered for object of type %T", o)
+	}
+	return ro, nil
+}
+
+func lookupObjectByName(name string) (registeredObject, error) {
+	objectRegistry.RLock()
+	defer objectRegistry.RUnlock()
+	ro, ok := objectRegistry.byName[name]
+	if !ok {
+		return ro, fmt.Errorf("no codec registered for object with name %q", name)
+	}
+	return ro, nil
+}
+
+// Encoder writes values in binary format to a stream.
+// The first value is preceded by the codec version.
+type Encoder struct {
+	w      io.Writer
+	header bool
+	buf    []byte
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+func (e *Encoder) Encode(v Value) error {
+	buf := e.buf[:0]
+	if !e.header {
+		buf = append(buf, CodecVersion)
+	}
+	buf, err := appendValue(buf, v)
+	if err != nil {
+		return err
+	}
+	e.buf = buf
+	if _, err := e.w.Write(buf); err != nil {
+		return err
+	}
+	e.header = true
+	return nil
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Decoder reads values in binary format from a stream.
+// If the underlying reader does not implement io.ByteReader, the Decoder may read more data than needed from it.
+type Decoder struct {
+	r      byteReader
+	header bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{r: br}
+}
+
+// Decode returns the next value in the stream, or io.EOF at its end.
+func (d *Decoder) Decode() (Value, error) {
+	if !d.header {
+		version, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if version != CodecVersion {
+			return nil, fmt.Errorf("unsupported codec version %d, expected %d", version, CodecVersion)
+		}
+		d.header = true
+	}
+	return readValue(d.r)
+}
+
+// Marshal encodes a single value, codec version included.
+func Marshal(v Value) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a single value encoded with Marshal.
+func Unmarshal(b []byte) (Value, error) {
+	r := bytes.NewReader(b)
+	v, err := NewDecoder(r).Decode()
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d unexpected trailing bytes", r.Len())
+	}
+	return v, nil
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, x int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendType(buf []byte, t Type) ([]byte, error) {
+	id, ok := typeIDs[t]
+	if !ok {
+		return nil, fmt.Errorf("cannot encode type %d", t)
+	}
+	return append(buf, id), nil
+}
+
+const (
+	fieldRepeated byte = 1 << iota
+	fieldNullable
+)
+
+func appendSchema(buf []byte, s *Schema) ([]byte, error) {
+	var err error
+	buf = appendUvarint(buf, uint64(len(s.fields)))
+	for _, f := range s.fields {
+		buf = appendBytes(buf, []byte(f.Name))
+		if buf, err = appendType(buf, f.Type); err != nil {
+			return nil, err
+		}
+		var flags byte
+		if f.Repeated {
+			flags |= fieldRepeated
+		}
+		if f.Nullable {
+			flags |= fieldNullable
+		}
+		buf = append(buf, flags)
+		if f.Type == RecordType {
+			if buf, err = appendSchema(buf, f.Schema); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf, nil
+}
+
+func appendValue(buf []byte, v Value) ([]byte, error) {
+	var err error
+	switch v := v.(type) {
+	case *keyedValue:
+		buf = append(buf, kindKeyed)
+		buf = appendUvarint(buf, uint64(v.k))
+		return appendValue(buf, v.Value)
+	case *valueWithSource:
+		buf = append(buf, kindWithSource)
+		buf = appendVarint(buf, int64(v.s))
+		return appendValue(buf, v.Value)
+	case *valueWithOrigin:
+		buf = append(buf, kindWithOrigin)
+		buf = appendVarint(buf, int64(v.o))
+		return appendValue(buf, v.Value)
+	case *timestampedValue:
+		buf = append(buf, kindTimestamped)
+		buf = appendVarint(buf, int64(v.ts))
+		buf = appendVarint(buf, int64(v.wm))
+		return appendValue(buf, v.Value)
+	case meta:
+		buf = append(buf, kindMeta)
+		return appendType(buf, v.t)
+	case nullValue:
+		buf = append(buf, kindNull)
+		return appendType(buf, v.t)
+	case *List:
+		buf = append(buf, kindList)
+		if buf, err = appendType(buf, v.t); err != nil {
+			return nil, err
+		}
+		buf = appendUvarint(buf, uint64(len(v.vs)))
+		for _, e := range v.vs {
+			if buf, err = appendValue(buf, e); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case *Map:
+		buf = append(buf, kindMap)
+		if buf, err = appendType(buf, v.kt); err != nil {
+			return nil, err
+		}
+		if buf, err = appendType(buf, v.vt); err != nil {
+			return nil, err
+		}
+		buf = appendUvarint(buf, uint64(v.Len()))
+		for i, k := range v.es.ks {
+			if buf, err = appendValue(buf, k); err != nil {
+				return nil, err
+			}
+			if buf, err = appendValue(buf, v.es.vs[i]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case *Set:
+		buf = append(buf, kindSet)
+		if buf, err = appendType(buf, v.t); err != nil {
+			return nil, err
+		}
+		buf = appendUvarint(buf, uint64(v.Len()))
+		for _, e := range v.es.ks {
+			if buf, err = appendValue(buf, e); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case *Record:
+		buf = append(buf, kindRecord)
+		if buf, err = appendSchema(buf, v.s); err != nil {
+			return nil, err
+		}
+		for _, fv := range v.vs {
+			if buf, err = appendValue(buf, fv); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case goObjectValue:
+		ro, err := lookupObjectByType(v.v)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ro.codec.Encode(v.v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode object %q: %w", ro.name, err)
+		}
+		buf = append(buf, kindObject)
+		buf = appendBytes(buf, []byte(ro.name))
+		return appendBytes(buf, b), nil
+	}
+
+	buf = append(buf, kindPrimitive)
+	if buf, err = appendType(buf, v.Type()); err != nil {
+		return nil, err
+	}
+	switch v := v.Get().(type) {
+	case int:
+		return appendVarint(buf, int64(v)), nil
+	case int8:
+		return appendVarint(buf, int64(v)), nil
+	case int16:
+		return appendVarint(buf, int64(v)), nil
+	case int32:
+		return appendVarint(buf, int64(v)), nil
+	case int64:
+		return appendVarint(buf, v), nil
+	case uint8:
+		return appendUvarint(buf, uint64(v)), nil
+	case uint16:
+		return appendUvarint(buf, uint64(v)), nil
+	case uint32:
+		return appendUvarint(buf, uint64(v)), nil
+	case uint64:
+		return appendUvarint(buf, v), nil
+	case float32:
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(v))
+		return append(buf, tmp[:]...), nil
+	case float64:
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+		return append(buf, tmp[:]...), nil
+	case string:
+		return appendBytes(buf, []byte(v)), nil
+	case bool:
+		if v {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case time.Time:
+		b, err := v.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(buf, b), nil
+	case time.Duration:
+		return appendVarint(buf, int64(v)), nil
+	case []byte:
+		return appendBytes(buf, v), nil
+	case Dec:
+		buf = appendVarint(buf, int64(v.Scale()))
+		u := v.unscaled()
+		if u.Sign() < 0 {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		return appendBytes(buf, u.Bytes()), nil
+	default:
+		return nil, fmt.Errorf("cannot encode value of type %T", v)
+	}
+}
+
+func readType(r byteReader) (Type, error) {
+	id, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	t, ok := idTypes[id]
+	if !ok {
+		return 0, fmt.Errorf("unknown type identifier %d", id)
+	}
+	return t, nil
+}
+
+// maxLength bounds the length prefixes in the input.
+const maxLength = 1 << 30
+
+// readLength reads a length prefix, and checks it against the bytes left in r, if known,
+// so that corrupt input does not cause huge allocations.
+func readLength(r byteReader) (int, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > maxLength {
+		return 0, fmt.Errorf("length %d exceeds the maximum of %d", n, maxLength)
+	}
+	if l, ok := r.(interface{ Len() int }); ok && n > uint64(l.Len()) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return int(n), nil
+}
+
+func readBytes(r byteReader) ([]byte, error) {
+	n, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	// The buffer grows as bytes are read, in case the length is corrupt and r is a stream.
+	buf := &bytes.Buffer{}
+	if _, err := io.CopyN(buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readSchema(r byteReader) (*Schema, error) {
+	n, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	var fs []Field
+	for i := 0; i < n; i++ {
+		name, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		t, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		flags, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		f := Field{
+			Name:     string(name),
+			Type:     t,
+			Repeated: flags&fieldRepeated != 0,
+			Nullable: flags&fieldNullable != 0,
+		}
+		if t == RecordType {
+			if f.Schema, err = readSchema(r); err != nil {
+				return nil, err
+			}
+		}
+		fs = append(fs, f)
+	}
+	return NewSchema(fs...)
+}
+
+// noEOF converts io.EOF into io.ErrUnexpectedEOF, as EOF is expected only between values.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func readValue(r byteReader) (Value, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	v, err := readKind(kind, r)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	return v, nil
+}
+
+func readKind(kind byte, r byteReader) (Value, error) {
+	switch kind {
+	case kindKeyed:
+		k, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		return &keyedValue{k: Key(k), Value: v}, nil
+	case kindWithSource:
+		s, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		return &valueWithSource{s: Source(s), Value: v}, nil
+	case kindWithOrigin:
+		o, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		return &valueWithOrigin{o: Origin(o), Value: v}, nil
+	case kindTimestamped:
+		ts, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		wm, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		return &timestampedValue{ts: Timestamp(ts), wm: Timestamp(wm), Value: v}, nil
+	case kindMeta:
+		t, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewMeta(t), nil
+	case kindNull:
+		t, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewNull(t), nil
+	case kindList:
+		t, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		l := NewList(t)
+		for i := uint64(0); i < n; i++ {
+			v, err := readValue(r)
+			if err != nil {
+				return nil, err
+			}
+			if err := l.AddValue(v); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case kindMap:
+		kt, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		vt, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		m := NewMap(kt, vt)
+		for i := uint64(0); i < n; i++ {
+			k, err := readValue(r)
+			if err != nil {
+				return nil, err
+			}
+			v, err := readValue(r)
+			if err != nil {
+				return nil, err
+			}
+			if err := m.Put(k, v); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case kindSet:
+		t, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		s := NewSet(t)
+		for i := uint64(0); i < n; i++ {
+			v, err := readValue(r)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.AddValue(v); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case kindRecord:
+		s, err := readSchema(r)
+		if err != nil {
+			return nil, err
+		}
+		vs := make([]Value, s.NumFields())
+		for i := range vs {
+			if vs[i], err = readValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return NewRecord(s, vs...)
+	case kindObject:
+		name, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		b, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		ro, err := lookupObjectByName(string(name))
+		if err != nil {
+			return nil, err
+		}
+		o, err := ro.codec.Decode(b)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode object %q: %w", ro.name, err)
+		}
+		return New(o), nil
+	case kindPrimitive:
+		t, err := readType(r)
+		if err != nil {
+			return nil, err
+		}
+		return readPrimitive(t, r)
+	default:
+		return nil, fmt.Errorf("unknown value kind %d", kind)
+	}
+}
+
+func readPrimitive(t Type, r byteReader) (Value, error) {
+	switch t {
+	case Int, Int8, Int16, Int32, Int64:
+		x, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case Int:
+			return New(int(x)), nil
+		case Int8:
+			return New(int8(x)), nil
+		case Int16:
+			return New(int16(x)), nil
+		case Int32:
+			return New(int32(x)), nil
+		default:
+			return New(x), nil
+		}
+	case Uint8, Uint16, Uint32, Uint64:
+		x, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case Uint8:
+			return New(uint8(x)), nil
+		case Uint16:
+			return New(uint16(x)), nil
+		case Uint32:
+			return New(uint32(x)), nil
+		default:
+			return New(x), nil
+		}
+	case Float32:
+		var tmp [4]byte
+		if _, err := io.ReadFull(r, tmp[:]); err != nil {
+			return nil, err
+		}
+		return New(math.Float32frombits(binary.LittleEndian.Uint32(tmp[:]))), nil
+	case Float64:
+		var tmp [8]byte
+		if _, err := io.ReadFull(r, tmp[:]); err != nil {
+			return nil, err
+		}
+		return New(math.Float64frombits(binary.LittleEndian.Uint64(tmp[:]))), nil
+	case String:
+		b, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		return New(string(b)), nil
+	case Bool:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		return New(b != 0), nil
+	case Time:
+		b, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		var ts time.Time
+		if err := ts.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return New(ts), nil
+	case Duration:
+		x, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		return New(time.Duration(x)), nil
+	case Bytes:
+		b, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		return New(b), nil
+	case Decimal:
+		scale, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if scale < 0 || scale > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid decimal scale %d", scale)
+		}
+		neg, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		b, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		u := new(big.Int).SetBytes(b)
+		if neg != 0 {
+			u.Neg(u)
+		}
+		return New(Dec{u: u, scale: int32(scale)}), nil
+	default:
+		return nil, fmt.Errorf("type %v is not primitive", t)
+	}
+}
@@ -0,0 +1,278 @@
+package values
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// describe returns a representation of the whole decorator chain of v.
+func describe(v Value) string {
+	sb := strings.Builder{}
+	for {
+		switch dv := v.(type) {
+		case *keyedValue:
+			sb.WriteString(fmt.Sprintf("key(%d) ", dv.k))
+		case *valueWithSource:
+			sb.WriteString(fmt.Sprintf("source(%d) ", dv.s))
+		case *valueWithOrigin:
+			sb.WriteString(fmt.Sprintf("origin(%d) ", dv.o))
+		case *timestampedValue:
+			sb.WriteString(fmt.Sprintf("time(%d, %d) ", dv.ts, dv.wm))
+		case *List:
+			sb.WriteString(fmt.Sprintf("list<%v>[", dv.t))
+			for i, e := range dv.vs {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(describe(e))
+			}
+			sb.WriteString("]")
+			return sb.String()
+		case *Map:
+			sb.WriteString(fmt.Sprintf("map<%v, %v>[", dv.kt, dv.vt))
+			for i, e := range dv.Entries() {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(describe(e.Key) + ": " + describe(e.Value))
+			}
+			sb.WriteString("]")
+			return sb.String()
+		case *Set:
+			sb.WriteString(fmt.Sprintf("set<%v>[", dv.t))
+			for i, e := range dv.GetValues() {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(describe(e))
+			}
+			sb.WriteString("]")
+			return sb.String()
+		case *Record:
+			sb.WriteString(fmt.Sprintf("%v{", dv.s))
+			for i, e := range dv.vs {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(describe(e))
+			}
+			sb.WriteString("}")
+			return sb.String()
+		case meta:
+			sb.WriteString(fmt.Sprintf("meta(%d)", int(dv.t)))
+			return sb.String()
+		default:
+			if v.IsNull() && v.Type() != Object {
+				sb.WriteString(fmt.Sprintf("null(%v)", v.Type()))
+				return sb.String()
+			}
+			if s, ok := v.Get().(fmt.Stringer); ok {
+				sb.WriteString(fmt.Sprintf("%T(%s)", v.Get(), s))
+				return sb.String()
+			}
+			sb.WriteString(fmt.Sprintf("%T(%#v)", v.Get(), v.Get()))
+			return sb.String()
+		}
+		v, _ = v.Unwrap()
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func init() {
+	RegisterObject("values.point", point{}, NewGobObjectCodec(point{}))
+}
+
+func TestCodec(t *testing.T) {
+	list := NewList(Int64)
+	_ = list.AddValue(New(int64(1)))
+	_ = list.AddValue(SetKey(2, New(int64(2))))
+	m := NewMap(String, ListType)
+	_ = m.Put(New("a"), list)
+	_ = m.Put(New("b"), NewList(Int64))
+	set := NewSet(Bool)
+	_ = set.AddValue(New(true))
+	_ = set.AddValue(New(false))
+	for _, v := range []Value{
+		New(-42),
+		New(int8(-8)),
+		New(int16(16)),
+		New(int32(-32)),
+		New(int64(1) << 62),
+		New(uint8(8)),
+		New(uint16(16)),
+		New(uint32(32)),
+		New(uint64(1) << 63),
+		New(float32(3.14)),
+		New(2.71828),
+		New(""),
+		New("hello, ssp"),
+		New(true),
+		New(false),
+		New(time.Date(2020, 5, 17, 10, 30, 0, 42, time.FixedZone("", 2*60*60))),
+		New(-90 * time.Minute),
+		New([]byte{0, 1, 255}),
+		New(MustParseDecimal("-12345678901234567890.0100")),
+		New(NewDecimal(0, 2)),
+		NewNull(String),
+		NewNull(Float64),
+		NewMeta(Close),
+		New(point{X: 1, Y: -1}),
+		list,
+		m,
+		set,
+		SetKey(3, SetSource(1, SetTime(10, 5, New("decorated")))),
+		SetTime(10, 5, SetSource(1, SetKey(3, NewNull(Int)))),
+		SetSource(0, SetOrigin(2, SetTime(10, 5, New("merged")))),
+	} {
+		want := describe(v)
+		t.Run(want, func(t *testing.T) {
+			b, err := Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != CodecVersion {
+				t.Errorf("expected codec version as first byte, got %d", b[0])
+			}
+			got, err := Unmarshal(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, describe(got)); diff != "" {
+				t.Errorf("unexpected value -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+}
+
+func TestCodec_Stream(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	for i := 0; i < 10; i++ {
+		if err := enc.Encode(SetTime(Timestamp(i), Timestamp(i-1), New(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(buf)
+	for i := 0; i < 10; i++ {
+		v, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts, wm, _ := GetTime(v)
+		if v.Int() != i || ts != Timestamp(i) || wm != Timestamp(i-1) {
+			t.Errorf("unexpected value: %s", describe(v))
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected EOF, got: %v", err)
+	}
+}
+
+func TestCodec_Errors(t *testing.T) {
+	type unregistered struct{}
+	if _, err := Marshal(New(unregistered{})); err == nil {
+		t.Error("expected error for unregistered object, got none")
+	}
+
+	b, err := Marshal(New("truncated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unmarshal(b[:len(b)-2]); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF, got: %v", err)
+	}
+
+	b[0] = CodecVersion + 1
+	if _, err := Unmarshal(b); err == nil {
+		t.Error("expected error for wrong version, got none")
+	}
+}
+
+func TestCodec_Corrupt(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		b    []byte
+	}{
+		{
+			name: "huge string length",
+			b:    []byte{CodecVersion, 1, 13, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		},
+		{
+			name: "string length beyond input",
+			b:    []byte{CodecVersion, 1, 13, 0xff, 0xff, 0xff, 0x03, 'a'},
+		},
+		{
+			name: "schema length beyond input",
+			b:    append([]byte{CodecVersion}, corruptRecord()...),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Unmarshal(tc.b); err == nil {
+				t.Error("expected error got none")
+			}
+			// Streams do not know how many bytes are left.
+			if _, err := NewDecoder(hideLen(tc.b)).Decode(); err == nil {
+				t.Error("expected error got none")
+			}
+		})
+	}
+}
+
+// hideLen hides the length of b to the Decoder.
+func hideLen(b []byte) io.Reader {
+	return io.MultiReader(bytes.NewReader(b))
+}
+
+// corruptRecord returns an encoded record whose schema declares a huge number of fields.
+func corruptRecord() []byte {
+	s, err := NewSchema(Field{Name: "a", Type: Int})
+	if err != nil {
+		panic(err)
+	}
+	r, err := NewRecord(s, New(1))
+	if err != nil {
+		panic(err)
+	}
+	b, err := Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	// Skip the version and the kind, and replace the number of fields.
+	return append([]byte{b[1], 0x80, 0x80, 0x40}, b[3:]...)
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, v := range []Value{
+		New(42),
+		New("hello"),
+		New([]byte{0, 1}),
+		SetKey(3, SetTime(10, 5, New("decorated"))),
+	} {
+		b, err := Marshal(v)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Add(append([]byte{CodecVersion}, corruptRecord()...))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		v, err := Unmarshal(b)
+		if err != nil {
+			return
+		}
+		// Values that decode must encode again.
+		if _, err := Marshal(v); err != nil {
+			t.Errorf("cannot encode decoded value %s: %v", describe(v), err)
+		}
+	})
+}
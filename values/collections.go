@@ -0,0 +1,268 @@
+package values
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	_ Value = (*Map)(nil)
+	_ Value = (*Set)(nil)
+)
+
+// entries is an insertion-ordered hash table of values.
+// Values are compared using Equal and hashed using Hash, so that
+// composite values can be used as keys.
+type entries struct {
+	ks    []Value
+	vs    []Value
+	index map[uint64][]int
+}
+
+func newEntries() entries {
+	return entries{index: make(map[uint64][]int)}
+}
+
+func (e *entries) find(k Value) (int, uint64) {
+	h := Hash(k)
+	for _, i := range e.index[h] {
+		if Equal(e.ks[i], k) {
+			return i, h
+		}
+	}
+	return -1, h
+}
+
+// put inserts or replaces the entry for k, and returns true if k was not present.
+func (e *entries) put(k, v Value) bool {
+	i, h := e.find(k)
+	if i >= 0 {
+		if e.vs != nil {
+			e.vs[i] = v
+		}
+		return false
+	}
+	e.index[h] = append(e.index[h], len(e.ks))
+	e.ks = append(e.ks, k)
+	if v != nil {
+		e.vs = append(e.vs, v)
+	}
+	return true
+}
+
+func (e *entries) remove(k Value) bool {
+	i, _ := e.find(k)
+	if i < 0 {
+		return false
+	}
+	e.ks = append(e.ks[:i], e.ks[i+1:]...)
+	if e.vs != nil {
+		e.vs = append(e.vs[:i], e.vs[i+1:]...)
+	}
+	// Positions changed, rebuild the index.
+	e.index = make(map[uint64][]int, len(e.ks))
+	for j, k := range e.ks {
+		h := Hash(k)
+		e.index[h] = append(e.index[h], j)
+	}
+	return true
+}
+
+func (e *entries) clone() entries {
+	c := newEntries()
+	for i, k := range e.ks {
+		var v Value
+		if e.vs != nil {
+			v = e.vs[i].Clone()
+		}
+		c.put(k.Clone(), v)
+	}
+	return c
+}
+
+// MapEntry is a key-value pair in a Map.
+type MapEntry struct {
+	Key   Value
+	Value Value
+}
+
+// Map is a value that associates keys to values.
+// Keys can be any value, including composite ones, and are compared by value.
+// Iteration follows insertion order.
+type Map struct {
+	nonPrimitive
+	kt, vt Type
+	es     entries
+}
+
+func NewMap(kt, vt Type) *Map {
+	return &Map{
+		kt: kt,
+		vt: vt,
+		es: newEntries(),
+	}
+}
+
+func (m *Map) Type() Type {
+	return MapType
+}
+
+// KeyType returns the type of the keys of the map.
+func (m *Map) KeyType() Type {
+	return m.kt
+}
+
+// ElemType returns the type of the values of the map.
+func (m *Map) ElemType() Type {
+	return m.vt
+}
+
+// Get returns the entries of the map.
+func (m *Map) Get() interface{} {
+	return m.Entries()
+}
+
+func (m *Map) Entries() []MapEntry {
+	es := make([]MapEntry, len(m.es.ks))
+	for i, k := range m.es.ks {
+		es[i] = MapEntry{Key: k, Value: m.es.vs[i]}
+	}
+	return es
+}
+
+func (m *Map) Len() int {
+	return len(m.es.ks)
+}
+
+// Put associates v to k.
+func (m *Map) Put(k, v Value) error {
+	if k.Type() != m.kt {
+		return fmt.Errorf("unexpected key type, want %v, got %v", m.kt, k.Type())
+	}
+	if v.Type() != m.vt {
+		return fmt.Errorf("unexpected value type, want %v, got %v", m.vt, v.Type())
+	}
+	m.es.put(k, v)
+	return nil
+}
+
+// Lookup returns the value associated to k, if any.
+func (m *Map) Lookup(k Value) (Value, bool) {
+	i, _ := m.es.find(k)
+	if i < 0 {
+		return nil, false
+	}
+	return m.es.vs[i], true
+}
+
+// Delete removes k from the map, and returns true if it was present.
+func (m *Map) Delete(k Value) bool {
+	return m.es.remove(k)
+}
+
+func (m *Map) IsNull() bool {
+	return false
+}
+
+func (m *Map) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("map cannot be unwrapped")
+}
+
+func (m *Map) Clone() Value {
+	return &Map{
+		kt: m.kt,
+		vt: m.vt,
+		es: m.es.clone(),
+	}
+}
+
+func (m *Map) String() string {
+	ss := make([]string, len(m.es.ks))
+	for i, k := range m.es.ks {
+		ss[i] = fmt.Sprintf("%v: %v", k, m.es.vs[i])
+	}
+	return fmt.Sprintf("map[%s]", strings.Join(ss, ", "))
+}
+
+// Set is a value that holds distinct values.
+// Values can be composite, and are compared by value.
+// Iteration follows insertion order.
+type Set struct {
+	nonPrimitive
+	t  Type
+	es entries
+}
+
+func NewSet(t Type) *Set {
+	return &Set{
+		t:  t,
+		es: newEntries(),
+	}
+}
+
+func (s *Set) Type() Type {
+	return SetType
+}
+
+// ElemType returns the type of the elements of the set.
+func (s *Set) ElemType() Type {
+	return s.t
+}
+
+// Get returns the elements of the set.
+func (s *Set) Get() interface{} {
+	return s.GetValues()
+}
+
+// GetValues returns a copy of the elements of the set.
+func (s *Set) GetValues() []Value {
+	vs := make([]Value, len(s.es.ks))
+	copy(vs, s.es.ks)
+	return vs
+}
+
+func (s *Set) Len() int {
+	return len(s.es.ks)
+}
+
+// AddValue adds v to the set, if not present.
+func (s *Set) AddValue(v Value) error {
+	if v.Type() != s.t {
+		return fmt.Errorf("unexpected type, want %v, got %v", s.t, v.Type())
+	}
+	s.es.put(v, nil)
+	return nil
+}
+
+func (s *Set) Contains(v Value) bool {
+	i, _ := s.es.find(v)
+	return i >= 0
+}
+
+// Remove removes v from the set, and returns true if it was present.
+func (s *Set) Remove(v Value) bool {
+	return s.es.remove(v)
+}
+
+func (s *Set) IsNull() bool {
+	return false
+}
+
+func (s *Set) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("set cannot be unwrapped")
+}
+
+func (s *Set) Clone() Value {
+	return &Set{
+		t:  s.t,
+		es: s.es.clone(),
+	}
+}
+
+func (s *Set) String() string {
+	ss := make([]string, len(s.es.ks))
+	for i, v := range s.es.ks {
+		ss[i] = v.String()
+	}
+	return fmt.Sprintf("set[%s]", strings.Join(ss, ", "))
+}
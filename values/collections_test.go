@@ -0,0 +1,165 @@
+package values
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newIntList(is ...int) *List {
+	l := NewList(Int)
+	for _, i := range is {
+		_ = l.AddValue(New(i))
+	}
+	return l
+}
+
+func TestMap(t *testing.T) {
+	m := NewMap(ListType, String)
+	if err := m.Put(newIntList(1, 2), New("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put(newIntList(3), New("b")); err != nil {
+		t.Fatal(err)
+	}
+	// Overwrite using an equal, but different, key.
+	if err := m.Put(newIntList(1, 2), New("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put(New(1), New("d")); err == nil {
+		t.Error("expected error for wrong key type, got none")
+	}
+	if m.Len() != 2 {
+		t.Fatalf("unexpected length: %d", m.Len())
+	}
+	if v, ok := m.Lookup(newIntList(1, 2)); !ok || v.String() != "c" {
+		t.Errorf("unexpected lookup result: %v, %v", v, ok)
+	}
+	if diff := cmp.Diff("map[[1 2]: c, [3]: b]", m.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+
+	c := m.Clone().(*Map)
+	if !m.Delete(newIntList(1, 2)) {
+		t.Error("expected key to be deleted")
+	}
+	if _, ok := m.Lookup(newIntList(1, 2)); ok {
+		t.Error("expected key to be missing")
+	}
+	if v, ok := m.Lookup(newIntList(3)); !ok || v.String() != "b" {
+		t.Errorf("unexpected lookup result after delete: %v, %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("clone should not be affected by delete, got length %d", c.Len())
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet(String)
+	for _, v := range []string{"a", "b", "a", "c", "b"} {
+		if err := s.AddValue(New(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff("set[a, b, c]", s.String()); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if !s.Contains(New("b")) || s.Contains(New("d")) {
+		t.Errorf("unexpected membership in %v", s)
+	}
+	if !s.Remove(New("a")) || s.Remove(New("a")) {
+		t.Error("unexpected remove result")
+	}
+	if s.Len() != 2 {
+		t.Errorf("unexpected length: %d", s.Len())
+	}
+	// Changing the returned values does not change the set.
+	s.GetValues()[0] = New("z")
+	if s.Contains(New("z")) || !s.Contains(New("b")) {
+		t.Errorf("set changed through its values: %v", s)
+	}
+}
+
+func TestEqualHash(t *testing.T) {
+	s1 := NewSet(ListType)
+	_ = s1.AddValue(newIntList(1))
+	_ = s1.AddValue(newIntList(2, 3))
+	s2 := NewSet(ListType)
+	_ = s2.AddValue(newIntList(2, 3))
+	_ = s2.AddValue(newIntList(1))
+
+	m1 := NewMap(String, SetType)
+	_ = m1.Put(New("x"), s1)
+	_ = m1.Put(New("y"), NewSet(ListType))
+	m2 := NewMap(String, SetType)
+	_ = m2.Put(New("y"), NewSet(ListType))
+	_ = m2.Put(New("x"), s2)
+
+	for _, tc := range []struct {
+		name  string
+		a, b  Value
+		equal bool
+	}{
+		{name: "primitives", a: New(1), b: New(1), equal: true},
+		{name: "different primitives", a: New(1), b: New(2)},
+		{name: "different types", a: New(1), b: New(int64(1))},
+		{name: "decorated", a: SetKey(1, SetTime(1, 0, New("a"))), b: New("a"), equal: true},
+		{name: "nulls", a: NewNull(Int), b: NewNull(Int), equal: true},
+		{name: "lists", a: newIntList(1, 2), b: newIntList(1, 2), equal: true},
+		{name: "list order", a: newIntList(1, 2), b: newIntList(2, 1)},
+		{name: "nested sets", a: s1, b: s2, equal: true},
+		{name: "nested maps", a: m1, b: m2, equal: true},
+		{name: "different maps", a: m1, b: NewMap(String, SetType)},
+		{name: "times", a: New(time.Unix(10, 0)), b: New(time.Unix(10, 0).In(time.FixedZone("X", 3600))), equal: true},
+		{name: "bytes", a: New([]byte("abc")), b: New([]byte("abc")), equal: true},
+		{name: "decimals", a: New(MustParseDecimal("1.50")), b: New(MustParseDecimal("1.5")), equal: true},
+		{name: "different decimals", a: New(MustParseDecimal("1.50")), b: New(MustParseDecimal("1.05"))},
+		{name: "objects", a: New(point{X: 1}), b: New(point{X: 1}), equal: true},
+		{name: "different objects", a: New(point{X: 1}), b: New(point{X: 2})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Equal(tc.a, tc.b); got != tc.equal {
+				t.Errorf("Equal(%v, %v): want %v, got %v", tc.a, tc.b, tc.equal, got)
+			}
+			if tc.equal && Hash(tc.a) != Hash(tc.b) {
+				t.Errorf("equal values with different hashes: %v, %v", tc.a, tc.b)
+			}
+		})
+	}
+}
+
+func TestTypeOf(t *testing.T) {
+	ll := NewList(ListType)
+	_ = ll.AddValue(newIntList(1))
+	m := NewMap(String, ListType)
+	_ = m.Put(New("a"), ll)
+	s := MustNewSchema(Field{Name: "a", Type: Int})
+
+	for _, tc := range []struct {
+		v    Value
+		want string
+	}{
+		{v: New(1), want: "int"},
+		{v: newIntList(), want: "list<int>"},
+		{v: NewList(ListType), want: "list<list>"},
+		{v: ll, want: "list<list<int>>"},
+		{v: NewSet(String), want: "set<string>"},
+		{v: m, want: "map<string, list<list<int>>>"},
+		{v: MustNewRecord(s, New(1)), want: "record<a: int>"},
+	} {
+		if diff := cmp.Diff(tc.want, TypeOf(tc.v).String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	}
+
+	if !TypeOf(ll).Equal(ListOf(ListOf(Scalar(Int)))) {
+		t.Errorf("unexpected type: %v", TypeOf(ll))
+	}
+	if TypeOf(ll).Equal(ListOf(Scalar(ListType))) {
+		t.Errorf("type should not be equal to shallow list type")
+	}
+	if got := newIntList().Type(); got != ListType {
+		t.Errorf("unexpected list type: %v", got)
+	}
+}
@@ -0,0 +1,386 @@
+package values
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	maxInt = int64(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
+
+func isSigned(t Type) bool {
+	switch t {
+	case Int, Int8, Int16, Int32, Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnsigned(t Type) bool {
+	switch t {
+	case Uint8, Uint16, Uint32, Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloat(t Type) bool {
+	return t == Float32 || t == Float64
+}
+
+// IsNumeric returns true for integer, floating point and decimal types.
+func IsNumeric(t Type) bool {
+	return isSigned(t) || isUnsigned(t) || isFloat(t) || t == Decimal
+}
+
+// CommonType returns the type to which values of type a and b must be widened
+// in order to be compared or combined.
+// Equal types are left untouched, otherwise integers widen to Int64 (or Uint64, if both unsigned),
+// integers and decimals to Decimal, and anything combined with floating point numbers to Float64.
+func CommonType(a, b Type) (Type, error) {
+	if a == b {
+		return a, nil
+	}
+	if !IsNumeric(a) || !IsNumeric(b) {
+		return 0, fmt.Errorf("no common type for %v and %v", a, b)
+	}
+	switch {
+	case isFloat(a) || isFloat(b):
+		return Float64, nil
+	case a == Decimal || b == Decimal:
+		return Decimal, nil
+	case isUnsigned(a) && isUnsigned(b):
+		return Uint64, nil
+	default:
+		return Int64, nil
+	}
+}
+
+func convertError(v Value, t Type) error {
+	return fmt.Errorf("cannot convert %v of type %v to %v", v, v.Type(), t)
+}
+
+func overflowError(v interface{}, t Type) error {
+	return fmt.Errorf("%v overflows %v", v, t)
+}
+
+// AsInt64 returns the value of integers as int64.
+func AsInt64(v Value) (int64, error) {
+	if v.IsNull() {
+		return 0, convertError(v, Int64)
+	}
+	switch x := v.Get().(type) {
+	case int:
+		return int64(x), nil
+	case int8:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case uint8:
+		return int64(x), nil
+	case uint16:
+		return int64(x), nil
+	case uint32:
+		return int64(x), nil
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0, overflowError(x, Int64)
+		}
+		return int64(x), nil
+	default:
+		return 0, convertError(v, Int64)
+	}
+}
+
+// AsUint64 returns the value of non-negative integers as uint64.
+func AsUint64(v Value) (uint64, error) {
+	if v.IsNull() {
+		return 0, convertError(v, Uint64)
+	}
+	if isSigned(v.Type()) {
+		x, _ := AsInt64(v)
+		if x < 0 {
+			return 0, overflowError(x, Uint64)
+		}
+		return uint64(x), nil
+	}
+	switch x := v.Get().(type) {
+	case uint8:
+		return uint64(x), nil
+	case uint16:
+		return uint64(x), nil
+	case uint32:
+		return uint64(x), nil
+	case uint64:
+		return x, nil
+	default:
+		return 0, convertError(v, Uint64)
+	}
+}
+
+// AsFloat64 returns the value of numbers as float64.
+// Large integers and decimals are rounded to the nearest float64.
+func AsFloat64(v Value) (float64, error) {
+	if v.IsNull() {
+		return 0, convertError(v, Float64)
+	}
+	switch x := v.Get().(type) {
+	case float32:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case Dec:
+		return x.Float64(), nil
+	}
+	if isUnsigned(v.Type()) {
+		x, _ := AsUint64(v)
+		return float64(x), nil
+	}
+	x, err := AsInt64(v)
+	if err != nil {
+		return 0, convertError(v, Float64)
+	}
+	return float64(x), nil
+}
+
+// AsDecimal returns the value of numbers as decimals.
+// Floating point numbers are converted using their shortest exact representation, e.g., 0.1 becomes 0.1.
+func AsDecimal(v Value) (Dec, error) {
+	if v.IsNull() {
+		return Dec{}, convertError(v, Decimal)
+	}
+	switch x := v.Get().(type) {
+	case Dec:
+		return x, nil
+	case float32:
+		return floatToDecimal(float64(x), 32)
+	case float64:
+		return floatToDecimal(x, 64)
+	}
+	if isUnsigned(v.Type()) {
+		x, _ := AsUint64(v)
+		return ParseDecimal(strconv.FormatUint(x, 10))
+	}
+	x, err := AsInt64(v)
+	if err != nil {
+		return Dec{}, convertError(v, Decimal)
+	}
+	return NewDecimal(x, 0), nil
+}
+
+func floatToDecimal(f float64, bitSize int) (Dec, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Dec{}, fmt.Errorf("cannot convert %v to decimal", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, bitSize))
+}
+
+// Convert converts v to type t.
+// Conversions to integers fail if the value does not fit the target type, or if it has a fractional part.
+// Conversions to floating point numbers round to the nearest representable value.
+// Strings are parsed, and every primitive value can be converted to string.
+// Nulls are converted to nulls of type t.
+func Convert(v Value, t Type) (Value, error) {
+	v = undecorate(v)
+	if v.Type() == t {
+		return v, nil
+	}
+	if v.IsNull() {
+		return NewNull(t), nil
+	}
+	if v.Type() == String {
+		return parse(v.String(), t)
+	}
+	switch {
+	case t == String:
+		switch v.Type() {
+		case Object, RecordType, ListType, MapType, SetType:
+			return nil, convertError(v, t)
+		case Time:
+			return New(v.Time().Format(time.RFC3339Nano)), nil
+		case Bytes:
+			return New(string(v.Bytes())), nil
+		default:
+			return New(v.String()), nil
+		}
+	case isSigned(t):
+		x, err := AsInt64(v)
+		if err != nil {
+			if x, ok := integralFloat(v); ok {
+				return newSigned(x, t)
+			}
+			return nil, err
+		}
+		return newSigned(x, t)
+	case isUnsigned(t):
+		x, err := AsUint64(v)
+		if err != nil {
+			if x, ok := integralFloat(v); ok && x >= 0 {
+				return newUnsigned(uint64(x), t)
+			}
+			return nil, err
+		}
+		return newUnsigned(x, t)
+	case t == Float32:
+		x, err := AsFloat64(v)
+		if err != nil {
+			return nil, err
+		}
+		if math.Abs(x) > math.MaxFloat32 && !math.IsInf(x, 0) {
+			return nil, overflowError(x, t)
+		}
+		return New(float32(x)), nil
+	case t == Float64:
+		x, err := AsFloat64(v)
+		if err != nil {
+			return nil, err
+		}
+		return New(x), nil
+	case t == Decimal:
+		x, err := AsDecimal(v)
+		if err != nil {
+			return nil, err
+		}
+		return New(x), nil
+	default:
+		return nil, convertError(v, t)
+	}
+}
+
+// integralFloat returns floats and decimals with no fractional part as int64.
+func integralFloat(v Value) (int64, bool) {
+	if v.Type() == Decimal {
+		d := v.Decimal()
+		r := d.Round(0)
+		if !r.Equal(d) || !r.unscaled().IsInt64() {
+			return 0, false
+		}
+		return r.unscaled().Int64(), true
+	}
+	f, err := AsFloat64(v)
+	if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func newSigned(x int64, t Type) (Value, error) {
+	switch t {
+	case Int:
+		if x < minInt || x > maxInt {
+			return nil, overflowError(x, t)
+		}
+		return New(int(x)), nil
+	case Int8:
+		if x < math.MinInt8 || x > math.MaxInt8 {
+			return nil, overflowError(x, t)
+		}
+		return New(int8(x)), nil
+	case Int16:
+		if x < math.MinInt16 || x > math.MaxInt16 {
+			return nil, overflowError(x, t)
+		}
+		return New(int16(x)), nil
+	case Int32:
+		if x < math.MinInt32 || x > math.MaxInt32 {
+			return nil, overflowError(x, t)
+		}
+		return New(int32(x)), nil
+	default:
+		return New(x), nil
+	}
+}
+
+func newUnsigned(x uint64, t Type) (Value, error) {
+	switch t {
+	case Uint8:
+		if x > math.MaxUint8 {
+			return nil, overflowError(x, t)
+		}
+		return New(uint8(x)), nil
+	case Uint16:
+		if x > math.MaxUint16 {
+			return nil, overflowError(x, t)
+		}
+		return New(uint16(x)), nil
+	case Uint32:
+		if x > math.MaxUint32 {
+			return nil, overflowError(x, t)
+		}
+		return New(uint32(x)), nil
+	default:
+		return New(x), nil
+	}
+}
+
+func parse(s string, t Type) (Value, error) {
+	wrap := func(err error) error {
+		return fmt.Errorf("cannot parse %q as %v: %w", s, t, err)
+	}
+	switch {
+	case isSigned(t):
+		x, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return newSigned(x, t)
+	case isUnsigned(t):
+		x, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return newUnsigned(x, t)
+	}
+	switch t {
+	case Float32:
+		x, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return New(float32(x)), nil
+	case Float64:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return New(x), nil
+	case Bool:
+		x, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return New(x), nil
+	case Decimal:
+		x, err := ParseDecimal(s)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return New(x), nil
+	case Time:
+		x, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return New(x), nil
+	case Duration:
+		x, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return New(x), nil
+	case Bytes:
+		return New([]byte(s)), nil
+	default:
+		return nil, fmt.Errorf("cannot parse %q as %v", s, t)
+	}
+}
@@ -0,0 +1,115 @@
+package values
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCommonType(t *testing.T) {
+	for _, tc := range []struct {
+		a, b, want Type
+	}{
+		{a: Int, b: Int, want: Int},
+		{a: Int, b: Int8, want: Int64},
+		{a: Uint8, b: Uint32, want: Uint64},
+		{a: Uint8, b: Int16, want: Int64},
+		{a: Int, b: Float32, want: Float64},
+		{a: Int64, b: Decimal, want: Decimal},
+		{a: Decimal, b: Float64, want: Float64},
+	} {
+		got, err := CommonType(tc.a, tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("CommonType(%v, %v): want %v, got %v", tc.a, tc.b, tc.want, got)
+		}
+	}
+	if _, err := CommonType(Int, String); err == nil {
+		t.Error("expected error, got none")
+	}
+}
+
+func TestAs(t *testing.T) {
+	if x, err := AsInt64(New(int8(-3))); err != nil || x != -3 {
+		t.Errorf("unexpected result: %v, %v", x, err)
+	}
+	if _, err := AsInt64(New(uint64(math.MaxUint64))); err == nil {
+		t.Error("expected overflow error, got none")
+	}
+	if _, err := AsInt64(New("1")); err == nil {
+		t.Error("expected error, got none")
+	}
+	if _, err := AsUint64(New(-1)); err == nil {
+		t.Error("expected error, got none")
+	}
+	if x, err := AsFloat64(New(uint16(7))); err != nil || x != 7 {
+		t.Errorf("unexpected result: %v, %v", x, err)
+	}
+	if x, err := AsDecimal(New(0.1)); err != nil || x.String() != "0.1" {
+		t.Errorf("unexpected result: %v, %v", x, err)
+	}
+	if _, err := AsFloat64(NewNull(Float64)); err == nil {
+		t.Error("expected error, got none")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		v    Value
+		t    Type
+		want string
+	}{
+		{name: "widen", v: New(int8(-8)), t: Int64, want: "int64(-8)"},
+		{name: "narrow", v: New(int64(127)), t: Int8, want: "int8(127)"},
+		{name: "signed to unsigned", v: New(42), t: Uint16, want: "uint16(0x2a)"},
+		{name: "integral float", v: New(3.0), t: Int, want: "int(3)"},
+		{name: "integral decimal", v: New(MustParseDecimal("12.00")), t: Uint8, want: "uint8(0xc)"},
+		{name: "to float", v: New(uint32(5)), t: Float32, want: "float32(5)"},
+		{name: "to decimal", v: New(int16(-5)), t: Decimal, want: "values.Dec(-5)"},
+		{name: "to string", v: New(1.5), t: String, want: `string("1.5")`},
+		{name: "parse int", v: New("-12"), t: Int32, want: "int32(-12)"},
+		{name: "parse decimal", v: New("1.10"), t: Decimal, want: "values.Dec(1.10)"},
+		{name: "parse duration", v: New("1m30s"), t: Duration, want: "time.Duration(1m30s)"},
+		{name: "parse bool", v: New("true"), t: Bool, want: "bool(true)"},
+		{name: "null", v: NewNull(Int), t: Float64, want: "null(float64)"},
+		{name: "decorated", v: SetKey(1, New(1)), t: Int64, want: "int64(1)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Convert(tc.v, tc.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, describe(got)); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		v    Value
+		t    Type
+	}{
+		{name: "overflow", v: New(128), t: Int8},
+		{name: "negative to unsigned", v: New(-1), t: Uint64},
+		{name: "fractional", v: New(1.5), t: Int},
+		{name: "fractional decimal", v: New(MustParseDecimal("1.5")), t: Int},
+		{name: "float32 overflow", v: New(math.MaxFloat64), t: Float32},
+		{name: "parse", v: New("abc"), t: Int},
+		{name: "parse overflow", v: New("300"), t: Uint8},
+		{name: "bool to int", v: New(true), t: Int},
+		{name: "time to int", v: New(time.Unix(0, 0)), t: Int64},
+		{name: "list to string", v: NewList(Int), t: String},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := Convert(tc.v, tc.t); err == nil {
+				t.Errorf("expected error, got %v", got)
+			}
+		})
+	}
+}
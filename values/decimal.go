@@ -0,0 +1,207 @@
+package values
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Dec is an exact decimal number, held by values of type Decimal.
+// It is represented as an arbitrary precision unscaled integer and a non-negative scale: unscaled * 10^(-scale).
+// Use it instead of floating point numbers when exactness matters, e.g., for amounts of money.
+// The zero value is 0.
+type Dec struct {
+	// u is never modified after creation, so that Dec can be copied safely.
+	u     *big.Int
+	scale int32
+}
+
+var bigTen = big.NewInt(10)
+
+// NewDecimal returns unscaled * 10^(-scale).
+func NewDecimal(unscaled int64, scale int32) Dec {
+	if scale < 0 {
+		panic(fmt.Errorf("negative decimal scale: %d", scale))
+	}
+	return Dec{u: big.NewInt(unscaled), scale: scale}
+}
+
+// NewDecimalFromBigInt is like NewDecimal, but for arbitrary precision integers.
+func NewDecimalFromBigInt(unscaled *big.Int, scale int32) Dec {
+	if scale < 0 {
+		panic(fmt.Errorf("negative decimal scale: %d", scale))
+	}
+	return Dec{u: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// ParseDecimal parses decimal numbers such as "-12.340".
+// The scale is the number of digits after the decimal point.
+func ParseDecimal(s string) (Dec, error) {
+	digits := s
+	var scale int32
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		frac := s[i+1:]
+		if len(frac) == 0 || strings.ContainsAny(frac, "+-") {
+			return Dec{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		digits = s[:i] + frac
+		scale = int32(len(frac))
+	}
+	u, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Dec{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Dec{u: u, scale: scale}, nil
+}
+
+// NewDecimalFromFloat returns the decimal nearest to f at the given scale.
+func NewDecimalFromFloat(f float64, scale int32) (Dec, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Dec{}, fmt.Errorf("cannot convert %v to decimal", f)
+	}
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Dec{}, err
+	}
+	return d.Round(scale), nil
+}
+
+// MustParseDecimal is like ParseDecimal, but panics on error.
+func MustParseDecimal(s string) Dec {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Dec) unscaled() *big.Int {
+	if d.u == nil {
+		return new(big.Int)
+	}
+	return d.u
+}
+
+// Unscaled returns a copy of the unscaled value of d.
+func (d Dec) Unscaled() *big.Int {
+	return new(big.Int).Set(d.unscaled())
+}
+
+func (d Dec) Scale() int32 {
+	return d.scale
+}
+
+func (d Dec) Sign() int {
+	return d.unscaled().Sign()
+}
+
+// rescale returns the unscaled value of d at a greater or equal scale.
+func (d Dec) rescale(scale int32) *big.Int {
+	u := d.unscaled()
+	if scale == d.scale {
+		return u
+	}
+	m := new(big.Int).Exp(bigTen, big.NewInt(int64(scale-d.scale)), nil)
+	return m.Mul(m, u)
+}
+
+func maxScale(a, b Dec) int32 {
+	if a.scale > b.scale {
+		return a.scale
+	}
+	return b.scale
+}
+
+func (d Dec) Add(o Dec) Dec {
+	s := maxScale(d, o)
+	return Dec{u: new(big.Int).Add(d.rescale(s), o.rescale(s)), scale: s}
+}
+
+func (d Dec) Sub(o Dec) Dec {
+	s := maxScale(d, o)
+	return Dec{u: new(big.Int).Sub(d.rescale(s), o.rescale(s)), scale: s}
+}
+
+// Mul returns the exact product of d and o, whose scale is the sum of the scales.
+func (d Dec) Mul(o Dec) Dec {
+	return Dec{u: new(big.Int).Mul(d.unscaled(), o.unscaled()), scale: d.scale + o.scale}
+}
+
+func (d Dec) Neg() Dec {
+	return Dec{u: new(big.Int).Neg(d.unscaled()), scale: d.scale}
+}
+
+// Cmp compares d and o numerically, regardless of their scale.
+// It returns -1, 0 or +1, if d is less than, equal to, or greater than o.
+func (d Dec) Cmp(o Dec) int {
+	s := maxScale(d, o)
+	return d.rescale(s).Cmp(o.rescale(s))
+}
+
+// Equal returns true if d and o are numerically equal, e.g., 1.0 and 1.00.
+func (d Dec) Equal(o Dec) bool {
+	return d.Cmp(o) == 0
+}
+
+// Round returns d rounded to the given scale, rounding half away from zero.
+func (d Dec) Round(scale int32) Dec {
+	if scale < 0 {
+		panic(fmt.Errorf("negative decimal scale: %d", scale))
+	}
+	if scale >= d.scale {
+		return Dec{u: d.rescale(scale), scale: scale}
+	}
+	m := new(big.Int).Exp(bigTen, big.NewInt(int64(d.scale-scale)), nil)
+	q, r := new(big.Int).QuoRem(d.unscaled(), m, new(big.Int))
+	// Round half away from zero: |2r| >= m.
+	if r.Abs(r).Lsh(r, 1).Cmp(m) >= 0 {
+		if d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Dec{u: q, scale: scale}
+}
+
+// normalize strips trailing zeros, so that numerically equal decimals have the same representation.
+func (d Dec) normalize() Dec {
+	u := d.Unscaled()
+	s := d.scale
+	r := new(big.Int)
+	for s > 0 {
+		q, _ := new(big.Int).QuoRem(u, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		u = q
+		s--
+	}
+	return Dec{u: u, scale: s}
+}
+
+// Float64 returns the nearest float64 to d.
+func (d Dec) Float64() float64 {
+	f, _ := new(big.Float).SetString(d.String())
+	x, _ := f.Float64()
+	return x
+}
+
+func (d Dec) String() string {
+	u := d.unscaled()
+	if d.scale == 0 {
+		return u.String()
+	}
+	digits := new(big.Int).Abs(u).String()
+	if pad := int(d.scale) - len(digits) + 1; pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	i := len(digits) - int(d.scale)
+	s := digits[:i] + "." + digits[i:]
+	if u.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package values
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		got  Dec
+		want string
+	}{
+		{name: "parse", got: MustParseDecimal("-0.050"), want: "-0.050"},
+		{name: "new", got: NewDecimal(-5, 3), want: "-0.005"},
+		{name: "zero", got: Dec{}, want: "0"},
+		{name: "add", got: MustParseDecimal("0.1").Add(MustParseDecimal("0.2")), want: "0.3"},
+		{name: "add scales", got: MustParseDecimal("1.25").Add(MustParseDecimal("3")), want: "4.25"},
+		{name: "sub", got: MustParseDecimal("1").Sub(MustParseDecimal("1.01")), want: "-0.01"},
+		{name: "mul", got: MustParseDecimal("1.5").Mul(MustParseDecimal("-0.25")), want: "-0.375"},
+		{name: "round half up", got: MustParseDecimal("2.345").Round(2), want: "2.35"},
+		{name: "round half negative", got: MustParseDecimal("-2.345").Round(2), want: "-2.35"},
+		{name: "round down", got: MustParseDecimal("2.344").Round(2), want: "2.34"},
+		{name: "round up scale", got: MustParseDecimal("2").Round(2), want: "2.00"},
+		{name: "big", got: MustParseDecimal("99999999999999999999.99").Add(MustParseDecimal("0.01")), want: "100000000000000000000.00"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.got.String()); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+
+	for _, s := range []string{"", ".", "1.", "1.-2", "a.1", "1.2.3"} {
+		if _, err := ParseDecimal(s); err == nil {
+			t.Errorf("expected error parsing %q, got none", s)
+		}
+	}
+
+	if d, err := NewDecimalFromFloat(0.1+0.2, 2); err != nil || d.String() != "0.30" {
+		t.Errorf("unexpected decimal from float: %v, %v", d, err)
+	}
+	if f := MustParseDecimal("-1.25").Float64(); f != -1.25 {
+		t.Errorf("unexpected float: %v", f)
+	}
+	if MustParseDecimal("1.10").Cmp(MustParseDecimal("1.1")) != 0 || MustParseDecimal("-1").Cmp(NewDecimal(0, 0)) != -1 {
+		t.Error("unexpected comparison result")
+	}
+	if v := New(MustParseDecimal("9.99")); v.Type() != Decimal || v.Decimal().String() != "9.99" {
+		t.Errorf("unexpected decimal value: %v", v)
+	}
+}
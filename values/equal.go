@@ -0,0 +1,211 @@
+package values
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"time"
+)
+
+// undecorate strips keys, sources, origins and timestamps from a value.
+func undecorate(v Value) Value {
+	for {
+		switch dv := v.(type) {
+		case *keyedValue:
+			v = dv.Value
+		case *valueWithSource:
+			v = dv.Value
+		case *valueWithOrigin:
+			v = dv.Value
+		case *timestampedValue:
+			v = dv.Value
+		default:
+			return v
+		}
+	}
+}
+
+// isNull is like IsNull, but values of type Object are never considered null.
+func isNull(v Value) bool {
+	_, ok := undecorate(v).(nullValue)
+	return ok
+}
+
+// Equal compares values by content, also when nested in lists, sets, maps and records.
+// Keys, sources, origins and timestamps are ignored.
+func Equal(a, b Value) bool {
+	a, b = undecorate(a), undecorate(b)
+	if a.Type() != b.Type() || isNull(a) != isNull(b) {
+		return false
+	}
+	if isNull(a) {
+		return true
+	}
+	switch a := a.(type) {
+	case meta:
+		return true
+	case *List:
+		bl := b.(*List)
+		if a.t != bl.t || len(a.vs) != len(bl.vs) {
+			return false
+		}
+		for i, v := range a.vs {
+			if !Equal(v, bl.vs[i]) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		bs := b.(*Set)
+		if a.t != bs.t || a.Len() != bs.Len() {
+			return false
+		}
+		for _, v := range a.es.ks {
+			if !bs.Contains(v) {
+				return false
+			}
+		}
+		return true
+	case *Map:
+		bm := b.(*Map)
+		if a.kt != bm.kt || a.vt != bm.vt || a.Len() != bm.Len() {
+			return false
+		}
+		for i, k := range a.es.ks {
+			bv, ok := bm.Lookup(k)
+			if !ok || !Equal(a.es.vs[i], bv) {
+				return false
+			}
+		}
+		return true
+	case *Record:
+		br := b.(*Record)
+		if !a.s.Equal(br.s) {
+			return false
+		}
+		for i, v := range a.vs {
+			if !Equal(v, br.vs[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	switch a.Type() {
+	case Object:
+		return reflect.DeepEqual(a.Get(), b.Get())
+	case Time:
+		return a.Time().Equal(b.Time())
+	case Bytes:
+		return bytes.Equal(a.Bytes(), b.Bytes())
+	case Decimal:
+		return a.Decimal().Equal(b.Decimal())
+	default:
+		return a.Get() == b.Get()
+	}
+}
+
+// Hash returns a hash of the content of a value, consistent with Equal.
+// The hash of sets and maps does not depend on the order of their elements.
+func Hash(v Value) uint64 {
+	h := fnv.New64a()
+	var tmp [8]byte
+	write := func(x uint64) {
+		binary.LittleEndian.PutUint64(tmp[:], x)
+		_, _ = h.Write(tmp[:])
+	}
+
+	v = undecorate(v)
+	write(uint64(v.Type()))
+	if isNull(v) {
+		write(0)
+		return h.Sum64()
+	}
+	switch v := v.(type) {
+	case meta:
+		return h.Sum64()
+	case *List:
+		write(uint64(v.t))
+		for _, e := range v.vs {
+			write(Hash(e))
+		}
+		return h.Sum64()
+	case *Set:
+		var sum uint64
+		for _, e := range v.es.ks {
+			sum += Hash(e)
+		}
+		write(uint64(v.t))
+		write(sum)
+		return h.Sum64()
+	case *Map:
+		var sum uint64
+		for i, k := range v.es.ks {
+			sum += Hash(k)*31 + Hash(v.es.vs[i])
+		}
+		write(uint64(v.kt))
+		write(uint64(v.vt))
+		write(sum)
+		return h.Sum64()
+	case *Record:
+		for _, f := range v.vs {
+			write(Hash(f))
+		}
+		return h.Sum64()
+	}
+
+	switch x := v.Get().(type) {
+	case int:
+		write(uint64(x))
+	case int8:
+		write(uint64(x))
+	case int16:
+		write(uint64(x))
+	case int32:
+		write(uint64(x))
+	case int64:
+		write(uint64(x))
+	case uint8:
+		write(uint64(x))
+	case uint16:
+		write(uint64(x))
+	case uint32:
+		write(uint64(x))
+	case uint64:
+		write(x)
+	case float32:
+		if x == 0 {
+			// Normalize negative zero.
+			x = 0
+		}
+		write(uint64(math.Float32bits(x)))
+	case float64:
+		if x == 0 {
+			x = 0
+		}
+		write(math.Float64bits(x))
+	case bool:
+		if x {
+			write(1)
+		} else {
+			write(0)
+		}
+	case string:
+		_, _ = h.Write([]byte(x))
+	case time.Time:
+		write(uint64(x.UnixNano()))
+	case time.Duration:
+		write(uint64(x))
+	case []byte:
+		_, _ = h.Write(x)
+	case Dec:
+		n := x.normalize()
+		write(uint64(n.scale))
+		_, _ = h.Write([]byte(n.unscaled().String()))
+	default:
+		_, _ = fmt.Fprintf(h, "%T:%v", x, x)
+	}
+	return h.Sum64()
+}
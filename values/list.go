@@ -5,6 +5,7 @@ import "fmt"
 var _ Value = (*List)(nil)
 
 type List struct {
+	nonPrimitive
 	t  Type
 	vs []Value
 }
@@ -13,9 +14,13 @@ func NewList(t Type) *List {
 	return &List{t: t}
 }
 
-// TODO(affo): find a better way of expressing types.
-//  A list if of type List<T>...
 func (l *List) Type() Type {
+	return ListType
+}
+
+// ElemType returns the type of the elements of the list.
+// Use TypeOf for a complete description of the list type.
+func (l *List) ElemType() Type {
 	return l.t
 }
 
@@ -51,54 +56,6 @@ func (l *List) Unwrap() (Value, error) {
 	return nil, fmt.Errorf("list cannot be unwrapped")
 }
 
-func (l *List) Int() int {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Bool() bool {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Float32() float32 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Float64() float64 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Int16() int16 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Int32() int32 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Int64() int64 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Int8() int8 {
-	panic("cannot return primitive type from list")
-}
-
 func (l *List) String() string {
 	return fmt.Sprintf("%v", l.vs)
 }
-
-func (l *List) Uint16() uint16 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Uint32() uint32 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Uint64() uint64 {
-	panic("cannot return primitive type from list")
-}
-
-func (l *List) Uint8() uint8 {
-	panic("cannot return primitive type from list")
-}
@@ -10,6 +10,7 @@ const (
 )
 
 type meta struct {
+	nonPrimitive
 	t Type
 }
 
@@ -39,54 +40,6 @@ func (m meta) Clone() Value {
 	return NewMeta(m.t)
 }
 
-func (m meta) Int() int {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Bool() bool {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Float32() float32 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Float64() float64 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Int16() int16 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Int32() int32 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Int64() int64 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Int8() int8 {
-	panic("cannot return primitive type from meta value")
-}
-
 func (m meta) String() string {
 	return fmt.Sprintf("meta(%v)", m.t)
 }
-
-func (m meta) Uint16() uint16 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Uint32() uint32 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Uint64() uint64 {
-	panic("cannot return primitive type from meta value")
-}
-
-func (m meta) Uint8() uint8 {
-	panic("cannot return primitive type from meta value")
-}
@@ -0,0 +1,286 @@
+package values
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Compare returns -1, 0 or +1 if a is less than, equal to, or greater than b.
+// Numbers of different types are compared by value, nulls come before any other value,
+// and lists and records are compared lexicographically.
+// NaNs are equal to each other and less than any other float, so that ordering is total.
+// An error is returned if a and b are not comparable.
+func Compare(a, b Value) (int, error) {
+	a, b = undecorate(a), undecorate(b)
+	switch {
+	case isNull(a) && isNull(b):
+		return 0, nil
+	case isNull(a):
+		return -1, nil
+	case isNull(b):
+		return 1, nil
+	case a.Type() == Object || b.Type() == Object:
+		return 0, fmt.Errorf("cannot compare objects: %v, %v", a, b)
+	}
+	if IsNumeric(a.Type()) && IsNumeric(b.Type()) {
+		return compareNumbers(a, b)
+	}
+	if a.Type() != b.Type() {
+		return 0, fmt.Errorf("cannot compare %v and %v", a.Type(), b.Type())
+	}
+	switch a.Type() {
+	case String:
+		return strings.Compare(a.String(), b.String()), nil
+	case Bool:
+		return compareBools(a.Bool(), b.Bool()), nil
+	case Time:
+		ta, tb := a.Time(), b.Time()
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	case Duration:
+		return compareInts(int64(a.Duration()), int64(b.Duration())), nil
+	case Bytes:
+		return bytes.Compare(a.Bytes(), b.Bytes()), nil
+	case ListType:
+		return compareSequences(a.(*List).vs, b.(*List).vs)
+	case RecordType:
+		ra, rb := a.(*Record), b.(*Record)
+		if !ra.s.Equal(rb.s) {
+			return 0, fmt.Errorf("cannot compare records with different schemas: %v, %v", ra.s, rb.s)
+		}
+		return compareSequences(ra.vs, rb.vs)
+	default:
+		return 0, fmt.Errorf("values of type %v are not comparable", a.Type())
+	}
+}
+
+func compareSequences(as, bs []Value) (int, error) {
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		c, err := Compare(as[i], bs[i])
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return compareInts(int64(len(as)), int64(len(bs))), nil
+}
+
+func compareNumbers(a, b Value) (int, error) {
+	t, err := CommonType(a.Type(), b.Type())
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case isFloat(t):
+		fa, _ := AsFloat64(a)
+		fb, _ := AsFloat64(b)
+		return compareFloats(fa, fb), nil
+	case t == Decimal:
+		da, err := AsDecimal(a)
+		if err != nil {
+			return 0, err
+		}
+		db, err := AsDecimal(b)
+		if err != nil {
+			return 0, err
+		}
+		return da.Cmp(db), nil
+	case isUnsigned(t):
+		ua, _ := AsUint64(a)
+		ub, _ := AsUint64(b)
+		return compareUints(ua, ub), nil
+	}
+	// Mixing signed and unsigned integers: compare signs first, so that no overflow can happen.
+	sa, sb := sign(a), sign(b)
+	if sa != sb || sa < 0 {
+		if sa != sb {
+			return compareInts(int64(sa), int64(sb)), nil
+		}
+		ia, _ := AsInt64(a)
+		ib, _ := AsInt64(b)
+		return compareInts(ia, ib), nil
+	}
+	ua, _ := AsUint64(a)
+	ub, _ := AsUint64(b)
+	return compareUints(ua, ub), nil
+}
+
+// sign returns -1, 0 or +1 for integers.
+func sign(v Value) int {
+	if isUnsigned(v.Type()) {
+		u, _ := AsUint64(v)
+		if u == 0 {
+			return 0
+		}
+		return 1
+	}
+	i, _ := AsInt64(v)
+	return compareInts(i, 0)
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareUints(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// Min returns the lesser of a and b.
+// Nulls are ignored: if one of the values is null, the other one is returned.
+func Min(a, b Value) (Value, error) {
+	return pick(a, b, -1)
+}
+
+// Max returns the greater of a and b.
+// Nulls are ignored: if one of the values is null, the other one is returned.
+func Max(a, b Value) (Value, error) {
+	return pick(a, b, 1)
+}
+
+func pick(a, b Value, want int) (Value, error) {
+	if isNull(a) {
+		return b, nil
+	}
+	if isNull(b) {
+		return a, nil
+	}
+	c, err := Compare(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if c == -want {
+		return b, nil
+	}
+	return a, nil
+}
+
+// Add returns the sum of a and b.
+// Numbers are widened to their CommonType, and an error is returned on overflow.
+// Durations can be added to durations and to times.
+// Nulls are ignored: if one of the values is null, the other one is returned.
+// This makes Add suitable for aggregations that start from a null value.
+func Add(a, b Value) (Value, error) {
+	a, b = undecorate(a), undecorate(b)
+	if isNull(a) {
+		return b, nil
+	}
+	if isNull(b) {
+		return a, nil
+	}
+	switch {
+	case a.Type() == Duration && b.Type() == Duration:
+		x, y := int64(a.Duration()), int64(b.Duration())
+		s, ok := addInt64(x, y)
+		if !ok {
+			return nil, overflowError(fmt.Sprintf("%v + %v", a, b), Duration)
+		}
+		return New(time.Duration(s)), nil
+	case a.Type() == Time && b.Type() == Duration:
+		return New(a.Time().Add(b.Duration())), nil
+	case a.Type() == Duration && b.Type() == Time:
+		return New(b.Time().Add(a.Duration())), nil
+	}
+	t, err := CommonType(a.Type(), b.Type())
+	if err != nil || !IsNumeric(t) {
+		return nil, fmt.Errorf("cannot add %v and %v", a.Type(), b.Type())
+	}
+	switch {
+	case isSigned(t):
+		x, err := AsInt64(a)
+		if err != nil {
+			return nil, err
+		}
+		y, err := AsInt64(b)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := addInt64(x, y)
+		if !ok {
+			return nil, overflowError(fmt.Sprintf("%v + %v", a, b), t)
+		}
+		return newSigned(s, t)
+	case isUnsigned(t):
+		x, _ := AsUint64(a)
+		y, _ := AsUint64(b)
+		s := x + y
+		if s < x {
+			return nil, overflowError(fmt.Sprintf("%v + %v", a, b), t)
+		}
+		return newUnsigned(s, t)
+	case t == Float32:
+		return New(a.Float32() + b.Float32()), nil
+	case t == Float64:
+		x, _ := AsFloat64(a)
+		y, _ := AsFloat64(b)
+		return New(x + y), nil
+	default:
+		x, err := AsDecimal(a)
+		if err != nil {
+			return nil, err
+		}
+		y, err := AsDecimal(b)
+		if err != nil {
+			return nil, err
+		}
+		return New(x.Add(y)), nil
+	}
+}
+
+func addInt64(x, y int64) (int64, bool) {
+	s := x + y
+	if (y > 0 && s < x) || (y < 0 && s > x) {
+		return 0, false
+	}
+	return s, true
+}
@@ -0,0 +1,126 @@
+package values
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompare(t *testing.T) {
+	l1 := newIntList(1, 2)
+	l2 := newIntList(1, 2, 0)
+	for _, tc := range []struct {
+		name string
+		a, b Value
+		want int
+	}{
+		{name: "ints", a: New(1), b: New(2), want: -1},
+		{name: "widened", a: New(int8(3)), b: New(int64(3)), want: 0},
+		{name: "int and float", a: New(2), b: New(1.5), want: 1},
+		{name: "signed and unsigned", a: New(-1), b: New(uint64(math.MaxUint64)), want: -1},
+		{name: "large unsigned", a: New(uint64(math.MaxUint64)), b: New(int64(math.MaxInt64)), want: 1},
+		{name: "decimal and int", a: New(MustParseDecimal("2.00")), b: New(2), want: 0},
+		{name: "NaN", a: New(math.NaN()), b: New(math.Inf(-1)), want: -1},
+		{name: "strings", a: New("b"), b: New("a"), want: 1},
+		{name: "bools", a: New(false), b: New(true), want: -1},
+		{name: "times", a: New(time.Unix(1, 0)), b: New(time.Unix(2, 0)), want: -1},
+		{name: "durations", a: New(time.Second), b: New(time.Millisecond), want: 1},
+		{name: "null first", a: NewNull(Int), b: New(math.MinInt64), want: -1},
+		{name: "lists", a: l1, b: l2, want: -1},
+		{name: "decorated", a: SetTime(1, 0, New(2)), b: New(1), want: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Compare(tc.a, tc.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Compare(%v, %v): want %d, got %d", tc.a, tc.b, tc.want, got)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		a, b Value
+	}{
+		{name: "string and int", a: New("1"), b: New(1)},
+		{name: "objects", a: New(point{}), b: New(point{})},
+		{name: "maps", a: NewMap(Int, Int), b: NewMap(Int, Int)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Compare(tc.a, tc.b); err == nil {
+				t.Error("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	var min, max Value = NewNull(Int), NewNull(Int)
+	for _, v := range []Value{New(3), New(int8(-1)), New(2.5), NewNull(Float64), New(uint8(10))} {
+		var err error
+		if min, err = Min(min, v); err != nil {
+			t.Fatal(err)
+		}
+		if max, err = Max(max, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff("int8(-1)", describe(min)); diff != "" {
+		t.Errorf("unexpected min -want/+got:\n\t%s", diff)
+	}
+	if diff := cmp.Diff("uint8(0xa)", describe(max)); diff != "" {
+		t.Errorf("unexpected max -want/+got:\n\t%s", diff)
+	}
+	if _, err := Max(New("a"), New(1)); err == nil {
+		t.Error("expected error, got none")
+	}
+}
+
+func TestAdd(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b Value
+		want string
+	}{
+		{name: "same type", a: New(int16(1)), b: New(int16(2)), want: "int16(3)"},
+		{name: "widened", a: New(1), b: New(int8(2)), want: "int64(3)"},
+		{name: "unsigned", a: New(uint8(200)), b: New(uint16(100)), want: "uint64(0x12c)"},
+		{name: "float", a: New(1), b: New(0.5), want: "float64(1.5)"},
+		{name: "float32", a: New(float32(1)), b: New(float32(0.5)), want: "float32(1.5)"},
+		{name: "decimal", a: New(MustParseDecimal("0.10")), b: New(2), want: "values.Dec(2.10)"},
+		{name: "durations", a: New(time.Second), b: New(time.Minute), want: "time.Duration(1m1s)"},
+		{name: "time", a: New(time.Unix(0, 0).UTC()), b: New(time.Hour), want: "time.Time(1970-01-01 01:00:00 +0000 UTC)"},
+		{name: "null", a: NewNull(Int), b: New(1), want: "int(1)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Add(tc.a, tc.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, describe(got)); diff != "" {
+				t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		a, b Value
+	}{
+		{name: "overflow", a: New(int8(100)), b: New(int8(100))},
+		{name: "int64 overflow", a: New(int64(math.MaxInt64)), b: New(1)},
+		{name: "uint64 overflow", a: New(uint64(math.MaxUint64)), b: New(uint8(1))},
+		{name: "strings", a: New("a"), b: New("b")},
+		{name: "time and int", a: New(time.Unix(0, 0)), b: New(1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := Add(tc.a, tc.b); err == nil {
+				t.Errorf("expected error, got %v", got)
+			}
+		})
+	}
+}
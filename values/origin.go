@@ -0,0 +1,66 @@
+package values
+
+// Origin is the position of the input a value has been merged from, by a union of streams.
+// As opposed to Source, it is set once by the union, and nodes do not change it.
+type Origin int
+
+type ValueWithOrigin interface {
+	Value
+	Origin() Origin
+
+	setOrigin(Origin)
+}
+
+var _ ValueWithOrigin = (*valueWithOrigin)(nil)
+
+type valueWithOrigin struct {
+	o Origin
+	Value
+}
+
+func (v *valueWithOrigin) Origin() Origin {
+	return v.o
+}
+
+func (v *valueWithOrigin) setOrigin(o Origin) {
+	v.o = o
+}
+
+func (v *valueWithOrigin) Unwrap() (Value, error) {
+	return v.Value, nil
+}
+
+func (v *valueWithOrigin) Clone() Value {
+	c := v.Value.Clone()
+	return SetOrigin(v.o, c)
+}
+
+func SetOrigin(o Origin, v Value) Value {
+	wo, err := GetValueWithOrigin(v)
+	if err != nil {
+		return &valueWithOrigin{o: o, Value: v}
+	}
+	wo.setOrigin(o)
+	return v
+}
+
+func GetValueWithOrigin(v Value) (ValueWithOrigin, error) {
+	for {
+		if wo, ok := v.(ValueWithOrigin); ok {
+			return wo, nil
+		}
+		uv, err := v.Unwrap()
+		if err != nil {
+			return nil, err
+		}
+		v = uv
+	}
+}
+
+func GetOrigin(v Value) (Origin, error) {
+	wo, err := GetValueWithOrigin(v)
+	if err != nil {
+		return 0, err
+	}
+	return wo.Origin(), nil
+}
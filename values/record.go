@@ -0,0 +1,288 @@
+package values
+
+import (
+	"fmt"
+	"strings"
+)
+
+var _ Value = (*Record)(nil)
+
+// Field describes a field of a Record.
+type Field struct {
+	Name string
+	Type Type
+	// Repeated fields hold a List of values of Type.
+	Repeated bool
+	// Nullable fields accept null values.
+	Nullable bool
+	// Schema describes nested records, it is required if Type is RecordType.
+	Schema *Schema
+}
+
+func (f Field) String() string {
+	t := f.Type.String()
+	if f.Type == RecordType {
+		t = f.Schema.String()
+	}
+	if f.Repeated {
+		t = fmt.Sprintf("list<%s>", t)
+	}
+	if f.Nullable {
+		t += "?"
+	}
+	return fmt.Sprintf("%s: %s", f.Name, t)
+}
+
+func (f Field) equal(o Field) bool {
+	if f.Name != o.Name || f.Type != o.Type || f.Repeated != o.Repeated || f.Nullable != o.Nullable {
+		return false
+	}
+	return f.Schema.Equal(o.Schema)
+}
+
+// check verifies that v can be the value of this field.
+func (f Field) check(v Value) error {
+	if v == nil {
+		return fmt.Errorf("field %q: nil value", f.Name)
+	}
+	if f.Repeated {
+		l, ok := v.(*List)
+		if !ok {
+			if v.IsNull() && f.Nullable {
+				return nil
+			}
+			return fmt.Errorf("field %q: expected list, got %v", f.Name, v)
+		}
+		if l.t != f.Type {
+			return fmt.Errorf("field %q: unexpected list of %v, want list of %v", f.Name, l.t, f.Type)
+		}
+		ef := f
+		ef.Repeated = false
+		for _, e := range l.vs {
+			if err := ef.check(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if v.Type() != f.Type {
+		return fmt.Errorf("field %q: unexpected type %v, want %v", f.Name, v.Type(), f.Type)
+	}
+	if v.IsNull() && f.Type != Object {
+		if !f.Nullable {
+			return fmt.Errorf("field %q: is not nullable", f.Name)
+		}
+		return nil
+	}
+	if f.Type == RecordType {
+		r, ok := v.(*Record)
+		if !ok {
+			return fmt.Errorf("field %q: expected record, got %v", f.Name, v)
+		}
+		if !r.s.Equal(f.Schema) {
+			return fmt.Errorf("field %q: unexpected schema %v, want %v", f.Name, r.s, f.Schema)
+		}
+	}
+	return nil
+}
+
+// Schema describes the fields of a Record.
+type Schema struct {
+	fields []Field
+	index  map[string]int
+}
+
+func NewSchema(fields ...Field) (*Schema, error) {
+	s := &Schema{
+		fields: fields,
+		index:  make(map[string]int, len(fields)),
+	}
+	for i, f := range fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("field %d has no name", i)
+		}
+		if _, ok := s.index[f.Name]; ok {
+			return nil, fmt.Errorf("duplicate field %q", f.Name)
+		}
+		if f.Type == RecordType && f.Schema == nil {
+			return nil, fmt.Errorf("field %q: record fields require a schema", f.Name)
+		}
+		if f.Type != RecordType && f.Schema != nil {
+			return nil, fmt.Errorf("field %q: only record fields can have a schema", f.Name)
+		}
+		s.index[f.Name] = i
+	}
+	return s, nil
+}
+
+// MustNewSchema is like NewSchema, but panics on error.
+func MustNewSchema(fields ...Field) *Schema {
+	s, err := NewSchema(fields...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Schema) NumFields() int {
+	return len(s.fields)
+}
+
+func (s *Schema) Field(i int) Field {
+	return s.fields[i]
+}
+
+func (s *Schema) Fields() []Field {
+	fs := make([]Field, len(s.fields))
+	copy(fs, s.fields)
+	return fs
+}
+
+// Index returns the index of the field with the given name.
+func (s *Schema) Index(name string) (int, bool) {
+	i, ok := s.index[name]
+	return i, ok
+}
+
+// Equal compares schemas structurally.
+func (s *Schema) Equal(o *Schema) bool {
+	if s == o {
+		return true
+	}
+	if s == nil || o == nil || len(s.fields) != len(o.fields) {
+		return false
+	}
+	for i, f := range s.fields {
+		if !f.equal(o.fields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Schema) String() string {
+	fs := make([]string, len(s.fields))
+	for i, f := range s.fields {
+		fs[i] = f.String()
+	}
+	return fmt.Sprintf("record<%s>", strings.Join(fs, ", "))
+}
+
+// Record is a value made of named and typed fields, described by a Schema.
+type Record struct {
+	nonPrimitive
+	s  *Schema
+	vs []Value
+}
+
+// NewRecord creates a new record with the given field values, in the order of the schema.
+// Missing values are set to null.
+func NewRecord(s *Schema, vs ...Value) (*Record, error) {
+	if len(vs) > len(s.fields) {
+		return nil, fmt.Errorf("too many values for schema %v: %d", s, len(vs))
+	}
+	r := &Record{
+		s:  s,
+		vs: make([]Value, len(s.fields)),
+	}
+	for i, f := range s.fields {
+		if i < len(vs) {
+			if err := f.check(vs[i]); err != nil {
+				return nil, err
+			}
+			r.vs[i] = vs[i]
+			continue
+		}
+		if !f.Nullable {
+			return nil, fmt.Errorf("field %q: missing value", f.Name)
+		}
+		if f.Repeated {
+			r.vs[i] = NewList(f.Type)
+		} else {
+			r.vs[i] = NewNull(f.Type)
+		}
+	}
+	return r, nil
+}
+
+// MustNewRecord is like NewRecord, but panics on error.
+func MustNewRecord(s *Schema, vs ...Value) *Record {
+	r, err := NewRecord(s, vs...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *Record) Schema() *Schema {
+	return r.s
+}
+
+func (r *Record) Type() Type {
+	return RecordType
+}
+
+// Get returns the values of the fields.
+func (r *Record) Get() interface{} {
+	return r.vs
+}
+
+// GetField returns the value of the field with the given name.
+func (r *Record) GetField(name string) (Value, error) {
+	i, ok := r.s.Index(name)
+	if !ok {
+		return nil, fmt.Errorf("no field %q in %v", name, r.s)
+	}
+	return r.vs[i], nil
+}
+
+// GetFieldAt returns the value of the i-th field.
+func (r *Record) GetFieldAt(i int) Value {
+	return r.vs[i]
+}
+
+// SetField sets the value of the field with the given name.
+func (r *Record) SetField(name string, v Value) error {
+	i, ok := r.s.Index(name)
+	if !ok {
+		return fmt.Errorf("no field %q in %v", name, r.s)
+	}
+	return r.SetFieldAt(i, v)
+}
+
+// SetFieldAt sets the value of the i-th field.
+func (r *Record) SetFieldAt(i int, v Value) error {
+	if err := r.s.fields[i].check(v); err != nil {
+		return err
+	}
+	r.vs[i] = v
+	return nil
+}
+
+func (r *Record) IsNull() bool {
+	return false
+}
+
+func (r *Record) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("record cannot be unwrapped")
+}
+
+// Clone deeply clones the record. The schema is shared, as it is immutable.
+func (r *Record) Clone() Value {
+	vs := make([]Value, len(r.vs))
+	for i, v := range r.vs {
+		vs[i] = v.Clone()
+	}
+	return &Record{
+		s:  r.s,
+		vs: vs,
+	}
+}
+
+func (r *Record) String() string {
+	fs := make([]string, len(r.vs))
+	for i, v := range r.vs {
+		fs[i] = fmt.Sprintf("%s: %v", r.s.fields[i].Name, v)
+	}
+	return fmt.Sprintf("{%s}", strings.Join(fs, ", "))
+}
@@ -0,0 +1,155 @@
+package values
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	addressSchema = MustNewSchema(
+		Field{Name: "city", Type: String},
+		Field{Name: "zip", Type: String, Nullable: true},
+	)
+	userSchema = MustNewSchema(
+		Field{Name: "id", Type: Int64},
+		Field{Name: "name", Type: String},
+		Field{Name: "address", Type: RecordType, Schema: addressSchema},
+		Field{Name: "tags", Type: String, Repeated: true, Nullable: true},
+	)
+)
+
+func newUser() *Record {
+	tags := NewList(String)
+	_ = tags.AddValue(New("admin"))
+	_ = tags.AddValue(New("ops"))
+	return MustNewRecord(userSchema,
+		New(int64(42)),
+		New("affo"),
+		MustNewRecord(addressSchema, New("Milan")),
+		tags,
+	)
+}
+
+func TestSchema(t *testing.T) {
+	t.Run("string", func(t *testing.T) {
+		want := "record<id: int64, name: string, address: record<city: string, zip: string?>, tags: list<string>?>"
+		if diff := cmp.Diff(want, userSchema.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("equal", func(t *testing.T) {
+		other := MustNewSchema(
+			Field{Name: "city", Type: String},
+			Field{Name: "zip", Type: String, Nullable: true},
+		)
+		if !addressSchema.Equal(other) {
+			t.Errorf("expected schemas to be equal")
+		}
+		if addressSchema.Equal(userSchema) {
+			t.Errorf("expected schemas to be different")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, fs := range [][]Field{
+			{{Name: "a", Type: Int}, {Name: "a", Type: String}},
+			{{Name: "", Type: Int}},
+			{{Name: "nested", Type: RecordType}},
+			{{Name: "a", Type: Int, Schema: addressSchema}},
+		} {
+			if _, err := NewSchema(fs...); err == nil {
+				t.Errorf("expected error for %v, got none", fs)
+			}
+		}
+	})
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("access", func(t *testing.T) {
+		r := newUser()
+		name, err := r.GetField("name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := name.String(), "affo"; got != want {
+			t.Errorf("unexpected name -want/+got:\n\t-\t%s\n\t+\t%s", want, got)
+		}
+		if got, want := r.GetFieldAt(0).Int64(), int64(42); got != want {
+			t.Errorf("unexpected id -want/+got:\n\t-\t%d\n\t+\t%d", want, got)
+		}
+		address, _ := r.GetField("address")
+		zip, err := address.(*Record).GetField("zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !zip.IsNull() {
+			t.Errorf("expected null zip, got %v", zip)
+		}
+		if _, err := r.GetField("missing"); err == nil {
+			t.Errorf("expected error, got none")
+		}
+		want := "{id: 42, name: affo, address: {city: Milan, zip: nil(string)}, tags: [admin ops]}"
+		if diff := cmp.Diff(want, r.String()); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+
+	t.Run("type check", func(t *testing.T) {
+		r := newUser()
+		if err := r.SetField("id", New("not an int")); err == nil {
+			t.Errorf("expected error, got none")
+		}
+		if err := r.SetField("name", NewNull(String)); err == nil {
+			t.Errorf("expected error for non nullable field, got none")
+		}
+		if err := r.SetField("address", MustNewRecord(userSchema, New(int64(1)), New("foo"), MustNewRecord(addressSchema, New("Rome")))); err == nil {
+			t.Errorf("expected error for wrong schema, got none")
+		}
+		wrongTags := NewList(Int)
+		if err := r.SetField("tags", wrongTags); err == nil {
+			t.Errorf("expected error for wrong list, got none")
+		}
+		if err := r.SetField("id", New(int64(1))); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := NewRecord(userSchema, New(int64(1))); err == nil {
+			t.Errorf("expected error for missing values, got none")
+		}
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		r := newUser()
+		c := r.Clone().(*Record)
+		if err := c.SetField("name", New("other")); err != nil {
+			t.Fatal(err)
+		}
+		address, _ := c.GetField("address")
+		if err := address.(*Record).SetField("city", New("Rome")); err != nil {
+			t.Fatal(err)
+		}
+		want := "{id: 42, name: affo, address: {city: Milan, zip: nil(string)}, tags: [admin ops]}"
+		if diff := cmp.Diff(want, r.String()); diff != "" {
+			t.Errorf("original record changed -want/+got:\n\t%s", diff)
+		}
+		if c.Schema() != r.Schema() {
+			t.Errorf("expected schema to be shared")
+		}
+	})
+
+	t.Run("codec", func(t *testing.T) {
+		r := newUser()
+		b, err := Marshal(SetKey(1, r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Unmarshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(describe(SetKey(1, r)), describe(got)); diff != "" {
+			t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package values
+
+import "fmt"
+
+// Composite types.
+// Use DataType to describe them completely.
+const (
+	_ Type = Close + iota
+	RecordType
+	ListType
+	MapType
+	SetType
+)
+
+// extendedTypeString returns the string representation of types that are not generated.
+func extendedTypeString(t Type) string {
+	switch t {
+	// Note that Close and Unknown are the same.
+	case Close:
+		return "close"
+	case RecordType:
+		return "record"
+	case ListType:
+		return "list"
+	case MapType:
+		return "map"
+	case SetType:
+		return "set"
+	default:
+		panic(fmt.Errorf("unknown type %d", t))
+	}
+}
+
+// DataType completely describes the type of a value, including the types of the elements of composite values.
+type DataType struct {
+	Type Type
+	// Elem is the type of the elements of lists and sets, and of the values of maps.
+	Elem *DataType
+	// Key is the type of the keys of maps.
+	Key *DataType
+	// Schema describes records.
+	Schema *Schema
+}
+
+func Scalar(t Type) DataType {
+	return DataType{Type: t}
+}
+
+func ListOf(elem DataType) DataType {
+	return DataType{Type: ListType, Elem: &elem}
+}
+
+func SetOf(elem DataType) DataType {
+	return DataType{Type: SetType, Elem: &elem}
+}
+
+func MapOf(key, value DataType) DataType {
+	return DataType{Type: MapType, Key: &key, Elem: &value}
+}
+
+func RecordOf(s *Schema) DataType {
+	return DataType{Type: RecordType, Schema: s}
+}
+
+func (dt DataType) Equal(o DataType) bool {
+	if dt.Type != o.Type {
+		return false
+	}
+	if (dt.Elem == nil) != (o.Elem == nil) || (dt.Key == nil) != (o.Key == nil) {
+		return false
+	}
+	if dt.Elem != nil && !dt.Elem.Equal(*o.Elem) {
+		return false
+	}
+	if dt.Key != nil && !dt.Key.Equal(*o.Key) {
+		return false
+	}
+	return dt.Schema.Equal(o.Schema)
+}
+
+func (dt DataType) String() string {
+	switch dt.Type {
+	case ListType, SetType:
+		if dt.Elem == nil {
+			return dt.Type.String()
+		}
+		return fmt.Sprintf("%v<%v>", dt.Type, dt.Elem)
+	case MapType:
+		if dt.Key == nil || dt.Elem == nil {
+			return dt.Type.String()
+		}
+		return fmt.Sprintf("%v<%v, %v>", dt.Type, dt.Key, dt.Elem)
+	case RecordType:
+		if dt.Schema == nil {
+			return dt.Type.String()
+		}
+		return dt.Schema.String()
+	default:
+		return dt.Type.String()
+	}
+}
+
+// TypeOf returns the DataType of a value.
+// The types of the elements of composite values are taken from their first element, if they are composite themselves.
+func TypeOf(v Value) DataType {
+	v = undecorate(v)
+	switch v := v.(type) {
+	case *List:
+		return ListOf(elemType(v.t, v.vs))
+	case *Set:
+		return SetOf(elemType(v.t, v.es.ks))
+	case *Map:
+		return MapOf(elemType(v.kt, v.es.ks), elemType(v.vt, v.es.vs))
+	case *Record:
+		return RecordOf(v.s)
+	default:
+		return Scalar(v.Type())
+	}
+}
+
+func elemType(t Type, vs []Value) DataType {
+	if len(vs) > 0 && isComposite(t) {
+		return TypeOf(vs[0])
+	}
+	return Scalar(t)
+}
+
+func isComposite(t Type) bool {
+	switch t {
+	case RecordType, ListType, MapType, SetType:
+		return true
+	default:
+		return false
+	}
+}
@@ -8,6 +8,7 @@ package values
 
 import (
 	"fmt"
+	"time"
 )
 
 type Type int
@@ -28,11 +29,17 @@ const (
 	Float64
 	String
 	Bool
+	Time
+	Duration
+	Bytes
+	Decimal
 	Unknown
 )
 
 func (t Type) String() string {
 	switch t {
+	case Object:
+		return "object"
 	case Int:
 		return "int"
 	case Int8:
@@ -59,8 +66,16 @@ func (t Type) String() string {
 		return "string"
 	case Bool:
 		return "bool"
+	case Time:
+		return "time"
+	case Duration:
+		return "duration"
+	case Bytes:
+		return "bytes"
+	case Decimal:
+		return "decimal"
 	default:
-		panic(fmt.Errorf("unknown type %d", t))
+		return extendedTypeString(t)
 	}
 }
 
@@ -84,6 +99,10 @@ type Value interface {
 	Float64() float64
 	String() string
 	Bool() bool
+	Time() time.Time
+	Duration() time.Duration
+	Bytes() []byte
+	Decimal() Dec
 
 	Clone() Value
 }
@@ -156,6 +175,22 @@ func (v intValue) Bool() bool {
 	panic("cannot get bool out of int")
 }
 
+func (v intValue) Time() time.Time {
+	panic("cannot get time out of int")
+}
+
+func (v intValue) Duration() time.Duration {
+	panic("cannot get duration out of int")
+}
+
+func (v intValue) Bytes() []byte {
+	panic("cannot get bytes out of int")
+}
+
+func (v intValue) Decimal() Dec {
+	panic("cannot get decimal out of int")
+}
+
 func (v intValue) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -232,6 +267,22 @@ func (v int8Value) Bool() bool {
 	panic("cannot get bool out of int8")
 }
 
+func (v int8Value) Time() time.Time {
+	panic("cannot get time out of int8")
+}
+
+func (v int8Value) Duration() time.Duration {
+	panic("cannot get duration out of int8")
+}
+
+func (v int8Value) Bytes() []byte {
+	panic("cannot get bytes out of int8")
+}
+
+func (v int8Value) Decimal() Dec {
+	panic("cannot get decimal out of int8")
+}
+
 func (v int8Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -308,6 +359,22 @@ func (v int16Value) Bool() bool {
 	panic("cannot get bool out of int16")
 }
 
+func (v int16Value) Time() time.Time {
+	panic("cannot get time out of int16")
+}
+
+func (v int16Value) Duration() time.Duration {
+	panic("cannot get duration out of int16")
+}
+
+func (v int16Value) Bytes() []byte {
+	panic("cannot get bytes out of int16")
+}
+
+func (v int16Value) Decimal() Dec {
+	panic("cannot get decimal out of int16")
+}
+
 func (v int16Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -384,6 +451,22 @@ func (v int32Value) Bool() bool {
 	panic("cannot get bool out of int32")
 }
 
+func (v int32Value) Time() time.Time {
+	panic("cannot get time out of int32")
+}
+
+func (v int32Value) Duration() time.Duration {
+	panic("cannot get duration out of int32")
+}
+
+func (v int32Value) Bytes() []byte {
+	panic("cannot get bytes out of int32")
+}
+
+func (v int32Value) Decimal() Dec {
+	panic("cannot get decimal out of int32")
+}
+
 func (v int32Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -460,6 +543,22 @@ func (v int64Value) Bool() bool {
 	panic("cannot get bool out of int64")
 }
 
+func (v int64Value) Time() time.Time {
+	panic("cannot get time out of int64")
+}
+
+func (v int64Value) Duration() time.Duration {
+	panic("cannot get duration out of int64")
+}
+
+func (v int64Value) Bytes() []byte {
+	panic("cannot get bytes out of int64")
+}
+
+func (v int64Value) Decimal() Dec {
+	panic("cannot get decimal out of int64")
+}
+
 func (v int64Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -536,6 +635,22 @@ func (v uint8Value) Bool() bool {
 	panic("cannot get bool out of uint8")
 }
 
+func (v uint8Value) Time() time.Time {
+	panic("cannot get time out of uint8")
+}
+
+func (v uint8Value) Duration() time.Duration {
+	panic("cannot get duration out of uint8")
+}
+
+func (v uint8Value) Bytes() []byte {
+	panic("cannot get bytes out of uint8")
+}
+
+func (v uint8Value) Decimal() Dec {
+	panic("cannot get decimal out of uint8")
+}
+
 func (v uint8Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -612,6 +727,22 @@ func (v uint16Value) Bool() bool {
 	panic("cannot get bool out of uint16")
 }
 
+func (v uint16Value) Time() time.Time {
+	panic("cannot get time out of uint16")
+}
+
+func (v uint16Value) Duration() time.Duration {
+	panic("cannot get duration out of uint16")
+}
+
+func (v uint16Value) Bytes() []byte {
+	panic("cannot get bytes out of uint16")
+}
+
+func (v uint16Value) Decimal() Dec {
+	panic("cannot get decimal out of uint16")
+}
+
 func (v uint16Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -688,6 +819,22 @@ func (v uint32Value) Bool() bool {
 	panic("cannot get bool out of uint32")
 }
 
+func (v uint32Value) Time() time.Time {
+	panic("cannot get time out of uint32")
+}
+
+func (v uint32Value) Duration() time.Duration {
+	panic("cannot get duration out of uint32")
+}
+
+func (v uint32Value) Bytes() []byte {
+	panic("cannot get bytes out of uint32")
+}
+
+func (v uint32Value) Decimal() Dec {
+	panic("cannot get decimal out of uint32")
+}
+
 func (v uint32Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -764,6 +911,22 @@ func (v uint64Value) Bool() bool {
 	panic("cannot get bool out of uint64")
 }
 
+func (v uint64Value) Time() time.Time {
+	panic("cannot get time out of uint64")
+}
+
+func (v uint64Value) Duration() time.Duration {
+	panic("cannot get duration out of uint64")
+}
+
+func (v uint64Value) Bytes() []byte {
+	panic("cannot get bytes out of uint64")
+}
+
+func (v uint64Value) Decimal() Dec {
+	panic("cannot get decimal out of uint64")
+}
+
 func (v uint64Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -840,6 +1003,22 @@ func (v float32Value) Bool() bool {
 	panic("cannot get bool out of float32")
 }
 
+func (v float32Value) Time() time.Time {
+	panic("cannot get time out of float32")
+}
+
+func (v float32Value) Duration() time.Duration {
+	panic("cannot get duration out of float32")
+}
+
+func (v float32Value) Bytes() []byte {
+	panic("cannot get bytes out of float32")
+}
+
+func (v float32Value) Decimal() Dec {
+	panic("cannot get decimal out of float32")
+}
+
 func (v float32Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -916,6 +1095,22 @@ func (v float64Value) Bool() bool {
 	panic("cannot get bool out of float64")
 }
 
+func (v float64Value) Time() time.Time {
+	panic("cannot get time out of float64")
+}
+
+func (v float64Value) Duration() time.Duration {
+	panic("cannot get duration out of float64")
+}
+
+func (v float64Value) Bytes() []byte {
+	panic("cannot get bytes out of float64")
+}
+
+func (v float64Value) Decimal() Dec {
+	panic("cannot get decimal out of float64")
+}
+
 func (v float64Value) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -992,6 +1187,22 @@ func (v stringValue) Bool() bool {
 	panic("cannot get bool out of string")
 }
 
+func (v stringValue) Time() time.Time {
+	panic("cannot get time out of string")
+}
+
+func (v stringValue) Duration() time.Duration {
+	panic("cannot get duration out of string")
+}
+
+func (v stringValue) Bytes() []byte {
+	panic("cannot get bytes out of string")
+}
+
+func (v stringValue) Decimal() Dec {
+	panic("cannot get decimal out of string")
+}
+
 func (v stringValue) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -1068,6 +1279,22 @@ func (v boolValue) Bool() bool {
 	return v.v
 }
 
+func (v boolValue) Time() time.Time {
+	panic("cannot get time out of bool")
+}
+
+func (v boolValue) Duration() time.Duration {
+	panic("cannot get duration out of bool")
+}
+
+func (v boolValue) Bytes() []byte {
+	panic("cannot get bytes out of bool")
+}
+
+func (v boolValue) Decimal() Dec {
+	panic("cannot get decimal out of bool")
+}
+
 func (v boolValue) String() string {
 	return fmt.Sprintf("%v", v.Get())
 }
@@ -1076,6 +1303,376 @@ func (v boolValue) Clone() Value {
 	return New(v.v)
 }
 
+type timeValue struct {
+	v time.Time
+}
+
+func (v timeValue) Type() Type {
+	return Time
+}
+
+func (v timeValue) Get() interface{} {
+	return v.v
+}
+
+func (v timeValue) IsNull() bool {
+	return false
+}
+
+func (v timeValue) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("value of type time cannot be unwrapped")
+}
+
+func (v timeValue) Int() int {
+	panic("cannot get int out of time")
+}
+
+func (v timeValue) Int8() int8 {
+	panic("cannot get int8 out of time")
+}
+
+func (v timeValue) Int16() int16 {
+	panic("cannot get int16 out of time")
+}
+
+func (v timeValue) Int32() int32 {
+	panic("cannot get int32 out of time")
+}
+
+func (v timeValue) Int64() int64 {
+	panic("cannot get int64 out of time")
+}
+
+func (v timeValue) Uint8() uint8 {
+	panic("cannot get uint8 out of time")
+}
+
+func (v timeValue) Uint16() uint16 {
+	panic("cannot get uint16 out of time")
+}
+
+func (v timeValue) Uint32() uint32 {
+	panic("cannot get uint32 out of time")
+}
+
+func (v timeValue) Uint64() uint64 {
+	panic("cannot get uint64 out of time")
+}
+
+func (v timeValue) Float32() float32 {
+	panic("cannot get float32 out of time")
+}
+
+func (v timeValue) Float64() float64 {
+	panic("cannot get float64 out of time")
+}
+
+func (v timeValue) Bool() bool {
+	panic("cannot get bool out of time")
+}
+
+func (v timeValue) Time() time.Time {
+	return v.v
+}
+
+func (v timeValue) Duration() time.Duration {
+	panic("cannot get duration out of time")
+}
+
+func (v timeValue) Bytes() []byte {
+	panic("cannot get bytes out of time")
+}
+
+func (v timeValue) Decimal() Dec {
+	panic("cannot get decimal out of time")
+}
+
+func (v timeValue) String() string {
+	return fmt.Sprintf("%v", v.Get())
+}
+
+func (v timeValue) Clone() Value {
+	return New(v.v)
+}
+
+type durationValue struct {
+	v time.Duration
+}
+
+func (v durationValue) Type() Type {
+	return Duration
+}
+
+func (v durationValue) Get() interface{} {
+	return v.v
+}
+
+func (v durationValue) IsNull() bool {
+	return false
+}
+
+func (v durationValue) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("value of type duration cannot be unwrapped")
+}
+
+func (v durationValue) Int() int {
+	panic("cannot get int out of duration")
+}
+
+func (v durationValue) Int8() int8 {
+	panic("cannot get int8 out of duration")
+}
+
+func (v durationValue) Int16() int16 {
+	panic("cannot get int16 out of duration")
+}
+
+func (v durationValue) Int32() int32 {
+	panic("cannot get int32 out of duration")
+}
+
+func (v durationValue) Int64() int64 {
+	panic("cannot get int64 out of duration")
+}
+
+func (v durationValue) Uint8() uint8 {
+	panic("cannot get uint8 out of duration")
+}
+
+func (v durationValue) Uint16() uint16 {
+	panic("cannot get uint16 out of duration")
+}
+
+func (v durationValue) Uint32() uint32 {
+	panic("cannot get uint32 out of duration")
+}
+
+func (v durationValue) Uint64() uint64 {
+	panic("cannot get uint64 out of duration")
+}
+
+func (v durationValue) Float32() float32 {
+	panic("cannot get float32 out of duration")
+}
+
+func (v durationValue) Float64() float64 {
+	panic("cannot get float64 out of duration")
+}
+
+func (v durationValue) Bool() bool {
+	panic("cannot get bool out of duration")
+}
+
+func (v durationValue) Time() time.Time {
+	panic("cannot get time out of duration")
+}
+
+func (v durationValue) Duration() time.Duration {
+	return v.v
+}
+
+func (v durationValue) Bytes() []byte {
+	panic("cannot get bytes out of duration")
+}
+
+func (v durationValue) Decimal() Dec {
+	panic("cannot get decimal out of duration")
+}
+
+func (v durationValue) String() string {
+	return fmt.Sprintf("%v", v.Get())
+}
+
+func (v durationValue) Clone() Value {
+	return New(v.v)
+}
+
+type bytesValue struct {
+	v []byte
+}
+
+func (v bytesValue) Type() Type {
+	return Bytes
+}
+
+func (v bytesValue) Get() interface{} {
+	return v.v
+}
+
+func (v bytesValue) IsNull() bool {
+	return false
+}
+
+func (v bytesValue) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("value of type bytes cannot be unwrapped")
+}
+
+func (v bytesValue) Int() int {
+	panic("cannot get int out of bytes")
+}
+
+func (v bytesValue) Int8() int8 {
+	panic("cannot get int8 out of bytes")
+}
+
+func (v bytesValue) Int16() int16 {
+	panic("cannot get int16 out of bytes")
+}
+
+func (v bytesValue) Int32() int32 {
+	panic("cannot get int32 out of bytes")
+}
+
+func (v bytesValue) Int64() int64 {
+	panic("cannot get int64 out of bytes")
+}
+
+func (v bytesValue) Uint8() uint8 {
+	panic("cannot get uint8 out of bytes")
+}
+
+func (v bytesValue) Uint16() uint16 {
+	panic("cannot get uint16 out of bytes")
+}
+
+func (v bytesValue) Uint32() uint32 {
+	panic("cannot get uint32 out of bytes")
+}
+
+func (v bytesValue) Uint64() uint64 {
+	panic("cannot get uint64 out of bytes")
+}
+
+func (v bytesValue) Float32() float32 {
+	panic("cannot get float32 out of bytes")
+}
+
+func (v bytesValue) Float64() float64 {
+	panic("cannot get float64 out of bytes")
+}
+
+func (v bytesValue) Bool() bool {
+	panic("cannot get bool out of bytes")
+}
+
+func (v bytesValue) Time() time.Time {
+	panic("cannot get time out of bytes")
+}
+
+func (v bytesValue) Duration() time.Duration {
+	panic("cannot get duration out of bytes")
+}
+
+func (v bytesValue) Bytes() []byte {
+	return v.v
+}
+
+func (v bytesValue) Decimal() Dec {
+	panic("cannot get decimal out of bytes")
+}
+
+func (v bytesValue) String() string {
+	return fmt.Sprintf("%v", v.Get())
+}
+
+func (v bytesValue) Clone() Value {
+	c := make([]byte, len(v.v))
+	copy(c, v.v)
+	return New(c)
+}
+
+type decimalValue struct {
+	v Dec
+}
+
+func (v decimalValue) Type() Type {
+	return Decimal
+}
+
+func (v decimalValue) Get() interface{} {
+	return v.v
+}
+
+func (v decimalValue) IsNull() bool {
+	return false
+}
+
+func (v decimalValue) Unwrap() (Value, error) {
+	return nil, fmt.Errorf("value of type decimal cannot be unwrapped")
+}
+
+func (v decimalValue) Int() int {
+	panic("cannot get int out of decimal")
+}
+
+func (v decimalValue) Int8() int8 {
+	panic("cannot get int8 out of decimal")
+}
+
+func (v decimalValue) Int16() int16 {
+	panic("cannot get int16 out of decimal")
+}
+
+func (v decimalValue) Int32() int32 {
+	panic("cannot get int32 out of decimal")
+}
+
+func (v decimalValue) Int64() int64 {
+	panic("cannot get int64 out of decimal")
+}
+
+func (v decimalValue) Uint8() uint8 {
+	panic("cannot get uint8 out of decimal")
+}
+
+func (v decimalValue) Uint16() uint16 {
+	panic("cannot get uint16 out of decimal")
+}
+
+func (v decimalValue) Uint32() uint32 {
+	panic("cannot get uint32 out of decimal")
+}
+
+func (v decimalValue) Uint64() uint64 {
+	panic("cannot get uint64 out of decimal")
+}
+
+func (v decimalValue) Float32() float32 {
+	panic("cannot get float32 out of decimal")
+}
+
+func (v decimalValue) Float64() float64 {
+	panic("cannot get float64 out of decimal")
+}
+
+func (v decimalValue) Bool() bool {
+	panic("cannot get bool out of decimal")
+}
+
+func (v decimalValue) Time() time.Time {
+	panic("cannot get time out of decimal")
+}
+
+func (v decimalValue) Duration() time.Duration {
+	panic("cannot get duration out of decimal")
+}
+
+func (v decimalValue) Bytes() []byte {
+	panic("cannot get bytes out of decimal")
+}
+
+func (v decimalValue) Decimal() Dec {
+	return v.v
+}
+
+func (v decimalValue) String() string {
+	return fmt.Sprintf("%v", v.Get())
+}
+
+func (v decimalValue) Clone() Value {
+	return New(v.v)
+}
+
 func New(v interface{}) Value {
 	if v == nil {
 		panic("cannot create value from nil")
@@ -1107,6 +1704,14 @@ func New(v interface{}) Value {
 		return stringValue{v: v}
 	case bool:
 		return boolValue{v: v}
+	case time.Time:
+		return timeValue{v: v}
+	case time.Duration:
+		return durationValue{v: v}
+	case []byte:
+		return bytesValue{v: v}
+	case Dec:
+		return decimalValue{v: v}
 	default:
 		return goObjectValue{v: v}
 	}
@@ -1184,6 +1789,22 @@ func (v nullValue) Bool() bool {
 	panic("cannot get bool out of null value")
 }
 
+func (v nullValue) Time() time.Time {
+	panic("cannot get time out of null value")
+}
+
+func (v nullValue) Duration() time.Duration {
+	panic("cannot get duration out of null value")
+}
+
+func (v nullValue) Bytes() []byte {
+	panic("cannot get bytes out of null value")
+}
+
+func (v nullValue) Decimal() Dec {
+	panic("cannot get decimal out of null value")
+}
+
 func (v nullValue) Clone() Value {
 	return NewNull(v.t)
 }
@@ -1260,6 +1881,22 @@ func (v goObjectValue) Bool() bool {
 	panic("cannot get bool out of generic Go object value")
 }
 
+func (v goObjectValue) Time() time.Time {
+	panic("cannot get time out of generic Go object value")
+}
+
+func (v goObjectValue) Duration() time.Duration {
+	panic("cannot get duration out of generic Go object value")
+}
+
+func (v goObjectValue) Bytes() []byte {
+	panic("cannot get bytes out of generic Go object value")
+}
+
+func (v goObjectValue) Decimal() Dec {
+	panic("cannot get decimal out of generic Go object value")
+}
+
 func (v goObjectValue) String() string {
 	return fmt.Sprintf("%v", v.v)
 }
@@ -1267,3 +1904,71 @@ func (v goObjectValue) String() string {
 func (v goObjectValue) Clone() Value {
 	return New(v.v)
 }
+
+// nonPrimitive implements the primitive accessors of Value by panicking.
+// Values that are not primitive can embed it.
+type nonPrimitive struct{}
+
+func (nonPrimitive) Int() int {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Int8() int8 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Int16() int16 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Int32() int32 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Int64() int64 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Uint8() uint8 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Uint16() uint16 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Uint32() uint32 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Uint64() uint64 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Float32() float32 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Float64() float64 {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Bool() bool {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Time() time.Time {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Duration() time.Duration {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Bytes() []byte {
+	panic("cannot return primitive type from non-primitive value")
+}
+
+func (nonPrimitive) Decimal() Dec {
+	panic("cannot return primitive type from non-primitive value")
+}
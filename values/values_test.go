@@ -135,6 +135,26 @@ func TestSource(t *testing.T) {
 	})
 }
 
+func TestOrigin(t *testing.T) {
+	v := SetSource(Source(1), SetOrigin(Origin(2), New(42)))
+	got, _ := GetOrigin(v)
+	want := Origin(2)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	// Sources do not change the origin.
+	got, _ = GetOrigin(SetSource(Source(3), v.Clone()))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result -want/+got:\n\t%s", diff)
+	}
+	if s, _ := GetSource(v); s != Source(1) {
+		t.Errorf("unexpected source: %v", s)
+	}
+	if _, err := GetOrigin(New(42)); err == nil {
+		t.Errorf("expected err, got none")
+	}
+}
+
 func TestTime(t *testing.T) {
 	t.Run("outer time", func(t *testing.T) {
 		v := SetTime(Timestamp(0), Timestamp(10), SetKey(Key(1), SetSource(Source(0), New(42))))
@@ -9,13 +9,20 @@ func in(a []Node, n Node) bool {
 	return false
 }
 
+// Walk visits every Arch reachable from the roots of g once, layer by layer.
+// Graphs with loops are supported: the Arches of a node are visited the first time the node is reached.
 func Walk(g Graph, f Visitor) {
 	// Here, we could use maps, but we need slices.
 	// We want a deterministic range, for a deterministic walk.
 	roots := g.Roots()
+	visited := make(map[Node]bool)
 	for len(roots) > 0 {
 		next := make([]Node, 0)
 		for _, root := range roots {
+			if visited[root] {
+				continue
+			}
+			visited[root] = true
 			for _, a := range g.Adjacents(root) {
 				f(a)
 				n := a.To()
@@ -2,6 +2,9 @@ package ssp
 
 import (
 	"fmt"
+	"sort"
+
+	"github.com/affo/ssp/metrics"
 	"github.com/affo/ssp/values"
 )
 
@@ -50,6 +53,16 @@ func (w *Window) IsEmpty() bool {
 	return len(w.elements) == 0
 }
 
+// copy returns a copy of w, with a copy of its state.
+func (w *Window) copy() *Window {
+	return &Window{
+		start:    w.start,
+		stop:     w.stop,
+		State:    w.State.Clone(),
+		elements: append([]values.TimestampedValue(nil), w.elements...),
+	}
+}
+
 func (w *Window) String() string {
 	return fmt.Sprintf("[%d, %d)", w.Start(), w.Stop())
 }
@@ -67,6 +80,8 @@ type FixedWindowManager struct {
 	wss   []*Window
 	state values.Value
 	wm    values.Timestamp
+	// open, if set, is updated with the number of windows that open and close.
+	open metrics.Gauge
 }
 
 func NewFixedWindowManager(size int, slide int, state values.Value) *FixedWindowManager {
@@ -96,11 +111,12 @@ func (m *FixedWindowManager) ForEachWindow(ts values.Timestamp, f func(w *Window
 		startTs := values.Timestamp(start)
 		if _, ok := m.ws[startTs]; !ok {
 			m.ws[startTs] = NewWindow(startTs, values.Timestamp(start+m.size), m.state.Clone())
+			m.track(1)
 		}
 	}
 
 	// TODO: this should be more efficient. Consider using a BST, for example.
-	for _, w := range m.ws {
+	for _, w := range m.sorted() {
 		if ts >= w.Start() && ts < w.Stop() {
 			if err := f(w); err != nil {
 				return err
@@ -110,17 +126,60 @@ func (m *FixedWindowManager) ForEachWindow(ts values.Timestamp, f func(w *Window
 	return nil
 }
 
+// copy returns a copy of m, with a copy of its windows.
+func (m *FixedWindowManager) copy() *FixedWindowManager {
+	c := &FixedWindowManager{
+		size:  m.size,
+		slide: m.slide,
+		ws:    make(map[values.Timestamp]*Window, len(m.ws)),
+		wss:   make([]*Window, 0),
+		state: m.state,
+		wm:    m.wm,
+		open:  m.open,
+	}
+	for start, w := range m.ws {
+		c.ws[start] = w.copy()
+	}
+	return c
+}
+
+// restore brings m back to the windows of cp, a copy of m.
+func (m *FixedWindowManager) restore(cp *FixedWindowManager) {
+	m.track(float64(len(cp.ws) - len(m.ws)))
+	m.ws = cp.ws
+	m.wm = cp.wm
+}
+
+func (m *FixedWindowManager) track(delta float64) {
+	if m.open != nil {
+		m.open.Add(delta)
+	}
+}
+
+// sorted returns the active windows in order of start.
+func (m *FixedWindowManager) sorted() []*Window {
+	ws := make([]*Window, 0, len(m.ws))
+	for _, w := range m.ws {
+		ws = append(ws, w)
+	}
+	sort.Slice(ws, func(i, j int) bool {
+		return ws[i].Start() < ws[j].Start()
+	})
+	return ws
+}
+
 func (m *FixedWindowManager) ForEachClosedWindow(wm values.Timestamp, f func(w *Window) error) error {
 	// Update the watermark.
 	if wm > m.wm {
 		m.wm = wm
 	}
 	// TODO: this should be more efficient. Consider using a BST, for example.
-	// TODO: this is also not deterministic in order!
-	for t, w := range m.ws {
+	// Windows close in order of start.
+	for _, w := range m.sorted() {
 		// This window can be closed.
 		if m.wm >= w.Stop() {
-			delete(m.ws, t)
+			delete(m.ws, w.Start())
+			m.track(-1)
 			if err := f(w); err != nil {
 				return err
 			}
@@ -137,6 +196,9 @@ type windowedNode struct {
 	wm      WindowManager
 	fn      WindowFn
 	closeFn WindowCloseFn
+	ts      Timestamps
+	// agg is set for window aggregates.
+	agg Aggregator
 
 	// For cloning.
 	size  int
@@ -180,6 +242,14 @@ func (n *windowedNode) Out() *Arch {
 	return NewLink(n)
 }
 
+func (n *windowedNode) OutTag(tag string) *Arch {
+	return NewTaggedLink(n, tag)
+}
+
+func (n *windowedNode) DeadLetters() *Arch {
+	return n.OutTag(DeadLetterTag)
+}
+
 func (n *windowedNode) SetParallelism(par int) Node {
 	n.par = par
 	return n
@@ -190,12 +260,46 @@ func (n *windowedNode) SetName(name string) Node {
 	return n
 }
 
+func (n *windowedNode) SetErrorPolicy(p ErrorPolicy) Node {
+	n.ep = p
+	return n
+}
+
+func (n *windowedNode) GetTimestamps() Timestamps {
+	return n.ts
+}
+
+func (n *windowedNode) isStateful() bool {
+	return true
+}
+
+func (n *windowedNode) instrument(m *operatorMetrics) {
+	if fm, ok := n.wm.(*FixedWindowManager); ok {
+		fm.open = m.windows
+	}
+}
+
+func (n *windowedNode) checkpoint(v values.Value) interface{} {
+	if fm, ok := n.wm.(*FixedWindowManager); ok {
+		return fm.copy()
+	}
+	return nil
+}
+
+func (n *windowedNode) restore(cp interface{}) {
+	if cp != nil {
+		n.wm.(*FixedWindowManager).restore(cp.(*FixedWindowManager))
+	}
+}
+
 func (n *windowedNode) Clone() Node {
 	return &windowedNode{
 		baseNode: n.baseNode.Clone(),
 		wm:       NewFixedWindowManager(n.size, n.slide, n.state),
 		fn:       n.fn,
 		closeFn:  n.closeFn,
+		ts:       n.ts,
+		agg:      n.agg,
 		size:     n.size,
 		slide:    n.slide,
 		state:    n.state,